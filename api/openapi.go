@@ -0,0 +1,8 @@
+// Package api holds the OpenAPI description of the HTTP API. It is embedded
+// into the server, which serves it at /openapi.yaml.
+package api
+
+import _ "embed"
+
+//go:embed openapi.yaml
+var OpenAPI []byte
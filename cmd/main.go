@@ -2,57 +2,287 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/SenechkaP/subs-tracker/api"
 	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/analytics"
+	"github.com/SenechkaP/subs-tracker/internal/docs"
+	"github.com/SenechkaP/subs-tracker/internal/events"
+	"github.com/SenechkaP/subs-tracker/internal/grpcserver"
+	"github.com/SenechkaP/subs-tracker/internal/health"
+	"github.com/SenechkaP/subs-tracker/internal/idempotency"
 	"github.com/SenechkaP/subs-tracker/internal/logger"
 	"github.com/SenechkaP/subs-tracker/internal/migrations"
 	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/internal/ui"
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
 	"github.com/SenechkaP/subs-tracker/pkg/db"
 	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+	"github.com/SenechkaP/subs-tracker/pkg/tracing"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
 )
 
-func App(envPath string) http.Handler {
-	conf := configs.LoadConfig(envPath)
-	database := db.NewDb(conf)
-	if err := migrations.RunMigrations(database); err != nil {
-		logger.Log.Fatalf("migrate failed: %v", err)
-	}
+// exposedHeaders are the response headers browser clients may read besides
+// the CORS-safelisted ones.
+var exposedHeaders = []string{
+	middleware.RequestIDHeader,
+	"ETag",
+	"Retry-After",
+	idempotency.HeaderReplayed,
+	"Deprecation",
+	"Sunset",
+	"Link",
+}
 
+// legacyRoutesDeprecated is when the unversioned routes were replaced by
+// /v1, sent in their Deprecation header.
+var legacyRoutesDeprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+// App wires storage, services and HTTP routes. The subscription service is
+// returned as well so that other transports can share it.
+func App(conf *configs.Config) (http.Handler, *subscription.SubscriptionService) {
 	router := http.NewServeMux()
+	v1Config := apiversion.VersionConfig{Name: "v1"}
+	if conf.LegacyRoutes {
+		v1Config.LegacyAliases = &apiversion.Deprecation{Since: legacyRoutesDeprecated, Sunset: conf.LegacySunset}
+	}
+	v1 := apiversion.Mount(router, v1Config)
 
-	subscriptionRepository := subscription.NewSubscriptionRepository(database)
+	var database *gorm.DB
+	var subscriptionRepository subscription.Repository
+	var idempotencyGuard *idempotency.Guard
 
-	subscription.NewSubscriptionHandler(router, &subscription.SubscriptionHandlerDeps{
-		Repository: subscriptionRepository,
+	switch conf.DBDriver {
+	case configs.DBDriverMemory:
+		logger.Log.Warnf("using in-memory storage: data is lost on restart, idempotency keys and analytics are disabled")
+		subscriptionRepository = subscription.NewMemoryRepository()
+	case configs.DBDriverSQLite:
+		var err error
+		database, err = db.OpenSQLite(conf.SQLitePath, conf.DBLogLevel)
+		if err != nil {
+			logger.Log.Fatalf("failed to open sqlite %s: %v", conf.SQLitePath, err)
+		}
+		if err := migrations.AutoMigrate(database); err != nil {
+			logger.Log.Fatalf("migrate failed: %v", err)
+		}
+		logger.Log.Warnf("using sqlite storage at %s: analytics are disabled", conf.SQLitePath)
+		subscriptionRepository = subscription.NewSubscriptionRepository(database)
+		idempotencyGuard = idempotency.NewGuard(idempotency.NewIdempotencyRepository(database), conf.IdempotencyTTL)
+	default:
+		var err error
+		database, err = db.NewDb(conf)
+		if err != nil {
+			logger.Log.Fatalf("%v", err)
+		}
+		if conf.AutoMigrate {
+			if err := migrations.RunMigrations(database); err != nil {
+				logger.Log.Fatalf("migrate failed: %v", err)
+			}
+		} else {
+			pending, err := migrations.Pending(database)
+			if err != nil {
+				logger.Log.Fatalf("failed to check migrations: %v", err)
+			}
+			if len(pending) > 0 {
+				logger.Log.Fatalf("refusing to start with pending migrations %s, run \"migrate up\" first", strings.Join(pending, ", "))
+			}
+		}
+		subscriptionRepository = subscription.NewSubscriptionRepository(database)
+		idempotencyGuard = idempotency.NewGuard(idempotency.NewIdempotencyRepository(database), conf.IdempotencyTTL)
+		analytics.NewAnalyticsHandler(v1, &analytics.AnalyticsHandlerDeps{
+			Repository: analytics.NewAnalyticsRepository(database),
+		})
+	}
+
+	// The pool stats belong to this App's database, so they go into a
+	// registry of its own rather than the default one, which a second App
+	// in the same process would collide with.
+	registry := prometheus.NewRegistry()
+	if database != nil {
+		if err := tracing.InstrumentDB(database); err != nil {
+			logger.Log.Fatalf("failed to instrument db: %v", err)
+		}
+		sqlDB, err := database.DB()
+		if err != nil {
+			logger.Log.Fatalf("failed to get db pool: %v", err)
+		}
+		registry.MustRegister(collectors.NewDBStatsCollector(sqlDB, conf.DBDriver))
+	}
+	health.NewHealthHandler(router, &health.HealthHandlerDeps{
+		DB:              database,
+		CheckMigrations: conf.DBDriver == configs.DBDriverPostgres,
 	})
+	router.Handle("GET /metrics", promhttp.InstrumentMetricHandler(
+		prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.Gatherers{prometheus.DefaultGatherer, registry}, promhttp.HandlerOpts{}),
+	))
 
-	return middleware.Logging(router)
+	eventBus := events.NewBus(conf.EventsReplaySize)
+	subscriptionService := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+		Repository:     subscriptionRepository,
+		RequireIfMatch: conf.RequireIfMatch,
+		Events:         eventBus,
+	})
+
+	subscription.NewSubscriptionHandler(v1, &subscription.SubscriptionHandlerDeps{
+		Service:     subscriptionService,
+		Idempotency: idempotencyGuard,
+	})
+	events.NewEventsHandler(v1, &events.EventsHandlerDeps{
+		Bus:       eventBus,
+		Heartbeat: conf.EventsHeartbeat,
+	})
+	ui.NewUIHandler(router, &ui.UIHandlerDeps{Service: subscriptionService})
+	docs.NewDocsHandler(router, &docs.DocsHandlerDeps{Spec: api.OpenAPI})
+
+	rateLimit := middleware.RateLimitConfig{
+		Default:   middleware.Limit{RPS: conf.RateLimitRPS, Burst: conf.RateLimitBurst},
+		Routes:    make(map[string]middleware.Limit, len(conf.RateLimitRoutes)),
+		Canonical: v1.Canonical,
+	}
+	for pattern, limit := range conf.RateLimitRoutes {
+		rateLimit.Routes[pattern] = middleware.Limit{RPS: limit.RPS, Burst: limit.Burst}
+	}
+	cors := middleware.CORSConfig{
+		AllowedOrigins:   conf.CORSAllowedOrigins,
+		AllowedMethods:   conf.CORSAllowedMethods,
+		AllowedHeaders:   conf.CORSAllowedHeaders,
+		ExposedHeaders:   exposedHeaders,
+		AllowCredentials: conf.CORSAllowCredentials,
+		MaxAge:           conf.CORSMaxAge,
+	}
+	var handler http.Handler = middleware.MaxBodySize(int64(conf.MaxBodyBytes))(router)
+	handler = middleware.RateLimit(router, rateLimit)(handler)
+	// CORS sits outside the rate limiter, so that preflights are not
+	// limited and 429 responses stay readable by browser clients.
+	handler = middleware.CORS(cors)(handler)
+	// Tracing, Logging and Metrics read the matched route from the request
+	// the mux saw, so nothing between them and the mux may replace the
+	// request with r.WithContext; RequestID and APIKeys do and go outside.
+	handler = middleware.Tracing(middleware.Logging(middleware.Metrics(handler)))
+	return middleware.RequestID(middleware.APIKeys(conf.APIKeys)(handler)), subscriptionService
+}
+
+// runMigrate is the "migrate" mode of the server binary: it applies or rolls
+// back migrations, prints their status and exits.
+func runMigrate(conf *configs.Config, args []string) {
+	if conf.DBDriver != configs.DBDriverPostgres {
+		logger.Log.Fatalf("migrate needs DB_DRIVER=%s, the %s schema is created on startup", configs.DBDriverPostgres, conf.DBDriver)
+	}
+	database, err := db.NewDb(conf)
+	if err != nil {
+		logger.Log.Fatalf("%v", err)
+	}
+	status, err := migrations.RunCommand(database, args)
+	if err != nil {
+		logger.Log.Fatalf("migrate %s failed: %v", strings.Join(args, " "), err)
+	}
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied"
+		}
+		fmt.Printf("%-8s %s\n", state, s.ID)
+	}
 }
 
 func main() {
+	conf, args, err := configs.Load(".env", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "commands:\n  migrate %s\n", migrations.CommandUsage)
+		return
+	}
+	if err != nil {
+		logger.Log.Fatalf("invalid configuration: %v", err)
+	}
+	if err := logger.SetFormat(conf.LogFormat); err != nil {
+		logger.Log.Fatalf("%v", err)
+	}
+	if err := logger.SetLevel(conf.LogLevel); err != nil {
+		logger.Log.Fatalf("%v", err)
+	}
+	logger.Log.Debugf("effective config:\n  %s", strings.Join(conf.Describe(), "\n  "))
+
+	if len(args) > 0 {
+		if args[0] != "migrate" {
+			logger.Log.Fatalf("unknown command %q, expected migrate %s", args[0], migrations.CommandUsage)
+		}
+		runMigrate(conf, args[1:])
+		return
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), conf.TraceExporter)
+	if err != nil {
+		logger.Log.Fatalf("failed to set up tracing: %v", err)
+	}
+
+	handler, subscriptionService := App(conf)
+
 	server := &http.Server{
-		Addr:    ":8081",
-		Handler: App(".env"),
+		Addr:              conf.ListenAddr(),
+		Handler:           handler,
+		ReadTimeout:       conf.ReadTimeout,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
 	}
+	// Event streams never go idle, so Shutdown would wait for them until
+	// its timeout; closing the bus ends them first.
+	server.RegisterOnShutdown(subscriptionService.Events.Close)
 
 	go func() {
+		logger.Log.Infof("http listening on %s", server.Addr)
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Log.Fatalf("listen: %s\n", err)
 		}
 	}()
 
+	grpcServer := grpcserver.NewServer(&grpcserver.SubscriptionServerDeps{
+		Service: subscriptionService,
+	})
+	grpcListener, err := net.Listen("tcp", net.JoinHostPort(conf.AppHost, conf.GRPCPort))
+	if err != nil {
+		logger.Log.Fatalf("grpc listen: %s\n", err)
+	}
+	go func() {
+		if err := grpcServer.Serve(grpcListener); err != nil {
+			logger.Log.Fatalf("grpc serve: %s\n", err)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	<-quit
 
-	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+	ctxShutdown, cancelShutdown := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
 	defer cancelShutdown()
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctxShutdown.Done():
+		grpcServer.Stop()
+	}
 	if err := server.Shutdown(ctxShutdown); err != nil {
 		logger.Log.Fatalf("server shutdown failed:%+v", err)
 	}
+	if err := shutdownTracing(ctxShutdown); err != nil {
+		logger.Log.Errorf("tracing shutdown failed: %v", err)
+	}
 }
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TestAppSpansNamedAfterRoutes runs a request through the middleware chain
+// built by App: a middleware replacing the request between Tracing and the
+// mux leaves spans without a route.
+func TestAppSpansNamedAfterRoutes(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	conf, _, err := configs.Load("", []string{"-db-driver", configs.DBDriverMemory})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler, _ := App(conf)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/subscriptions/6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e", nil))
+	if w.Code != http.StatusNotFound || w.Header().Get("X-Request-ID") == "" {
+		t.Fatalf("response = %d with X-Request-ID %q, want 404 with an ID", w.Code, w.Header().Get("X-Request-ID"))
+	}
+
+	var server sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() == trace.SpanKindServer {
+			server = span
+		}
+	}
+	if server == nil {
+		t.Fatal("no server span")
+	}
+	const route = "GET /v1/subscriptions/{sub_id}"
+	if server.Name() != route {
+		t.Errorf("span name = %q, want %q", server.Name(), route)
+	}
+	var got string
+	for _, attr := range server.Attributes() {
+		if attr.Key == semconv.HTTPRouteKey {
+			got = attr.Value.AsString()
+		}
+	}
+	if got != route {
+		t.Errorf("http.route = %q, want %q", got, route)
+	}
+}
+
+// TestAppTwice builds two apps over separate databases in one process: each
+// serves the pool stats of its own database.
+func TestAppTwice(t *testing.T) {
+	for i := range 2 {
+		conf, _, err := configs.Load("", []string{"-db-driver", configs.DBDriverSQLite, "-sqlite-path", filepath.Join(t.TempDir(), "app.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler, _ := App(conf)
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `go_sql_open_connections{db_name="sqlite"}`) {
+			t.Fatalf("app %d: /metrics = %d without the pool stats:\n%s", i, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"gorm.io/gorm"
+)
+
+var errMigrateDriver = errors.New("migrate needs DB_DRIVER=postgres; the sqlite schema is created automatically")
+
+// openDB connects to the database from the env file. SQL logging is
+// silenced so that it does not end up in the command output.
+func openDB(envPath string) (*configs.Config, *gorm.DB, error) {
+	conf := configs.LoadConfig(envPath)
+
+	var gormDB *gorm.DB
+	switch conf.DBDriver {
+	case configs.DBDriverMemory:
+		return nil, nil, errors.New("-admin needs a database, DB_DRIVER is memory")
+	case configs.DBDriverSQLite:
+		var err error
+		gormDB, err = db.OpenSQLite(conf.SQLitePath, configs.DBLogLevelSilent)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := migrations.AutoMigrate(gormDB); err != nil {
+			return nil, nil, err
+		}
+	default:
+		conf.DBLogLevel = configs.DBLogLevelSilent
+		var err error
+		if gormDB, err = db.NewDb(conf); err != nil {
+			return nil, nil, err
+		}
+	}
+	return conf, gormDB, nil
+}
+
+func closeDB(gormDB *gorm.DB) func() {
+	return func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+}
+
+func openAdminBackend(envPath string) (backend, func(), error) {
+	conf, gormDB, err := openDB(envPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	service := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+		Repository:     subscription.NewSubscriptionRepository(gormDB),
+		RequireIfMatch: conf.RequireIfMatch,
+	})
+	return &adminBackend{service: service}, closeDB(gormDB), nil
+}
+
+func (c *cli) migrate(ctx context.Context, args []string) error {
+	args, err := c.splitArgs(c.newFlagSet("migrate"), args)
+	if err != nil || len(args) == 0 {
+		return errUsage
+	}
+	if !c.admin {
+		return errors.New("migrate talks to the database directly, run it with -admin")
+	}
+	conf, gormDB, err := openDB(c.envPath)
+	if err != nil {
+		return err
+	}
+	defer closeDB(gormDB)()
+	if conf.DBDriver != configs.DBDriverPostgres {
+		return errMigrateDriver
+	}
+	status, err := migrations.RunCommand(gormDB.WithContext(ctx), args)
+	if err != nil {
+		return err
+	}
+	if c.output == formatJSON {
+		return printJSON(c.stdout, status)
+	}
+	rows := make([][]string, 0, len(status))
+	for _, s := range status {
+		state := "pending"
+		if s.Applied {
+			state = "applied"
+		}
+		rows = append(rows, []string{s.ID, state})
+	}
+	return printRows(c.stdout, c.output, []string{"id", "state"}, rows)
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/client"
+	"github.com/google/uuid"
+)
+
+// maxRetries is how many times a request rejected with 429 or a 5xx is
+// retried.
+const maxRetries = 5
+
+// backend is what the CLI commands run against: the HTTP API, or the
+// database directly in admin mode.
+type backend interface {
+	Get(ctx context.Context, id string) (*client.Subscription, error)
+	Create(ctx context.Context, body *client.SubscriptionCreateRequest) (*client.SubscriptionCreateResponse, error)
+	Patch(ctx context.Context, id string, body *client.SubscriptionPatchRequest, ifMatch string) (*client.Subscription, error)
+	Delete(ctx context.Context, id string, ifMatch string) error
+	ListByUser(ctx context.Context, userID string, offset, limit int) ([]client.Subscription, error)
+	Sum(ctx context.Context, start, end, userID, service string) (int64, error)
+	Batch(ctx context.Context, ops []client.BatchOperation) (*client.BatchResponse, error)
+}
+
+// httpBackend runs commands through the HTTP API.
+type httpBackend struct {
+	client *client.Client
+}
+
+func newHTTPBackend(baseURL string) *httpBackend {
+	return &httpBackend{client: client.New(baseURL, client.Options{MaxRetries: maxRetries})}
+}
+
+// withViolations appends field violations to an error message.
+func withViolations(msg string, violations []client.FieldViolation) string {
+	for i, v := range violations {
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += sep + v.Field + " " + v.Message
+	}
+	return msg
+}
+
+func (b *httpBackend) Get(ctx context.Context, id string) (*client.Subscription, error) {
+	return b.client.GetSubscription(ctx, id)
+}
+
+func (b *httpBackend) Create(ctx context.Context, body *client.SubscriptionCreateRequest) (*client.SubscriptionCreateResponse, error) {
+	return b.client.CreateSubscription(ctx, body)
+}
+
+func (b *httpBackend) Patch(ctx context.Context, id string, body *client.SubscriptionPatchRequest, ifMatch string) (*client.Subscription, error) {
+	return b.client.PatchSubscription(ctx, id, body, ifMatch)
+}
+
+func (b *httpBackend) Delete(ctx context.Context, id string, ifMatch string) error {
+	return b.client.DeleteSubscription(ctx, id, ifMatch)
+}
+
+func (b *httpBackend) ListByUser(ctx context.Context, userID string, offset, limit int) ([]client.Subscription, error) {
+	return b.client.ListUserSubscriptions(ctx, userID, offset, limit)
+}
+
+func (b *httpBackend) Sum(ctx context.Context, start, end, userID, service string) (int64, error) {
+	return b.client.SumSubscriptions(ctx, client.MonthRange{Start: start, End: end, UserID: userID, Service: service})
+}
+
+func (b *httpBackend) Batch(ctx context.Context, ops []client.BatchOperation) (*client.BatchResponse, error) {
+	return b.client.BatchSubscriptions(ctx, ops)
+}
+
+// adminBackend runs commands through SubscriptionService directly against
+// the database, bypassing the HTTP API.
+type adminBackend struct {
+	service *subscription.SubscriptionService
+}
+
+func parseID(s, code, msg string) (uuid.UUID, error) {
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, &subscription.ServiceError{Kind: subscription.KindInvalid, Code: code, Message: msg}
+	}
+	return id, nil
+}
+
+func (b *adminBackend) Get(ctx context.Context, id string) (*client.Subscription, error) {
+	subID, err := parseID(id, subscription.ProblemInvalidSubscriptionID, subscription.ErrInvalidSubscriptionUUID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := b.service.Get(ctx, subID)
+	if err != nil {
+		return nil, err
+	}
+	return toClientSubscription(sub), nil
+}
+
+func (b *adminBackend) Create(ctx context.Context, body *client.SubscriptionCreateRequest) (*client.SubscriptionCreateResponse, error) {
+	created, err := b.service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service:   body.Service,
+		PriceRUB:  body.PriceRUB,
+		UserID:    body.UserID,
+		StartDate: body.StartDate,
+		EndDate:   body.EndDate,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := &client.SubscriptionCreateResponse{SubID: created.Subscription.ID.String()}
+	if len(created.OverlapsWith) > 0 {
+		out.Warning = subscription.WarnOverlappingSubscription
+		out.OverlapsWith = created.OverlapsWith
+	}
+	return out, nil
+}
+
+func (b *adminBackend) Patch(ctx context.Context, id string, body *client.SubscriptionPatchRequest, ifMatch string) (*client.Subscription, error) {
+	subID, err := parseID(id, subscription.ProblemInvalidSubscriptionID, subscription.ErrInvalidSubscriptionUUID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := b.service.Patch(ctx, subID, &subscription.SubscriptionPatchRequest{
+		PriceRUB:  body.PriceRUB,
+		StartDate: body.StartDate,
+		EndDate:   body.EndDate,
+	}, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	return toClientSubscription(sub), nil
+}
+
+func (b *adminBackend) Delete(ctx context.Context, id string, ifMatch string) error {
+	subID, err := parseID(id, subscription.ProblemInvalidSubscriptionID, subscription.ErrInvalidSubscriptionUUID)
+	if err != nil {
+		return err
+	}
+	return b.service.Delete(ctx, subID, ifMatch)
+}
+
+func (b *adminBackend) ListByUser(ctx context.Context, userID string, offset, limit int) ([]client.Subscription, error) {
+	uid, err := parseID(userID, subscription.ProblemInvalidUserID, subscription.ErrInvalidUserUUID)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := b.service.ListByUser(ctx, uid, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]client.Subscription, len(subs))
+	for i := range subs {
+		out[i] = *toClientSubscription(&subs[i])
+	}
+	return out, nil
+}
+
+func (b *adminBackend) Sum(ctx context.Context, start, end, userID, service string) (int64, error) {
+	var uid *uuid.UUID
+	if userID != "" {
+		id, err := parseID(userID, subscription.ProblemInvalidUserID, subscription.ErrInvalidUserUUID)
+		if err != nil {
+			return 0, err
+		}
+		uid = &id
+	}
+	var svc *string
+	if service != "" {
+		svc = &service
+	}
+	return b.service.SumByMonthRange(ctx, start, end, uid, svc)
+}
+
+func (b *adminBackend) Batch(ctx context.Context, ops []client.BatchOperation) (*client.BatchResponse, error) {
+	serviceOps := make([]subscription.BatchOperation, len(ops))
+	for i, op := range ops {
+		serviceOps[i] = subscription.BatchOperation{Op: op.Op, ID: op.ID, IfMatch: op.IfMatch, Data: op.Data}
+	}
+	results, committed, err := b.service.Batch(ctx, serviceOps)
+	if err != nil {
+		return nil, err
+	}
+	resp, _ := subscription.NewBatchResponse(results, committed)
+
+	out := &client.BatchResponse{Committed: resp.Committed, Results: make([]client.BatchOperationResult, len(resp.Results))}
+	for i, r := range resp.Results {
+		result := client.BatchOperationResult{Index: r.Index, Op: r.Op, Status: r.Status, ID: r.ID, Code: r.Code, Error: r.Error}
+		if r.Subscription != nil {
+			result.Subscription = toClientSubscription(r.Subscription)
+		}
+		for _, v := range r.Violations {
+			result.Violations = append(result.Violations, client.FieldViolation{Field: v.Field, Code: v.Code, Message: v.Message})
+		}
+		out.Results[i] = result
+	}
+	return out, nil
+}
+
+// toClientSubscription converts a subscription to its API representation.
+func toClientSubscription(sub *models.Subscription) *client.Subscription {
+	return &client.Subscription{
+		ID:        sub.ID,
+		Service:   sub.Service,
+		PriceRUB:  sub.PriceRUB,
+		UserID:    sub.UserID,
+		StartDate: sub.StartDate,
+		EndDate:   sub.EndDate,
+		Version:   sub.Version,
+		CreatedAt: sub.CreatedAt,
+		UpdatedAt: sub.UpdatedAt,
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SenechkaP/subs-tracker/pkg/client"
+)
+
+const (
+	// importChunkSize keeps every batch request well below the server limit.
+	importChunkSize = 500
+	exportPageSize  = 500
+)
+
+func (c *cli) sub(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "create":
+		return c.subCreate(ctx, args[1:])
+	case "get":
+		return c.subGet(ctx, args[1:])
+	case "patch":
+		return c.subPatch(ctx, args[1:])
+	case "delete":
+		return c.subDelete(ctx, args[1:])
+	}
+	return errUsage
+}
+
+func (c *cli) subCreate(ctx context.Context, args []string) error {
+	flags := c.newFlagSet("sub create")
+	var body client.SubscriptionCreateRequest
+	var end string
+	flags.StringVar(&body.UserID, "user", "", "user UUID")
+	flags.StringVar(&body.Service, "service", "", "service name")
+	flags.Int64Var(&body.PriceRUB, "price", 0, "monthly price in RUB")
+	flags.StringVar(&body.StartDate, "start", "", "start month, MM-YYYY")
+	flags.StringVar(&end, "end", "", "end month, MM-YYYY")
+	if rest, err := c.splitArgs(flags, args); err != nil || len(rest) != 0 {
+		return errUsage
+	}
+	body.EndDate = optional(end)
+
+	out, err := c.backend.Create(ctx, &body)
+	if err != nil {
+		return err
+	}
+	if out.Warning != "" {
+		fmt.Fprintf(c.stderr, "warning: %s: %s\n", out.Warning, strings.Join(out.OverlapsWith, ", "))
+	}
+	return c.printCreated(c.stdout, out)
+}
+
+func (c *cli) subGet(ctx context.Context, args []string) error {
+	rest, err := c.splitArgs(c.newFlagSet("sub get"), args)
+	if err != nil || len(rest) != 1 {
+		return errUsage
+	}
+	sub, err := c.backend.Get(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return c.printSubscriptions(c.stdout, []client.Subscription{*sub})
+}
+
+func (c *cli) subPatch(ctx context.Context, args []string) error {
+	flags := c.newFlagSet("sub patch")
+	var price int64
+	var start, end, ifMatch string
+	flags.Int64Var(&price, "price", -1, "monthly price in RUB")
+	flags.StringVar(&start, "start", "", "start month, MM-YYYY")
+	flags.StringVar(&end, "end", "", "end month, MM-YYYY")
+	flags.StringVar(&ifMatch, "if-match", "", "only patch if the subscription still has this ETag")
+	rest, err := c.splitArgs(flags, args)
+	if err != nil || len(rest) != 1 {
+		return errUsage
+	}
+
+	body := client.SubscriptionPatchRequest{StartDate: optional(start), EndDate: optional(end)}
+	if price >= 0 {
+		body.PriceRUB = &price
+	}
+	sub, err := c.backend.Patch(ctx, rest[0], &body, ifMatch)
+	if err != nil {
+		return err
+	}
+	return c.printSubscriptions(c.stdout, []client.Subscription{*sub})
+}
+
+func (c *cli) subDelete(ctx context.Context, args []string) error {
+	flags := c.newFlagSet("sub delete")
+	var ifMatch string
+	flags.StringVar(&ifMatch, "if-match", "", "only delete if the subscription still has this ETag")
+	rest, err := c.splitArgs(flags, args)
+	if err != nil || len(rest) != 1 {
+		return errUsage
+	}
+	return c.backend.Delete(ctx, rest[0], ifMatch)
+}
+
+func (c *cli) user(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "list" {
+		return errUsage
+	}
+	flags := c.newFlagSet("user list")
+	var offset, limit int
+	flags.IntVar(&offset, "offset", 0, "number of subscriptions to skip")
+	flags.IntVar(&limit, "limit", 10, "maximum number of subscriptions")
+	rest, err := c.splitArgs(flags, args[1:])
+	if err != nil || len(rest) != 1 {
+		return errUsage
+	}
+	subs, err := c.backend.ListByUser(ctx, rest[0], offset, limit)
+	if err != nil {
+		return err
+	}
+	return c.printSubscriptions(c.stdout, subs)
+}
+
+func (c *cli) sum(ctx context.Context, args []string) error {
+	flags := c.newFlagSet("sum")
+	var start, end, userID, service string
+	flags.StringVar(&start, "start", "", "first month, MM-YYYY")
+	flags.StringVar(&end, "end", "", "last month, MM-YYYY")
+	flags.StringVar(&userID, "user", "", "only count this user's subscriptions")
+	flags.StringVar(&service, "service", "", "only count this service")
+	if rest, err := c.splitArgs(flags, args); err != nil || len(rest) != 0 || start == "" || end == "" {
+		return errUsage
+	}
+	total, err := c.backend.Sum(ctx, start, end, userID, service)
+	if err != nil {
+		return err
+	}
+	return c.printSum(c.stdout, total)
+}
+
+// importFile creates subscriptions from a CSV or JSON file. Each chunk of
+// importChunkSize rows is sent as one batch, so a bad row rolls back only its
+// own chunk; earlier chunks stay committed.
+func (c *cli) importFile(ctx context.Context, args []string) error {
+	rest, err := c.splitArgs(c.newFlagSet("import"), args)
+	if err != nil || len(rest) != 1 {
+		return errUsage
+	}
+	f, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rows []client.SubscriptionCreateRequest
+	if strings.HasSuffix(strings.ToLower(rest[0]), ".json") {
+		err = json.NewDecoder(f).Decode(&rows)
+	} else {
+		rows, err = readCSV(f)
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", rest[0], err)
+	}
+
+	var created []client.BatchOperationResult
+	for start := 0; start < len(rows); start += importChunkSize {
+		end := min(start+importChunkSize, len(rows))
+		ops := make([]client.BatchOperation, 0, end-start)
+		for i := start; i < end; i++ {
+			data, err := json.Marshal(rows[i])
+			if err != nil {
+				return err
+			}
+			ops = append(ops, client.BatchOperation{Op: client.BatchOpCreate, Data: data})
+		}
+
+		out, err := c.backend.Batch(ctx, ops)
+		if err != nil {
+			return fmt.Errorf("rows %d-%d: %w", start+1, end, err)
+		}
+		if !out.Committed {
+			// The operations after the failed one have the code not_run.
+			i := slices.IndexFunc(out.Results, func(r client.BatchOperationResult) bool {
+				return r.Code != "" && r.Code != client.ProblemNotRun
+			})
+			if i < 0 {
+				return fmt.Errorf("rows %d-%d: chunk rolled back; rows %d-%d were not imported, %d rows imported before them",
+					start+1, end, start+1, len(rows), start)
+			}
+			failed := out.Results[i]
+			return fmt.Errorf("row %d: %s; rows %d-%d were not imported, %d rows imported before them",
+				start+failed.Index+1, withViolations(failed.Error, failed.Violations), start+1, len(rows), start)
+		}
+		for _, result := range out.Results {
+			result.Index += start
+			created = append(created, result)
+		}
+	}
+	return c.printImported(c.stdout, created)
+}
+
+// export writes every subscription of a user, paging through the list
+// endpoint. It defaults to CSV, which import reads back.
+func (c *cli) export(ctx context.Context, args []string) error {
+	flags := c.newFlagSet("export")
+	var userID, path string
+	flags.StringVar(&userID, "user", "", "user UUID")
+	flags.StringVar(&path, "o", "", "write to this file instead of stdout")
+	if rest, err := c.splitArgs(flags, args); err != nil || len(rest) != 0 || userID == "" {
+		return errUsage
+	}
+
+	// Pages are ordered by start date and then ID, so they neither repeat
+	// nor skip subscriptions sharing a start date.
+	var subs []client.Subscription
+	for offset := 0; ; offset += exportPageSize {
+		page, err := c.backend.ListByUser(ctx, userID, offset, exportPageSize)
+		if err != nil {
+			return err
+		}
+		subs = append(subs, page...)
+		if len(page) < exportPageSize {
+			break
+		}
+	}
+
+	w := c.stdout
+	if path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	format := c.output
+	if format == formatTable {
+		format = formatCSV
+	}
+	return printSubscriptions(w, format, subs)
+}
+
+// readCSV reads create requests from CSV. The header row names the columns
+// with the JSON field names, in any order; end_date may be empty.
+func readCSV(r io.Reader) ([]client.SubscriptionCreateRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"service_name", "price", "user_id", "start_date"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+	get := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var rows []client.SubscriptionCreateRequest
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseInt(get(record, "price"), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, get(record, "price"))
+		}
+		rows = append(rows, client.SubscriptionCreateRequest{
+			Service:   get(record, "service_name"),
+			PriceRUB:  price,
+			UserID:    get(record, "user_id"),
+			StartDate: get(record, "start_date"),
+			EndDate:   optional(get(record, "end_date")),
+		})
+	}
+}
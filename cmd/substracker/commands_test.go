@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/client"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/google/uuid"
+)
+
+// newTestCLI returns a CLI in admin mode over an empty SQLite database.
+func newTestCLI(t *testing.T) (*cli, *bytes.Buffer) {
+	t.Helper()
+	gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.AutoMigrate(gormDB); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	t.Cleanup(closeDB(gormDB))
+
+	service := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+		Repository: subscription.NewSubscriptionRepository(gormDB),
+	})
+	stdout := &bytes.Buffer{}
+	return &cli{
+		output:  formatTable,
+		backend: &adminBackend{service: service},
+		stdout:  stdout,
+		stderr:  &bytes.Buffer{},
+	}, stdout
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func listAll(t *testing.T, c *cli, userID string) []client.Subscription {
+	t.Helper()
+	subs, err := c.backend.ListByUser(context.Background(), userID, 0, 10000)
+	if err != nil {
+		t.Fatalf("ListByUser: %v", err)
+	}
+	return subs
+}
+
+// csvRows returns a CSV file with n rows for userID. bad, if positive, is
+// the 1-based row given a negative price.
+func csvRows(userID string, n, bad int) string {
+	var b strings.Builder
+	b.WriteString("service_name,price,user_id,start_date,end_date\n")
+	for i := 1; i <= n; i++ {
+		price := 100
+		if i == bad {
+			price = -1
+		}
+		fmt.Fprintf(&b, "Service %d,%d,%s,01-2025,\n", i, price, userID)
+	}
+	return b.String()
+}
+
+func TestImport(t *testing.T) {
+	userID := uuid.NewString()
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "csv",
+			file: "subs.csv",
+			// Columns may come in any order and end_date may be left out.
+			content: "user_id, start_date,service_name,price\n" +
+				userID + ",01-2025,Netflix,499\n" +
+				userID + ",03-2025,Spotify,199\n",
+		},
+		{
+			name: "json",
+			file: "subs.JSON",
+			content: `[{"service_name":"Netflix","price":499,"user_id":"` + userID + `","start_date":"01-2025"},
+				{"service_name":"Spotify","price":199,"user_id":"` + userID + `","start_date":"03-2025","end_date":"12-2025"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, stdout := newTestCLI(t)
+			if err := c.importFile(context.Background(), []string{writeFile(t, tt.file, tt.content)}); err != nil {
+				t.Fatalf("import: %v", err)
+			}
+			subs := listAll(t, c, userID)
+			if len(subs) != 2 || subs[0].Service != "Spotify" || subs[1].Service != "Netflix" || subs[1].PriceRUB != 499 {
+				t.Fatalf("imported %+v", subs)
+			}
+			if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 3 {
+				t.Fatalf("output = %q, want a header and a line per row", stdout)
+			}
+		})
+	}
+
+	c, _ := newTestCLI(t)
+	err := c.importFile(context.Background(), []string{writeFile(t, "bad.csv", "service_name,price,user_id\n")})
+	if err == nil || !strings.Contains(err.Error(), `missing column "start_date"`) {
+		t.Fatalf("import without start_date = %v", err)
+	}
+}
+
+func TestImportFailedChunk(t *testing.T) {
+	c, _ := newTestCLI(t)
+	userID := uuid.NewString()
+	bad := importChunkSize + 50
+
+	err := c.importFile(context.Background(), []string{writeFile(t, "subs.csv", csvRows(userID, importChunkSize+100, bad))})
+	want := fmt.Sprintf("row %d: %s: price must be between", bad, subscription.ErrValidationFailed)
+	if err == nil || !strings.HasPrefix(err.Error(), want) {
+		t.Fatalf("import = %v, want %q...", err, want)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%d rows imported before them", importChunkSize)) {
+		t.Fatalf("import = %v, want it to report the committed chunk", err)
+	}
+	// The first chunk stays committed, the failed one is rolled back.
+	if subs := listAll(t, c, userID); len(subs) != importChunkSize {
+		t.Fatalf("%d subscriptions imported, want %d", len(subs), importChunkSize)
+	}
+}
+
+// rolledBackBackend rolls back every batch without saying which operation
+// failed.
+type rolledBackBackend struct {
+	backend
+}
+
+func (rolledBackBackend) Batch(_ context.Context, ops []client.BatchOperation) (*client.BatchResponse, error) {
+	out := &client.BatchResponse{}
+	for i, op := range ops {
+		out.Results = append(out.Results, client.BatchOperationResult{Index: i, Op: op.Op, Code: client.ProblemNotRun})
+	}
+	return out, nil
+}
+
+func TestImportRolledBackChunk(t *testing.T) {
+	c, _ := newTestCLI(t)
+	c.backend = rolledBackBackend{c.backend}
+
+	err := c.importFile(context.Background(), []string{writeFile(t, "subs.csv", csvRows(uuid.NewString(), 3, 0))})
+	if err == nil || !strings.HasPrefix(err.Error(), "rows 1-3: chunk rolled back") {
+		t.Fatalf("import = %v, want the chunk reported as rolled back", err)
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	c, _ := newTestCLI(t)
+	userID := uuid.NewString()
+	// More than a page of subscriptions, all starting in the same month.
+	n := exportPageSize + 20
+	if err := c.importFile(context.Background(), []string{writeFile(t, "subs.csv", csvRows(userID, n, 0))}); err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	end := "06-2025"
+	first := listAll(t, c, userID)[0]
+	if _, err := c.backend.Patch(context.Background(), first.ID.String(), &client.SubscriptionPatchRequest{EndDate: &end}, ""); err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := c.export(context.Background(), []string{"-user", userID, "-o", path}); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	imported, _ := newTestCLI(t)
+	if err := imported.importFile(context.Background(), []string{path}); err != nil {
+		t.Fatalf("import of the export: %v", err)
+	}
+
+	key := func(s client.Subscription) string {
+		end := ""
+		if s.EndDate != nil {
+			end = monthYear(*s.EndDate)
+		}
+		return fmt.Sprintf("%s %d %s %s", s.Service, s.PriceRUB, monthYear(s.StartDate), end)
+	}
+	want := make(map[string]int)
+	for _, s := range listAll(t, c, userID) {
+		want[key(s)]++
+	}
+	got := listAll(t, imported, userID)
+	if len(got) != n {
+		t.Fatalf("round trip kept %d of %d subscriptions", len(got), n)
+	}
+	for _, s := range got {
+		if want[key(s)] != 1 {
+			t.Fatalf("round trip produced %q %d times out of the export", key(s), want[key(s)])
+		}
+		want[key(s)]--
+	}
+}
@@ -0,0 +1,166 @@
+// Command substracker is a command-line client for the subscription API. It
+// talks to a running server over HTTP or, with -admin, straight to the
+// database configured in the .env file.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const usage = `usage: substracker [flags] <command> [args]
+
+Commands:
+  sub create   -user ID -service NAME -price N -start MM-YYYY [-end MM-YYYY]
+  sub get      SUB_ID
+  sub patch    SUB_ID [-price N] [-start MM-YYYY] [-end MM-YYYY] [-if-match ETAG]
+  sub delete   SUB_ID [-if-match ETAG]
+  user list    USER_ID [-offset N] [-limit N]
+  sum          -start MM-YYYY -end MM-YYYY [-user ID] [-service NAME]
+  import       FILE            (CSV with a header row, or a JSON array)
+  export       -user ID [-o FILE]
+  migrate      up | down [N] | to ID | status  (admin mode only)
+
+Flags:
+`
+
+// errUsage is returned by commands that were called with bad arguments; the
+// caller prints the usage text instead of the error.
+var errUsage = errors.New("usage")
+
+type cli struct {
+	apiURL  string
+	admin   bool
+	envPath string
+	output  string
+
+	backend backend
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+func main() {
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr}
+	flags := flag.NewFlagSet("substracker", flag.ExitOnError)
+	flags.StringVar(&c.apiURL, "api", envOr("SUBSTRACKER_API", "http://localhost:8081"), "base URL of the HTTP API")
+	flags.BoolVar(&c.admin, "admin", false, "talk to the database directly instead of the HTTP API")
+	flags.StringVar(&c.envPath, "env", defaultEnvPath(), "env file with the database settings for -admin")
+	flags.StringVar(&c.output, "output", formatTable, "output format: table, json or csv")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := c.run(ctx, flags.Args())
+	if errors.Is(err, errUsage) {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	if args[0] == "migrate" {
+		return c.migrate(ctx, args[1:])
+	}
+
+	if c.admin {
+		b, closeDB, err := openAdminBackend(c.envPath)
+		if err != nil {
+			return err
+		}
+		defer closeDB()
+		c.backend = b
+	} else {
+		c.backend = newHTTPBackend(c.apiURL)
+	}
+
+	switch args[0] {
+	case "sub":
+		return c.sub(ctx, args[1:])
+	case "user":
+		return c.user(ctx, args[1:])
+	case "sum":
+		return c.sum(ctx, args[1:])
+	case "import":
+		return c.importFile(ctx, args[1:])
+	case "export":
+		return c.export(ctx, args[1:])
+	}
+	return errUsage
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// defaultEnvPath points -env at ./.env when there is one, so that -admin
+// works from the repository root and falls back to the environment elsewhere.
+func defaultEnvPath() string {
+	if _, err := os.Stat(".env"); err == nil {
+		return ".env"
+	}
+	return ""
+}
+
+// splitArgs separates positional arguments from flags, so that commands can be
+// written as "sub get ID -output json" as well as "sub get -output json ID".
+func (c *cli) splitArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	switch c.output {
+	case formatTable, formatJSON, formatCSV:
+		return positional, nil
+	}
+	fmt.Fprintf(flags.Output(), "unknown output format %q\n", c.output)
+	return nil, errUsage
+}
+
+// newFlagSet returns the flag set of a subcommand. -output is accepted after
+// the subcommand as well as before it.
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {}
+	flags.StringVar(&c.output, "output", c.output, "output format: table, json or csv")
+	return flags
+}
+
+// optional returns nil for an empty flag value, so that unset flags are left
+// out of patch requests.
+func optional(s string) *string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/pkg/client"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// subscriptionColumns use the JSON field names, so that exported CSV can be
+// imported again as is.
+var subscriptionColumns = []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "version"}
+
+func (c *cli) printSubscriptions(w io.Writer, subs []client.Subscription) error {
+	return printSubscriptions(w, c.output, subs)
+}
+
+func printSubscriptions(w io.Writer, format string, subs []client.Subscription) error {
+	if format == formatJSON {
+		return printJSON(w, subs)
+	}
+	rows := make([][]string, 0, len(subs))
+	for _, s := range subs {
+		end := ""
+		if s.EndDate != nil {
+			end = monthYear(*s.EndDate)
+		}
+		rows = append(rows, []string{
+			s.ID.String(),
+			s.Service,
+			strconv.FormatInt(s.PriceRUB, 10),
+			s.UserID.String(),
+			monthYear(s.StartDate),
+			end,
+			strconv.FormatInt(s.Version, 10),
+		})
+	}
+	return printRows(w, format, subscriptionColumns, rows)
+}
+
+func (c *cli) printCreated(w io.Writer, out *client.SubscriptionCreateResponse) error {
+	if c.output == formatJSON {
+		return printJSON(w, out)
+	}
+	return printRows(w, c.output, []string{"subscription_id"}, [][]string{{out.SubID}})
+}
+
+func (c *cli) printSum(w io.Writer, total int64) error {
+	if c.output == formatJSON {
+		return printJSON(w, client.SubscriptionsPriceSumResponse{PriceSum: total})
+	}
+	return printRows(w, c.output, []string{"total_sum"}, [][]string{{strconv.FormatInt(total, 10)}})
+}
+
+func (c *cli) printImported(w io.Writer, results []client.BatchOperationResult) error {
+	if c.output == formatJSON {
+		return printJSON(w, results)
+	}
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{strconv.Itoa(result.Index + 1), result.ID})
+	}
+	return printRows(w, c.output, []string{"row", "subscription_id"}, rows)
+}
+
+func printJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printRows(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == formatCSV {
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	writeTabbed(tw, header)
+	for _, row := range rows {
+		writeTabbed(tw, row)
+	}
+	return tw.Flush()
+}
+
+func writeTabbed(w io.Writer, cells []string) {
+	for i, cell := range cells {
+		if i > 0 {
+			fmt.Fprint(w, "\t")
+		}
+		fmt.Fprint(w, cell)
+	}
+	fmt.Fprintln(w)
+}
+
+// monthYear formats dates the way the API accepts them.
+func monthYear(t time.Time) string {
+	return t.Format("01-2006")
+}
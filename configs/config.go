@@ -1,42 +1,664 @@
 package configs
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/pkg/tracing"
 	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
 )
 
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+	DBDriverMemory   = "memory"
+)
+
+// GORM SQL log levels: info logs every statement, warn adds slow queries to
+// errors.
+const (
+	DBLogLevelSilent = "silent"
+	DBLogLevelError  = "error"
+	DBLogLevelWarn   = "warn"
+	DBLogLevelInfo   = "info"
+)
+
+// defaultConfigFile is read when it exists and no other file is given.
+const defaultConfigFile = "config.yaml"
+
 type Config struct {
-	DBUser     string
-	DBPassword string
-	DBName     string
-	DBHost     string
-	DBPort     string
-	AppPort    string
+	AppHost           string
+	AppPort           string
+	GRPCPort          string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+
+	DBDriver          string
+	SQLitePath        string
+	DBUser            string
+	DBPassword        string
+	DBName            string
+	DBHost            string
+	DBPort            string
+	DBSSLMode         string
+	DBSSLRootCert     string
+	DBSSLCert         string
+	DBSSLKey          string
+	DBReplicas        []string
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	DBConnectTimeout  time.Duration
+	DBLogLevel        string
+	AutoMigrate       bool
+
+	LogLevel      string
+	LogFormat     string
+	TraceExporter string
+
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	CORSMaxAge           time.Duration
+
+	RateLimitRPS    float64
+	RateLimitBurst  int
+	RateLimitRoutes map[string]RouteRateLimit
+	MaxBodyBytes    int
+
+	// APIKeys are the X-API-Key values clients may identify themselves
+	// with; callers without one are told apart by IP.
+	APIKeys []string
+
+	IdempotencyTTL time.Duration
+	RequireIfMatch bool
+
+	// LegacyRoutes serves the API without the /v1 prefix as well, marked
+	// deprecated; LegacySunset, if set, is announced as the date they go.
+	LegacyRoutes bool
+	LegacySunset time.Time
+
+	// EventsReplaySize is how many change events are kept for clients
+	// resuming GET /events with Last-Event-ID.
+	EventsReplaySize int
+	EventsHeartbeat  time.Duration
+
+	// sources records where each setting got its value, for Describe.
+	sources map[string]string
+}
+
+func defaults() *Config {
+	return &Config{
+		AppPort:           "8081",
+		GRPCPort:          "9090",
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 5 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+		ShutdownTimeout:   5 * time.Second,
+
+		DBDriver:          DBDriverPostgres,
+		SQLitePath:        "substracker.db",
+		DBUser:            "postgres",
+		DBPassword:        "postgres",
+		DBName:            "substracker_db",
+		DBHost:            "localhost",
+		DBPort:            "5432",
+		DBSSLMode:         "disable",
+		DBMaxOpenConns:    25,
+		DBMaxIdleConns:    10,
+		DBConnMaxLifetime: 30 * time.Minute,
+		DBConnMaxIdleTime: 5 * time.Minute,
+		DBConnectTimeout:  30 * time.Second,
+		DBLogLevel:        DBLogLevelWarn,
+		AutoMigrate:       true,
+
+		LogLevel:      "info",
+		LogFormat:     logger.FormatText,
+		TraceExporter: tracing.ExporterNone,
+
+		CORSAllowedMethods: []string{"GET", "POST", "PATCH", "DELETE"},
+		CORSAllowedHeaders: []string{"Content-Type", "If-Match", "Idempotency-Key", "X-Request-ID", "X-API-Key", "Last-Event-ID"},
+		CORSMaxAge:         10 * time.Minute,
+
+		RateLimitRPS:   10,
+		RateLimitBurst: 20,
+		RateLimitRoutes: map[string]RouteRateLimit{
+			"GET /healthz": {},
+			"GET /readyz":  {},
+			"GET /metrics": {},
+		},
+		MaxBodyBytes: 1 << 20,
+
+		IdempotencyTTL: 24 * time.Hour,
+
+		LegacyRoutes: true,
+
+		EventsReplaySize: 1000,
+		EventsHeartbeat:  15 * time.Second,
+	}
+}
+
+// RouteRateLimit overrides the rate limit for one route. A zero RPS leaves
+// the route unlimited.
+type RouteRateLimit struct {
+	RPS   float64
+	Burst int
+}
+
+// ListenAddr is the address of the HTTP server.
+func (c *Config) ListenAddr() string {
+	return net.JoinHostPort(c.AppHost, c.AppPort)
+}
+
+// ReplicaHostPort splits a db.replicas entry; the port defaults to the
+// primary's.
+func (c *Config) ReplicaHostPort(replica string) (host, port string, err error) {
+	host, port = replica, c.DBPort
+	if strings.Contains(replica, ":") {
+		if host, port, err = net.SplitHostPort(replica); err != nil {
+			return "", "", fmt.Errorf("replica %q: %w", replica, err)
+		}
+	}
+	if host == "" || !validPort(port) {
+		return "", "", fmt.Errorf("replica %q is not host[:port]", replica)
+	}
+	return host, port, nil
+}
+
+// setting describes one configuration value and every place it can be set
+// from. Keys are dotted YAML paths: "db.host" is host under db.
+type setting struct {
+	key    string
+	env    string
+	flag   string
+	usage  string
+	secret bool
+	isBool bool
+	set    func(string) error
+	get    func() string
 }
 
+func (c *Config) settings() []setting {
+	return []setting{
+		stringSetting("http.host", "APP_HOST", "host", "HTTP listen host, empty for all interfaces", &c.AppHost),
+		stringSetting("http.port", "APP_PORT", "port", "HTTP listen port", &c.AppPort),
+		durationSetting("http.read_timeout", "HTTP_READ_TIMEOUT", "read-timeout", "maximum time to read a request", &c.ReadTimeout),
+		durationSetting("http.read_header_timeout", "HTTP_READ_HEADER_TIMEOUT", "read-header-timeout", "maximum time to read request headers", &c.ReadHeaderTimeout),
+		durationSetting("http.write_timeout", "HTTP_WRITE_TIMEOUT", "write-timeout", "maximum time to write a response", &c.WriteTimeout),
+		durationSetting("http.idle_timeout", "HTTP_IDLE_TIMEOUT", "idle-timeout", "keep-alive idle timeout", &c.IdleTimeout),
+		durationSetting("http.shutdown_timeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "graceful shutdown timeout", &c.ShutdownTimeout),
+		stringSetting("grpc.port", "GRPC_PORT", "grpc-port", "gRPC listen port", &c.GRPCPort),
+
+		stringSetting("db.driver", "DB_DRIVER", "db-driver", "storage: postgres, sqlite or memory", &c.DBDriver),
+		stringSetting("db.sqlite_path", "SQLITE_PATH", "sqlite-path", "SQLite database file", &c.SQLitePath),
+		stringSetting("db.host", "POSTGRES_HOST", "db-host", "Postgres host", &c.DBHost),
+		stringSetting("db.port", "POSTGRES_PORT", "db-port", "Postgres port", &c.DBPort),
+		stringSetting("db.user", "POSTGRES_USER", "db-user", "Postgres user", &c.DBUser),
+		secret(stringSetting("db.password", "POSTGRES_PASSWORD", "db-password", "Postgres password", &c.DBPassword)),
+		stringSetting("db.name", "POSTGRES_DB", "db-name", "Postgres database", &c.DBName),
+		stringSetting("db.sslmode", "POSTGRES_SSLMODE", "db-sslmode", "Postgres sslmode: disable, allow, prefer, require, verify-ca or verify-full", &c.DBSSLMode),
+		stringSetting("db.sslrootcert", "POSTGRES_SSLROOTCERT", "db-sslrootcert", "CA certificate for verify-ca and verify-full", &c.DBSSLRootCert),
+		stringSetting("db.sslcert", "POSTGRES_SSLCERT", "db-sslcert", "client certificate", &c.DBSSLCert),
+		stringSetting("db.sslkey", "POSTGRES_SSLKEY", "db-sslkey", "client certificate key", &c.DBSSLKey),
+		listSetting("db.replicas", "DB_REPLICAS", "db-replicas", "comma-separated host[:port] read replicas for list, sum and analytics queries", &c.DBReplicas),
+		intSetting("db.max_open_conns", "DB_MAX_OPEN_CONNS", "db-max-open-conns", "maximum open connections, 0 for unlimited", &c.DBMaxOpenConns),
+		intSetting("db.max_idle_conns", "DB_MAX_IDLE_CONNS", "db-max-idle-conns", "maximum idle connections", &c.DBMaxIdleConns),
+		durationSetting("db.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "maximum connection lifetime", &c.DBConnMaxLifetime),
+		durationSetting("db.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", "db-conn-max-idle-time", "maximum connection idle time", &c.DBConnMaxIdleTime),
+		durationSetting("db.connect_timeout", "DB_CONNECT_TIMEOUT", "db-connect-timeout", "how long to retry connecting on startup, 0 for a single attempt", &c.DBConnectTimeout),
+		stringSetting("db.log_level", "DB_LOG_LEVEL", "db-log-level", "SQL log level: silent, error, warn or info", &c.DBLogLevel),
+		boolSetting("db.auto_migrate", "AUTO_MIGRATE", "auto-migrate", "apply pending migrations on startup; when false, refuse to start while any are pending", &c.AutoMigrate),
+
+		stringSetting("log.level", "LOG_LEVEL", "log-level", "log level: debug, info, warn or error", &c.LogLevel),
+		stringSetting("log.format", "LOG_FORMAT", "log-format", "log format: text or json", &c.LogFormat),
+		stringSetting("tracing.exporter", "TRACE_EXPORTER", "trace-exporter", "trace exporter: none, stdout or otlp", &c.TraceExporter),
+
+		listSetting("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "cors-allowed-origins", "comma-separated origins allowed by CORS, * for any; empty disables CORS", &c.CORSAllowedOrigins),
+		listSetting("cors.allowed_methods", "CORS_ALLOWED_METHODS", "cors-allowed-methods", "comma-separated methods allowed in CORS requests", &c.CORSAllowedMethods),
+		listSetting("cors.allowed_headers", "CORS_ALLOWED_HEADERS", "cors-allowed-headers", "comma-separated request headers allowed in CORS requests", &c.CORSAllowedHeaders),
+		boolSetting("cors.allow_credentials", "CORS_ALLOW_CREDENTIALS", "cors-allow-credentials", "allow cookies and auth headers in CORS requests", &c.CORSAllowCredentials),
+		durationSetting("cors.max_age", "CORS_MAX_AGE", "cors-max-age", "how long browsers may cache preflight results", &c.CORSMaxAge),
+
+		floatSetting("rate_limit.rps", "RATE_LIMIT_RPS", "rate-limit-rps", "requests per second per client, 0 disables rate limiting", &c.RateLimitRPS),
+		intSetting("rate_limit.burst", "RATE_LIMIT_BURST", "rate-limit-burst", "requests a client may make at once", &c.RateLimitBurst),
+		routeLimitsSetting("rate_limit.routes", "RATE_LIMIT_ROUTES", "rate-limit-routes", `comma-separated per-route limits "METHOD /pattern=RPS:BURST", RPS 0 for unlimited; merged with the defaults`, &c.RateLimitRoutes),
+		secret(listSetting("api_keys", "API_KEYS", "api-keys", "comma-separated API keys accepted in X-API-Key for rate limiting and idempotency keys", &c.APIKeys)),
+		intSetting("http.max_body_bytes", "MAX_BODY_BYTES", "max-body-bytes", "maximum request body size, 0 for unlimited", &c.MaxBodyBytes),
+
+		durationSetting("idempotency.ttl", "IDEMPOTENCY_TTL", "idempotency-ttl", "how long idempotency keys are kept", &c.IdempotencyTTL),
+		boolSetting("subscriptions.require_if_match", "REQUIRE_IF_MATCH", "require-if-match", "reject PATCH and DELETE without If-Match", &c.RequireIfMatch),
+
+		boolSetting("api.legacy_routes", "LEGACY_ROUTES", "legacy-routes", "also serve the API without the /v1 prefix, with Deprecation headers", &c.LegacyRoutes),
+		dateSetting("api.legacy_sunset", "LEGACY_ROUTES_SUNSET", "legacy-routes-sunset", "YYYY-MM-DD sent in the Sunset header of unversioned routes, empty for none", &c.LegacySunset),
+
+		intSetting("events.replay_size", "EVENTS_REPLAY_SIZE", "events-replay-size", "change events kept for clients resuming with Last-Event-ID", &c.EventsReplaySize),
+		durationSetting("events.heartbeat", "EVENTS_HEARTBEAT", "events-heartbeat", "interval of keep-alive comments on idle event streams", &c.EventsHeartbeat),
+	}
+}
+
+// LoadConfig reads the configuration without command-line flags and exits
+// on errors. It is meant for tools that share the server's env file.
 func LoadConfig(envPath string) *Config {
+	cfg, _, err := Load(envPath, nil)
+	if err != nil {
+		logger.Log.Fatalf("invalid configuration: %v", err)
+	}
+	return cfg
+}
+
+// Load builds the configuration from, in increasing priority: defaults, a
+// YAML file, the environment (including envPath, if it exists) and the
+// command-line flags in args. The YAML file is given with -config or
+// CONFIG_FILE, or is config.yaml when present. It returns the arguments left
+// after the flags. With -h it returns flag.ErrHelp after printing usage.
+func Load(envPath string, args []string) (*Config, []string, error) {
 	if envPath != "" {
-		if err := godotenv.Load(envPath); err != nil {
-			logger.Log.Fatalf("warning: could not load env file %s: %v", envPath, err)
+		if err := godotenv.Load(envPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, nil, fmt.Errorf("env file %s: %w", envPath, err)
 		}
 	}
 
-	cfg := &Config{
-		DBUser:     getEnv("POSTGRES_USER", "postgres"),
-		DBPassword: getEnv("POSTGRES_PASSWORD", "postgres"),
-		DBName:     getEnv("POSTGRES_DB", "substracker_db"),
-		DBHost:     getEnv("POSTGRES_HOST", "localhost"),
-		DBPort:     getEnv("POSTGRES_PORT", "5432"),
-		AppPort:    getEnv("APP_PORT", "8080"),
+	cfg := defaults()
+	cfg.sources = make(map[string]string)
+	settings := cfg.settings()
+
+	flags := flag.NewFlagSet("substracker", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML config file (env CONFIG_FILE, default config.yaml if present)")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		s := s
+		flags.Var(&rawFlag{isBool: s.isBool, value: s.get(), set: func(v string) {
+			flagValues[s.key] = v
+		}}, s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "usage: %s [flags] [command]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	path, required := *configFile, true
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		path, required = defaultConfigFile, false
+	}
+	fileValues, err := readYAML(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		fileValues, err = nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	var errs []error
+	known := make(map[string]bool)
+	for _, s := range settings {
+		known[s.key] = true
+		if v, ok := fileValues[s.key]; ok {
+			errs = append(errs, cfg.apply(s, v, "file "+path))
+		}
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			errs = append(errs, cfg.apply(s, v, "env "+s.env))
+		}
+		if v, ok := flagValues[s.key]; ok {
+			errs = append(errs, cfg.apply(s, v, "flag -"+s.flag))
+		}
+	}
+	for key := range fileValues {
+		if !known[key] {
+			errs = append(errs, fmt.Errorf("file %s: unknown setting %s", path, key))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, flags.Args(), nil
+}
+
+func (c *Config) apply(s setting, value, source string) error {
+	if err := s.set(value); err != nil {
+		return fmt.Errorf("%s: %s: %w", source, s.key, err)
+	}
+	c.sources[s.key] = source
+	return nil
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(oneOf(c.DBDriver, DBDriverPostgres, DBDriverSQLite, DBDriverMemory),
+		"db.driver %q must be %s, %s or %s", c.DBDriver, DBDriverPostgres, DBDriverSQLite, DBDriverMemory)
+	check(validPort(c.AppPort), "http.port %q is not a valid port", c.AppPort)
+	check(validPort(c.GRPCPort), "grpc.port %q is not a valid port", c.GRPCPort)
+	check(c.AppPort != c.GRPCPort, "http.port and grpc.port must differ")
+	for name, d := range map[string]time.Duration{
+		"http.read_timeout":        c.ReadTimeout,
+		"http.read_header_timeout": c.ReadHeaderTimeout,
+		"http.write_timeout":       c.WriteTimeout,
+		"http.idle_timeout":        c.IdleTimeout,
+		"http.shutdown_timeout":    c.ShutdownTimeout,
+		"idempotency.ttl":          c.IdempotencyTTL,
+		"events.heartbeat":         c.EventsHeartbeat,
+	} {
+		check(d > 0, "%s must be positive", name)
+	}
+
+	if c.DBDriver == DBDriverPostgres {
+		check(validPort(c.DBPort), "db.port %q is not a valid port", c.DBPort)
+		check(c.DBHost != "", "db.host must be set")
+		check(c.DBName != "", "db.name must be set")
+		check(oneOf(c.DBSSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+			"db.sslmode %q must be disable, allow, prefer, require, verify-ca or verify-full", c.DBSSLMode)
+		check((c.DBSSLCert == "") == (c.DBSSLKey == ""), "db.sslcert and db.sslkey must be set together")
+		for key, path := range map[string]string{"db.sslrootcert": c.DBSSLRootCert, "db.sslcert": c.DBSSLCert, "db.sslkey": c.DBSSLKey} {
+			if path != "" {
+				_, err := os.Stat(path)
+				check(err == nil, "%s: %v", key, err)
+			}
+		}
+		for _, replica := range c.DBReplicas {
+			_, _, err := c.ReplicaHostPort(replica)
+			check(err == nil, "db.replicas: %v", err)
+		}
+	}
+	if c.DBDriver == DBDriverSQLite {
+		check(c.SQLitePath != "", "db.sqlite_path must be set")
+	}
+	check(c.DBMaxOpenConns >= 0, "db.max_open_conns must not be negative")
+	check(c.DBMaxIdleConns >= 0, "db.max_idle_conns must not be negative")
+	check(c.DBMaxOpenConns == 0 || c.DBMaxIdleConns <= c.DBMaxOpenConns, "db.max_idle_conns must not exceed db.max_open_conns")
+	check(c.DBConnMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+	check(c.DBConnMaxIdleTime >= 0, "db.conn_max_idle_time must not be negative")
+	check(c.DBConnectTimeout >= 0, "db.connect_timeout must not be negative")
+	check(oneOf(c.DBLogLevel, DBLogLevelSilent, DBLogLevelError, DBLogLevelWarn, DBLogLevelInfo),
+		"db.log_level %q must be %s, %s, %s or %s", c.DBLogLevel, DBLogLevelSilent, DBLogLevelError, DBLogLevelWarn, DBLogLevelInfo)
+
+	_, err := logrus.ParseLevel(c.LogLevel)
+	check(err == nil, "log.level %q must be debug, info, warn or error", c.LogLevel)
+	check(oneOf(c.LogFormat, logger.FormatText, logger.FormatJSON), "log.format %q must be %s or %s", c.LogFormat, logger.FormatText, logger.FormatJSON)
+	check(oneOf(c.TraceExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP),
+		"tracing.exporter %q must be %s, %s or %s", c.TraceExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)
+
+	for _, origin := range c.CORSAllowedOrigins {
+		check(origin == "*" || validOrigin(origin), "cors.allowed_origins: %q is not * or scheme://host[:port]", origin)
+	}
+	check(len(c.CORSAllowedOrigins) == 0 || len(c.CORSAllowedMethods) > 0, "cors.allowed_methods must not be empty when CORS is enabled")
+	for _, method := range c.CORSAllowedMethods {
+		check(validToken(method) && method == strings.ToUpper(method), "cors.allowed_methods: %q is not an upper-case HTTP method", method)
+	}
+	for _, header := range c.CORSAllowedHeaders {
+		check(validToken(header), "cors.allowed_headers: %q is not a header name", header)
+	}
+	check(!c.CORSAllowCredentials || !oneOf("*", c.CORSAllowedOrigins...), "cors.allow_credentials cannot be used with origin *")
+	check(c.CORSMaxAge >= 0, "cors.max_age must not be negative")
+
+	check(c.RateLimitRPS >= 0, "rate_limit.rps must not be negative")
+	check(c.RateLimitRPS == 0 || c.RateLimitBurst >= 1, "rate_limit.burst must be at least 1")
+	for pattern, limit := range c.RateLimitRoutes {
+		check(limit.RPS >= 0, "rate_limit.routes: %s: rps must not be negative", pattern)
+		check(limit.RPS == 0 || limit.Burst >= 1, "rate_limit.routes: %s: burst must be at least 1", pattern)
+	}
+	check(c.MaxBodyBytes >= 0, "http.max_body_bytes must not be negative")
+	check(c.EventsReplaySize >= 0, "events.replay_size must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// Describe lists the effective settings as "key=value (source)", with
+// secrets redacted.
+func (c *Config) Describe() []string {
+	var lines []string
+	for _, s := range c.settings() {
+		value := s.get()
+		if s.secret && value != "" {
+			value = "[REDACTED]"
+		}
+		source := c.sources[s.key]
+		if source == "" {
+			source = "default"
+		}
+		lines = append(lines, fmt.Sprintf("%s=%s (%s)", s.key, value, source))
+	}
+	return lines
+}
+
+func oneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n < 65536
+}
+
+// validToken reports whether s is an RFC 9110 token, the syntax of method
+// and header names.
+func validToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func validOrigin(origin string) bool {
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/")
+}
+
+// rawFlag only records the flag's text; flags are applied after the file and
+// the environment so that they take precedence.
+type rawFlag struct {
+	isBool bool
+	value  string
+	set    func(string)
+}
+
+func (f *rawFlag) String() string   { return f.value }
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *rawFlag) Set(v string) error {
+	f.value = v
+	f.set(v)
+	return nil
+}
+
+func secret(s setting) setting {
+	s.secret = true
+	return s
+}
+
+func stringSetting(key, env, flagName, usage string, p *string) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error { *p = v; return nil },
+		get: func() string { return *p },
+	}
+}
+
+func intSetting(key, env, flagName, usage string, p *int) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid integer %q", v)
+			}
+			*p = n
+			return nil
+		},
+		get: func() string { return strconv.Itoa(*p) },
+	}
+}
+
+func floatSetting(key, env, flagName, usage string, p *float64) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q", v)
+			}
+			*p = f
+			return nil
+		},
+		get: func() string { return strconv.FormatFloat(*p, 'g', -1, 64) },
+	}
+}
+
+func boolSetting(key, env, flagName, usage string, p *bool) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage, isBool: true,
+		set: func(v string) error {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid boolean %q", v)
+			}
+			*p = b
+			return nil
+		},
+		get: func() string { return strconv.FormatBool(*p) },
+	}
+}
+
+func durationSetting(key, env, flagName, usage string, p *time.Duration) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid duration %q", v)
+			}
+			*p = d
+			return nil
+		},
+		get: func() string { return p.String() },
+	}
+}
+
+// dateSetting reads a YYYY-MM-DD date in UTC; empty is the zero time.
+func dateSetting(key, env, flagName, usage string, p *time.Time) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			if v == "" {
+				*p = time.Time{}
+				return nil
+			}
+			t, err := time.Parse(time.DateOnly, v)
+			if err != nil {
+				return fmt.Errorf("invalid date %q, want YYYY-MM-DD", v)
+			}
+			*p = t
+			return nil
+		},
+		get: func() string {
+			if p.IsZero() {
+				return ""
+			}
+			return p.Format(time.DateOnly)
+		},
+	}
+}
+
+// routeLimitsSetting reads comma-separated "METHOD /pattern=RPS:BURST"
+// entries; the burst may be left out when RPS is 0.
+func routeLimitsSetting(key, env, flagName, usage string, p *map[string]RouteRateLimit) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			// Routes are merged into those already set, so that an override
+			// keeps the default exemptions unless it names them.
+			limits := maps.Clone(*p)
+			if limits == nil {
+				limits = make(map[string]RouteRateLimit)
+			}
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				i := strings.LastIndex(item, "=")
+				if i <= 0 {
+					return fmt.Errorf("invalid route limit %q, want METHOD /pattern=RPS:BURST", item)
+				}
+				pattern, value := strings.TrimSpace(item[:i]), item[i+1:]
+				rps, burst, hasBurst := strings.Cut(value, ":")
+				var limit RouteRateLimit
+				var err error
+				if limit.RPS, err = strconv.ParseFloat(rps, 64); err != nil {
+					return fmt.Errorf("invalid route limit %q: rps %q is not a number", item, rps)
+				}
+				if hasBurst {
+					if limit.Burst, err = strconv.Atoi(burst); err != nil {
+						return fmt.Errorf("invalid route limit %q: burst %q is not an integer", item, burst)
+					}
+				}
+				limits[pattern] = limit
+			}
+			*p = limits
+			return nil
+		},
+		get: func() string {
+			patterns := make([]string, 0, len(*p))
+			for pattern := range *p {
+				patterns = append(patterns, pattern)
+			}
+			sort.Strings(patterns)
+			items := make([]string, len(patterns))
+			for i, pattern := range patterns {
+				limit := (*p)[pattern]
+				items[i] = fmt.Sprintf("%s=%s:%d", pattern, strconv.FormatFloat(limit.RPS, 'g', -1, 64), limit.Burst)
+			}
+			return strings.Join(items, ",")
+		},
 	}
-	return cfg
 }
 
-func getEnv(key, def string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
+// listSetting reads comma-separated values; YAML lists are joined with
+// commas before they get here.
+func listSetting(key, env, flagName, usage string, p *[]string) setting {
+	return setting{key: key, env: env, flag: flagName, usage: usage,
+		set: func(v string) error {
+			*p = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*p = append(*p, item)
+				}
+			}
+			return nil
+		},
+		get: func() string { return strings.Join(*p, ",") },
 	}
-	return def
 }
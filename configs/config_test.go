@@ -0,0 +1,171 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+http:
+  port: 7000
+  write_timeout: 45s
+db:
+  host: file-host
+  user: file-user
+cors:
+  allowed_origins:
+    - https://a.example
+    - https://b.example
+api:
+  legacy_sunset: 2027-06-30
+`)
+	t.Setenv("POSTGRES_HOST", "env-host")
+	t.Setenv("POSTGRES_USER", "env-user")
+
+	cfg, rest, err := Load("", []string{"-config", path, "-db-host", "flag-host", "-auto-migrate=false", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if cfg.AppPort != "7000" || cfg.WriteTimeout != 45*time.Second {
+		t.Fatalf("file values not applied: port=%s write_timeout=%s", cfg.AppPort, cfg.WriteTimeout)
+	}
+	if cfg.DBUser != "env-user" {
+		t.Fatalf("DBUser = %q, want env to override the file", cfg.DBUser)
+	}
+	if cfg.DBHost != "flag-host" {
+		t.Fatalf("DBHost = %q, want the flag to override env and file", cfg.DBHost)
+	}
+	if cfg.AutoMigrate {
+		t.Fatal("AutoMigrate = true, want -auto-migrate=false to apply")
+	}
+	if cfg.ReadTimeout != 15*time.Second {
+		t.Fatalf("ReadTimeout = %s, want the default", cfg.ReadTimeout)
+	}
+	if strings.Join(cfg.CORSAllowedOrigins, " ") != "https://a.example https://b.example" {
+		t.Fatalf("CORSAllowedOrigins = %v", cfg.CORSAllowedOrigins)
+	}
+	if !cfg.LegacyRoutes || !cfg.LegacySunset.Equal(time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("LegacyRoutes = %t, LegacySunset = %s", cfg.LegacyRoutes, cfg.LegacySunset)
+	}
+	if strings.Join(rest, " ") != "migrate up" {
+		t.Fatalf("remaining args = %v, want [migrate up]", rest)
+	}
+}
+
+func TestLoadWithoutFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg, _, err := Load(filepath.Join(t.TempDir(), ".env"), nil)
+	if err != nil {
+		t.Fatalf("Load without .env and config.yaml: %v", err)
+	}
+	if cfg.ListenAddr() != ":8081" {
+		t.Fatalf("ListenAddr = %q, want :8081", cfg.ListenAddr())
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	path := writeFile(t, "config.yaml", "db:\n  hots: typo\n")
+	if _, _, err := Load("", []string{"-config", path}); err == nil || !strings.Contains(err.Error(), "unknown setting db.hots") {
+		t.Fatalf("Load with unknown key: err = %v", err)
+	}
+
+	_, _, err := Load("", []string{
+		"-db-driver", "oracle",
+		"-port", "0",
+		"-log-level", "loud",
+		"-db-max-idle-conns", "50",
+		"-cors-allowed-origins", "example.com",
+		"-cors-allowed-methods", "get",
+		"-cors-allowed-headers", "X Bad",
+		"-events-replay-size", "-1",
+	})
+	if err == nil {
+		t.Fatal("Load accepted invalid settings")
+	}
+	for _, want := range []string{"db.driver", "http.port", "log.level", "db.max_idle_conns", "cors.allowed_origins", "cors.allowed_methods", "cors.allowed_headers", "events.replay_size"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	if _, _, err := Load("", []string{"-read-timeout", "soon"}); err == nil {
+		t.Fatal("Load accepted an invalid duration")
+	}
+	if _, _, err := Load("", []string{"-legacy-routes-sunset", "30.06.2027"}); err == nil {
+		t.Fatal("Load accepted an invalid date")
+	}
+}
+
+func TestDescribeRedactsSecrets(t *testing.T) {
+	t.Setenv("POSTGRES_PASSWORD", "hunter2")
+	cfg, _, err := Load("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	described := strings.Join(cfg.Describe(), "\n")
+	if strings.Contains(described, "hunter2") {
+		t.Fatal("Describe leaks the database password")
+	}
+	if !strings.Contains(described, "db.password=[REDACTED] (env POSTGRES_PASSWORD)") {
+		t.Fatalf("Describe output lacks the redacted password:\n%s", described)
+	}
+}
+
+func TestRateLimitRoutesMerge(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+rate_limit:
+  routes:
+    - POST /v1/subscriptions=2:5
+`)
+	t.Setenv("RATE_LIMIT_ROUTES", "GET /metrics=1:3")
+
+	cfg, _, err := Load("", []string{"-config", path, "-rate-limit-routes", "POST /v1/subscriptions/batch=0.2:2"})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := map[string]RouteRateLimit{
+		"GET /healthz":                 {},
+		"GET /readyz":                  {},
+		"GET /metrics":                 {RPS: 1, Burst: 3},
+		"POST /v1/subscriptions":       {RPS: 2, Burst: 5},
+		"POST /v1/subscriptions/batch": {RPS: 0.2, Burst: 2},
+	}
+	if len(cfg.RateLimitRoutes) != len(want) {
+		t.Fatalf("RateLimitRoutes = %v, want %v", cfg.RateLimitRoutes, want)
+	}
+	for pattern, limit := range want {
+		if got, ok := cfg.RateLimitRoutes[pattern]; !ok || got != limit {
+			t.Errorf("%s = %+v, want %+v", pattern, got, limit)
+		}
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	path := writeFile(t, "config.yaml", "api_keys: [key-a, key-b]\n")
+	cfg, _, err := Load("", []string{"-config", path})
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if strings.Join(cfg.APIKeys, " ") != "key-a key-b" {
+		t.Fatalf("APIKeys = %v", cfg.APIKeys)
+	}
+	for _, line := range cfg.Describe() {
+		if strings.Contains(line, "key-a") {
+			t.Fatalf("Describe leaks an API key: %s", line)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// readYAML reads a config file into dotted keys: nested mappings are joined
+// with "." and lists with ",", so that every value can go through the same
+// parsers as environment variables.
+func readYAML(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	if err := flattenYAML("", doc, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func flattenYAML(prefix string, node map[string]any, out map[string]string) error {
+	for k, v := range node {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flattenYAML(key, v, out); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, 0, len(v))
+			for _, item := range v {
+				if _, nested := item.(map[string]any); nested {
+					return fmt.Errorf("%s: lists of mappings are not supported", key)
+				}
+				items = append(items, fmt.Sprint(item))
+			}
+			out[key] = strings.Join(items, ",")
+		case nil:
+			out[key] = ""
+		case time.Time:
+			// YAML reads unquoted dates as timestamps; give dates back
+			// the form they were written in.
+			if v.Equal(v.Truncate(24 * time.Hour)) {
+				out[key] = v.Format(time.DateOnly)
+			} else {
+				out[key] = v.Format(time.RFC3339)
+			}
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+	return nil
+}
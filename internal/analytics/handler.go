@@ -0,0 +1,141 @@
+package analytics
+
+import (
+	"net/http"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+)
+
+const (
+	ErrInvalidUserUUID     = "USER UUID IS INVALID"
+	ErrInvalidStartDate    = "START DATE IS INVALID"
+	ErrInvalidEndDate      = "END DATE IS INVALID"
+	ErrInvalidDateInterval = "START DATE MUST BE BEFORE OR EQUAL TO END DATE"
+	ErrRangeTooLong        = "DATE RANGE IS TOO LONG"
+	ErrMissingParameter    = "MISSING QUERY PARAMETER"
+	ErrInvalidParameter    = "QUERY PARAMETER IS INVALID"
+	ErrFetchAnalytics      = "FAILED TO FETCH ANALYTICS"
+)
+
+// Problem codes are shared with the subscription endpoints for the same
+// problems.
+const (
+	ProblemInvalidUserID    = res.ProblemInvalidUserID
+	ProblemInvalidStartDate = res.ProblemInvalidStartDate
+	ProblemInvalidEndDate   = res.ProblemInvalidEndDate
+	ProblemInvalidDateRange = res.ProblemInvalidDateRange
+	ProblemRangeTooLong     = "date_range_too_long"
+	ProblemMissingParameter = res.ProblemMissingParameter
+	ProblemInvalidParameter = res.ProblemInvalidParameter
+)
+
+const (
+	defaultTopServicesLimit = 10
+	maxTopServicesLimit     = 100
+)
+
+type AnalyticsHandlerDeps struct {
+	Repository *AnalyticsRepository
+}
+
+type AnalyticsHandler struct {
+	Repository *AnalyticsRepository
+}
+
+func NewAnalyticsHandler(router apiversion.Router, deps *AnalyticsHandlerDeps) {
+	handler := AnalyticsHandler{Repository: deps.Repository}
+	router.HandleFunc("GET /analytics/trends", handler.GetTrends())
+	router.HandleFunc("GET /analytics/top-services", handler.GetTopServices())
+	router.HandleFunc("GET /analytics/churn", handler.GetChurn())
+}
+
+func (handler *AnalyticsHandler) GetTrends() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, filterErr := parseFilter(r.URL.Query())
+		if filter == nil {
+			logger.FromRequest(r).Warnf("GetTrends bad query query=%s err=%s", r.URL.RawQuery, filterErr.Message)
+			res.WriteProblem(w, r, http.StatusBadRequest, filterErr.Code, filterErr.Message)
+			return
+		}
+
+		rows, err := handler.Repository.MonthlyTrends(r.Context(), filter)
+		if err != nil {
+			logger.FromRequest(r).Errorf("GetTrends db error: %v", err)
+			res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrFetchAnalytics)
+			return
+		}
+
+		out := TrendsResponse{Months: make([]TrendPoint, 0, len(rows))}
+		for _, row := range rows {
+			out.Months = append(out.Months, TrendPoint{
+				Month:         formatMonthYear(row.Month),
+				Total:         row.Total,
+				Delta:         row.Delta,
+				PercentChange: row.PercentChange,
+			})
+		}
+		res.JsonDump(w, out, http.StatusOK)
+	}
+}
+
+func (handler *AnalyticsHandler) GetTopServices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter, filterErr := parseFilter(q)
+		if filter == nil {
+			logger.FromRequest(r).Warnf("GetTopServices bad query query=%s err=%s", r.URL.RawQuery, filterErr.Message)
+			res.WriteProblem(w, r, http.StatusBadRequest, filterErr.Code, filterErr.Message)
+			return
+		}
+		limit, ok := parseLimit(q, defaultTopServicesLimit, maxTopServicesLimit)
+		if !ok {
+			logger.FromRequest(r).Warnf("GetTopServices invalid limit limit=%s", q.Get("limit"))
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, ErrInvalidParameter)
+			return
+		}
+
+		rows, err := handler.Repository.TopServices(r.Context(), filter, limit)
+		if err != nil {
+			logger.FromRequest(r).Errorf("GetTopServices db error: %v", err)
+			res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrFetchAnalytics)
+			return
+		}
+
+		out := TopServicesResponse{Services: make([]TopService, 0, len(rows))}
+		for _, row := range rows {
+			out.Services = append(out.Services, TopService(row))
+		}
+		res.JsonDump(w, out, http.StatusOK)
+	}
+}
+
+func (handler *AnalyticsHandler) GetChurn() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, filterErr := parseFilter(r.URL.Query())
+		if filter == nil {
+			logger.FromRequest(r).Warnf("GetChurn bad query query=%s err=%s", r.URL.RawQuery, filterErr.Message)
+			res.WriteProblem(w, r, http.StatusBadRequest, filterErr.Code, filterErr.Message)
+			return
+		}
+
+		rows, err := handler.Repository.Churn(r.Context(), filter)
+		if err != nil {
+			logger.FromRequest(r).Errorf("GetChurn db error: %v", err)
+			res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrFetchAnalytics)
+			return
+		}
+
+		out := ChurnResponse{Months: make([]ChurnPoint, 0, len(rows))}
+		for _, row := range rows {
+			out.Months = append(out.Months, ChurnPoint{
+				Month:   formatMonthYear(row.Month),
+				Started: row.Started,
+				Ended:   row.Ended,
+				Net:     row.Started - row.Ended,
+			})
+		}
+		res.JsonDump(w, out, http.StatusOK)
+	}
+}
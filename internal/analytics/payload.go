@@ -0,0 +1,34 @@
+package analytics
+
+type TrendPoint struct {
+	Month         string   `json:"month"`
+	Total         int64    `json:"total"`
+	Delta         *int64   `json:"delta"`
+	PercentChange *float64 `json:"percent_change"`
+}
+
+type TrendsResponse struct {
+	Months []TrendPoint `json:"months"`
+}
+
+type TopService struct {
+	Service       string `json:"service_name"`
+	Total         int64  `json:"total"`
+	Subscriptions int64  `json:"subscriptions"`
+	Users         int64  `json:"users"`
+}
+
+type TopServicesResponse struct {
+	Services []TopService `json:"services"`
+}
+
+type ChurnPoint struct {
+	Month   string `json:"month"`
+	Started int64  `json:"started"`
+	Ended   int64  `json:"ended"`
+	Net     int64  `json:"net"`
+}
+
+type ChurnResponse struct {
+	Months []ChurnPoint `json:"months"`
+}
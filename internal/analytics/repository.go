@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"gorm.io/gorm"
+)
+
+// AnalyticsRepository runs the analytics queries. They rely on Postgres
+// generate_series, so the endpoints are only served with the postgres driver,
+// and repository_test.go only runs against the database named by
+// SUBS_TRACKER_TEST_POSTGRES_DSN.
+type AnalyticsRepository struct {
+	db *gorm.DB
+}
+
+func NewAnalyticsRepository(db *gorm.DB) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+type MonthlyTotal struct {
+	Month         time.Time
+	Total         int64
+	Delta         *int64
+	PercentChange *float64
+}
+
+type ServiceTotal struct {
+	Service       string
+	Total         int64
+	Subscriptions int64
+	Users         int64
+}
+
+type MonthlyChurn struct {
+	Month   time.Time
+	Started int64
+	Ended   int64
+}
+
+// subscriptionFilter renders the optional user and service filters as extra
+// conditions on the subscriptions alias "s".
+func subscriptionFilter(filter *Filter) (string, []any) {
+	var conds []string
+	var args []any
+	if filter.UserID != nil {
+		conds = append(conds, "s.user_id = ?")
+		args = append(args, *filter.UserID)
+	}
+	if filter.Service != nil && *filter.Service != "" {
+		conds = append(conds, "s.service = ?")
+		args = append(args, *filter.Service)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(conds, " AND "), args
+}
+
+// MonthlyTrends returns the total monthly spend for every month in the range,
+// together with the change against the previous month. A subscription counts
+// towards a month when it started on or before it and has not ended before it.
+func (repo *AnalyticsRepository) MonthlyTrends(ctx context.Context, filter *Filter) ([]MonthlyTotal, error) {
+	cond, condArgs := subscriptionFilter(filter)
+	args := append([]any{filter.Start, filter.End}, condArgs...)
+
+	var rows []MonthlyTotal
+	err := db.FromReplica(repo.db.WithContext(ctx)).Raw(`
+		WITH months AS (
+			SELECT generate_series(CAST(? AS timestamp), CAST(? AS timestamp), interval '1 month') AS month
+		),
+		totals AS (
+			SELECT m.month, COALESCE(SUM(s.price_rub), 0) AS total
+			FROM months m
+			LEFT JOIN subscriptions s
+				ON s.start_date <= m.month
+				AND (s.end_date IS NULL OR s.end_date >= m.month)`+cond+`
+			GROUP BY m.month
+		)
+		SELECT
+			month,
+			total,
+			total - LAG(total) OVER (ORDER BY month) AS delta,
+			ROUND(
+				(total - LAG(total) OVER (ORDER BY month)) * 100.0
+				/ NULLIF(LAG(total) OVER (ORDER BY month), 0),
+				2
+			)::float8 AS percent_change
+		FROM totals
+		ORDER BY month`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// TopServices ranks services by their total spend over the range. Every month
+// a subscription is active adds its monthly price to the service total.
+func (repo *AnalyticsRepository) TopServices(ctx context.Context, filter *Filter, limit int) ([]ServiceTotal, error) {
+	cond, condArgs := subscriptionFilter(filter)
+	args := append([]any{filter.Start, filter.End}, condArgs...)
+	args = append(args, limit)
+
+	var rows []ServiceTotal
+	err := db.FromReplica(repo.db.WithContext(ctx)).Raw(`
+		SELECT
+			s.service AS service,
+			SUM(s.price_rub) AS total,
+			COUNT(DISTINCT s.id) AS subscriptions,
+			COUNT(DISTINCT s.user_id) AS users
+		FROM generate_series(CAST(? AS timestamp), CAST(? AS timestamp), interval '1 month') AS m(month)
+		JOIN subscriptions s
+			ON s.start_date <= m.month
+			AND (s.end_date IS NULL OR s.end_date >= m.month)`+cond+`
+		GROUP BY s.service
+		ORDER BY total DESC, service ASC
+		LIMIT ?`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Churn counts, for every month in the range, how many subscriptions started
+// in that month and how many had it as their last month.
+func (repo *AnalyticsRepository) Churn(ctx context.Context, filter *Filter) ([]MonthlyChurn, error) {
+	cond, condArgs := subscriptionFilter(filter)
+	args := append([]any{filter.Start, filter.End}, condArgs...)
+
+	var rows []MonthlyChurn
+	err := db.FromReplica(repo.db.WithContext(ctx)).Raw(`
+		WITH months AS (
+			SELECT generate_series(CAST(? AS timestamp), CAST(? AS timestamp), interval '1 month') AS month
+		)
+		SELECT
+			m.month,
+			COUNT(s.id) FILTER (WHERE date_trunc('month', s.start_date) = m.month) AS started,
+			COUNT(s.id) FILTER (WHERE date_trunc('month', s.end_date) = m.month) AS ended
+		FROM months m
+		LEFT JOIN subscriptions s
+			ON (date_trunc('month', s.start_date) = m.month OR date_trunc('month', s.end_date) = m.month)`+cond+`
+		GROUP BY m.month
+		ORDER BY m.month`, args...).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
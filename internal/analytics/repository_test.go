@@ -0,0 +1,233 @@
+package analytics_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/analytics"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresDSNEnv points the tests at a real Postgres, since the analytics
+// queries use generate_series. They are skipped when it is unset. The
+// database is wiped.
+const postgresDSNEnv = "SUBS_TRACKER_TEST_POSTGRES_DSN"
+
+func month(s string) time.Time {
+	t, err := time.Parse("01-2006", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// newRepository returns a repository over a Postgres database holding only
+// subs.
+func newRepository(t *testing.T, subs ...*models.Subscription) *analytics.AnalyticsRepository {
+	t.Helper()
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	if err := migrations.RunMigrations(gormDB); err != nil {
+		t.Fatalf("migrate postgres: %v", err)
+	}
+	if err := gormDB.Exec("TRUNCATE subscriptions, subscription_changes").Error; err != nil {
+		t.Fatalf("truncate: %v", err)
+	}
+	for _, s := range subs {
+		if err := gormDB.Create(s).Error; err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	return analytics.NewAnalyticsRepository(gormDB)
+}
+
+func newSub(userID uuid.UUID, service string, price int64, start, end string) *models.Subscription {
+	s := &models.Subscription{
+		ID:        uuid.New(),
+		Service:   service,
+		PriceRUB:  price,
+		UserID:    userID,
+		StartDate: month(start),
+		Version:   1,
+	}
+	if end != "" {
+		endDate := month(end)
+		s.EndDate = &endDate
+	}
+	return s
+}
+
+// fixture spans 01-2025..04-2025: Netflix runs from 02-2025 on, Yandex only
+// in 03-2025, and Spotify started before the range and never ends.
+func fixture() (alice, bob uuid.UUID, subs []*models.Subscription) {
+	alice, bob = uuid.New(), uuid.New()
+	return alice, bob, []*models.Subscription{
+		newSub(alice, "Netflix", 100, "02-2025", ""),
+		newSub(alice, "Yandex", 50, "03-2025", "03-2025"),
+		newSub(bob, "Spotify", 30, "01-2024", ""),
+	}
+}
+
+func TestMonthlyTrends(t *testing.T) {
+	alice, _, subs := fixture()
+	repo := newRepository(t, subs...)
+	filter := &analytics.Filter{Start: month("01-2025"), End: month("04-2025"), UserID: &alice}
+
+	rows, err := repo.MonthlyTrends(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("MonthlyTrends: %v", err)
+	}
+	want := []struct {
+		month   string
+		total   int64
+		delta   *int64
+		percent *float64
+	}{
+		{"01-2025", 0, nil, nil},
+		// The previous month is 0, so there is no percent change.
+		{"02-2025", 100, ptr[int64](100), nil},
+		{"03-2025", 150, ptr[int64](50), ptr(50.0)},
+		{"04-2025", 100, ptr[int64](-50), ptr(-33.33)},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d months, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		row := rows[i]
+		if !row.Month.Equal(month(w.month)) || row.Total != w.total {
+			t.Errorf("month %d = %v total %d, want %s total %d", i, row.Month, row.Total, w.month, w.total)
+		}
+		if !equalPtr(row.Delta, w.delta) {
+			t.Errorf("%s delta = %v, want %v", w.month, deref(row.Delta), deref(w.delta))
+		}
+		if !equalPtr(row.PercentChange, w.percent) {
+			t.Errorf("%s percent change = %v, want %v", w.month, deref(row.PercentChange), deref(w.percent))
+		}
+	}
+
+	// Without the user filter, bob's open-ended Spotify adds 30 to every
+	// month.
+	filter.UserID = nil
+	rows, err = repo.MonthlyTrends(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("MonthlyTrends: %v", err)
+	}
+	for i, total := range []int64{30, 130, 180, 130} {
+		if rows[i].Total != total {
+			t.Errorf("unfiltered month %d total = %d, want %d", i, rows[i].Total, total)
+		}
+	}
+	if rows[1].PercentChange == nil || *rows[1].PercentChange != 333.33 {
+		t.Errorf("unfiltered 02-2025 percent change = %v, want 333.33", deref(rows[1].PercentChange))
+	}
+}
+
+func TestTopServices(t *testing.T) {
+	_, bob, subs := fixture()
+	repo := newRepository(t, subs...)
+	filter := &analytics.Filter{Start: month("01-2025"), End: month("04-2025")}
+
+	rows, err := repo.TopServices(context.Background(), filter, 10)
+	if err != nil {
+		t.Fatalf("TopServices: %v", err)
+	}
+	want := []analytics.ServiceTotal{
+		{Service: "Netflix", Total: 300, Subscriptions: 1, Users: 1},
+		// Open-ended and started before the range: all four months count.
+		{Service: "Spotify", Total: 120, Subscriptions: 1, Users: 1},
+		{Service: "Yandex", Total: 50, Subscriptions: 1, Users: 1},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %+v, want %+v", rows, want)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("rows[%d] = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	rows, err = repo.TopServices(context.Background(), filter, 1)
+	if err != nil {
+		t.Fatalf("TopServices: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Service != "Netflix" {
+		t.Fatalf("limit 1 = %+v, want only Netflix", rows)
+	}
+
+	filter.UserID = &bob
+	rows, err = repo.TopServices(context.Background(), filter, 10)
+	if err != nil {
+		t.Fatalf("TopServices: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Service != "Spotify" {
+		t.Fatalf("bob's services = %+v, want only Spotify", rows)
+	}
+}
+
+func TestChurn(t *testing.T) {
+	_, _, subs := fixture()
+	repo := newRepository(t, subs...)
+	filter := &analytics.Filter{Start: month("01-2025"), End: month("04-2025")}
+
+	rows, err := repo.Churn(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Churn: %v", err)
+	}
+	// Spotify started before the range and, like Netflix, never ends, so
+	// neither shows up as ended.
+	want := []analytics.MonthlyChurn{
+		{Month: month("01-2025"), Started: 0, Ended: 0},
+		{Month: month("02-2025"), Started: 1, Ended: 0},
+		{Month: month("03-2025"), Started: 1, Ended: 1},
+		{Month: month("04-2025"), Started: 0, Ended: 0},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("got %d months, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i, w := range want {
+		if !rows[i].Month.Equal(w.Month) || rows[i].Started != w.Started || rows[i].Ended != w.Ended {
+			t.Errorf("rows[%d] = %+v, want %+v", i, rows[i], w)
+		}
+	}
+
+	service := "Yandex"
+	filter.Service = &service
+	rows, err = repo.Churn(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("Churn: %v", err)
+	}
+	if rows[1].Started != 0 || rows[2].Started != 1 || rows[2].Ended != 1 {
+		t.Fatalf("Yandex churn = %+v", rows)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func equalPtr[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
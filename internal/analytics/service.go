@@ -0,0 +1,91 @@
+package analytics
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const monthYearLayout = "01-2006"
+
+// maxRangeMonths caps the number of months a single analytics query may span,
+// since every month in the range becomes a row of the generated series.
+const maxRangeMonths = 240
+
+type Filter struct {
+	Start   time.Time
+	End     time.Time
+	UserID  *uuid.UUID
+	Service *string
+}
+
+func parseMonthYear(s string) (time.Time, error) {
+	return time.Parse(monthYearLayout, s)
+}
+
+func formatMonthYear(t time.Time) string {
+	return t.UTC().Format(monthYearLayout)
+}
+
+func monthsBetween(start, end time.Time) int {
+	return (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+}
+
+// filterError is the problem code and message sent to the client for a
+// query parseFilter rejects.
+type filterError struct {
+	Code    string
+	Message string
+}
+
+// parseFilter reads the start, end, user_id and service query parameters
+// shared by all analytics endpoints.
+func parseFilter(q url.Values) (*Filter, *filterError) {
+	startParam := q.Get("start")
+	endParam := q.Get("end")
+	if startParam == "" || endParam == "" {
+		return nil, &filterError{ProblemMissingParameter, ErrMissingParameter}
+	}
+
+	start, err := parseMonthYear(startParam)
+	if err != nil {
+		return nil, &filterError{ProblemInvalidStartDate, ErrInvalidStartDate}
+	}
+	end, err := parseMonthYear(endParam)
+	if err != nil {
+		return nil, &filterError{ProblemInvalidEndDate, ErrInvalidEndDate}
+	}
+	if end.Before(start) {
+		return nil, &filterError{ProblemInvalidDateRange, ErrInvalidDateInterval}
+	}
+	if monthsBetween(start, end) > maxRangeMonths {
+		return nil, &filterError{ProblemRangeTooLong, ErrRangeTooLong}
+	}
+
+	filter := &Filter{Start: start, End: end}
+	if userParam := q.Get("user_id"); userParam != "" {
+		uid, err := uuid.Parse(userParam)
+		if err != nil {
+			return nil, &filterError{ProblemInvalidUserID, ErrInvalidUserUUID}
+		}
+		filter.UserID = &uid
+	}
+	if s := q.Get("service"); s != "" {
+		filter.Service = &s
+	}
+	return filter, nil
+}
+
+func parseLimit(q url.Values, def, max int) (int, bool) {
+	limitStr := q.Get("limit")
+	if limitStr == "" {
+		return def, true
+	}
+	v, err := strconv.Atoi(limitStr)
+	if err != nil || v <= 0 || v > max {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,117 @@
+package analytics
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMonthsBetween(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       int
+	}{
+		{"01-2025", "01-2025", 1},
+		{"01-2025", "12-2025", 12},
+		{"11-2024", "02-2025", 4},
+		{"01-2005", "12-2024", 240},
+		{"02-2025", "01-2025", 0},
+	}
+	for _, tt := range tests {
+		start, _ := parseMonthYear(tt.start)
+		end, _ := parseMonthYear(tt.end)
+		if got := monthsBetween(start, end); got != tt.want {
+			t.Errorf("monthsBetween(%s, %s) = %d, want %d", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode string
+	}{
+		{"valid", "start=01-2025&end=03-2025", ""},
+		{"single month", "start=01-2025&end=01-2025", ""},
+		{"longest range", "start=01-2005&end=12-2024", ""},
+		{"with filters", "start=01-2025&end=03-2025&user_id=" + userID.String() + "&service=Netflix", ""},
+		{"missing start", "end=03-2025", ProblemMissingParameter},
+		{"missing end", "start=01-2025", ProblemMissingParameter},
+		{"bad start", "start=2025-01&end=03-2025", ProblemInvalidStartDate},
+		{"bad end", "start=01-2025&end=13-2025", ProblemInvalidEndDate},
+		{"reversed", "start=03-2025&end=01-2025", ProblemInvalidDateRange},
+		{"too long", "start=01-2005&end=01-2025", ProblemRangeTooLong},
+		{"bad user", "start=01-2025&end=03-2025&user_id=nope", ProblemInvalidUserID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filter, filterErr := parseFilter(q)
+			if tt.wantCode != "" {
+				if filter != nil || filterErr == nil || filterErr.Code != tt.wantCode {
+					t.Fatalf("parseFilter = %+v, %+v, want code %s", filter, filterErr, tt.wantCode)
+				}
+				return
+			}
+			if filter == nil {
+				t.Fatalf("parseFilter failed: %+v", filterErr)
+			}
+			if !filter.Start.Equal(mustMonth(t, q.Get("start"))) || !filter.End.Equal(mustMonth(t, q.Get("end"))) {
+				t.Fatalf("range = %v..%v", filter.Start, filter.End)
+			}
+		})
+	}
+
+	q := url.Values{"start": {"01-2025"}, "end": {"03-2025"}, "user_id": {userID.String()}, "service": {"Netflix"}}
+	filter, _ := parseFilter(q)
+	if filter.UserID == nil || *filter.UserID != userID || filter.Service == nil || *filter.Service != "Netflix" {
+		t.Fatalf("filter = %+v", filter)
+	}
+	q = url.Values{"start": {"01-2025"}, "end": {"03-2025"}}
+	if filter, _ := parseFilter(q); filter.UserID != nil || filter.Service != nil {
+		t.Fatalf("filter without user_id and service = %+v", filter)
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		limit  string
+		want   int
+		wantOK bool
+	}{
+		{"", 10, true},
+		{"1", 1, true},
+		{"100", 100, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"101", 0, false},
+		{"ten", 0, false},
+	}
+	for _, tt := range tests {
+		q := url.Values{}
+		if tt.limit != "" {
+			q.Set("limit", tt.limit)
+		}
+		got, ok := parseLimit(q, 10, 100)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseLimit(%q) = %d, %t, want %d, %t", tt.limit, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func mustMonth(t *testing.T, s string) time.Time {
+	t.Helper()
+	m, err := parseMonthYear(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,62 @@
+package docs
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+const ContentTypeYAML = "application/yaml"
+
+// page is the Swagger UI page.
+//
+//go:embed docs.html
+var page []byte
+
+// assets are the files of swagger-ui-dist the page loads, served under
+// /docs/. update-swagger-ui.sh replaces them with another release.
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js
+var assets embed.FS
+
+type DocsHandlerDeps struct {
+	// Spec is the OpenAPI document, api.OpenAPI outside of tests.
+	Spec []byte
+}
+
+type DocsHandler struct {
+	Spec []byte
+}
+
+func NewDocsHandler(router *http.ServeMux, deps *DocsHandlerDeps) {
+	handler := DocsHandler{Spec: deps.Spec}
+	router.HandleFunc("GET /openapi.yaml", handler.OpenAPI())
+	router.HandleFunc("GET /docs", handler.Page())
+	router.HandleFunc("GET /docs/{$}", handler.Page())
+	router.Handle("GET /docs/", handler.Assets())
+}
+
+// OpenAPI serves the OpenAPI document the binary was built with.
+func (handler *DocsHandler) OpenAPI() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentTypeYAML)
+		w.Write(handler.Spec)
+	}
+}
+
+// Page serves interactive documentation for the spec.
+func (handler *DocsHandler) Page() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	}
+}
+
+// Assets serves the Swagger UI files the page loads.
+func (handler *DocsHandler) Assets() http.Handler {
+	dist, err := fs.Sub(assets, "swagger-ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/docs/", http.FileServerFS(dist))
+}
@@ -0,0 +1,49 @@
+package docs_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/api"
+	"github.com/SenechkaP/subs-tracker/internal/docs"
+)
+
+func TestDocsHandler(t *testing.T) {
+	router := http.NewServeMux()
+	docs.NewDocsHandler(router, &docs.DocsHandlerDeps{Spec: api.OpenAPI})
+
+	tests := []struct {
+		path        string
+		contentType string
+		contains    string
+	}{
+		{"/openapi.yaml", docs.ContentTypeYAML, "openapi: 3.0.3"},
+		{"/docs", "text/html; charset=utf-8", `url: "/openapi.yaml"`},
+		{"/docs/", "text/html; charset=utf-8", `src="/docs/swagger-ui-bundle.js"`},
+		{"/docs/swagger-ui-bundle.js", "text/javascript; charset=utf-8", "SwaggerUIBundle"},
+		{"/docs/swagger-ui.css", "text/css; charset=utf-8", ".swagger-ui"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if !strings.Contains(w.Body.String(), tt.contains) {
+				t.Errorf("body doesn't contain %q", tt.contains)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/docs/LICENSE", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("GET /docs/LICENSE status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,11 @@
+#!/bin/sh
+# Replaces the embedded Swagger UI assets with those of swagger-ui-dist
+# $1, e.g. ./update-swagger-ui.sh 5.18.2.
+set -eu
+
+version=$1
+dir=$(dirname "$0")/swagger-ui
+for file in swagger-ui.css swagger-ui-bundle.js; do
+	curl -fsSL "https://unpkg.com/swagger-ui-dist@$version/$file" -o "$dir/$file"
+done
+echo "$version" > "$dir/VERSION"
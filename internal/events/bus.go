@@ -0,0 +1,148 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// listenerBuffer is how many events a listener may fall behind before it is
+// dropped. A dropped client reconnects and catches up from the replay
+// buffer instead of holding back everyone else.
+const listenerBuffer = 64
+
+// Bus is an in-process publish/subscribe bus for subscription changes. It
+// keeps the last events in a bounded buffer so that listeners can resume
+// after a reconnect. It is safe for concurrent use; a nil *Bus discards
+// everything published to it.
+type Bus struct {
+	mu        sync.Mutex
+	lastID    uint64
+	replay    []Event // ring of the last cap(replay) events
+	next      int     // where the next event goes in replay
+	listeners map[*Listener]struct{}
+	closed    bool
+	now       func() time.Time
+}
+
+// NewBus returns a bus that keeps the last replaySize events for resuming.
+func NewBus(replaySize int) *Bus {
+	now := time.Now
+	return &Bus{
+		// IDs start from the start time in microseconds, so that they keep
+		// growing across restarts and an ID from before a restart is
+		// recognised as unknown rather than silently matching a new event.
+		lastID:    uint64(now().UnixMicro()),
+		replay:    make([]Event, 0, replaySize),
+		listeners: make(map[*Listener]struct{}),
+		now:       now,
+	}
+}
+
+// Listener receives the events published after it subscribed on C. C is
+// closed when the listener falls too far behind or the bus is closed.
+type Listener struct {
+	C   <-chan Event
+	ch  chan Event
+	bus *Bus
+}
+
+// Close unsubscribes the listener.
+func (l *Listener) Close() {
+	l.bus.mu.Lock()
+	defer l.bus.mu.Unlock()
+	l.bus.drop(l)
+}
+
+// Publish assigns the event its ID and time, keeps it for replay and hands
+// it to every listener.
+func (bus *Bus) Publish(e Event) {
+	if bus == nil {
+		return
+	}
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	if bus.closed {
+		return
+	}
+
+	bus.lastID++
+	e.ID = bus.lastID
+	e.Time = bus.now().UTC()
+	if cap(bus.replay) > 0 {
+		if len(bus.replay) < cap(bus.replay) {
+			bus.replay = append(bus.replay, e)
+		} else {
+			bus.replay[bus.next] = e
+		}
+		bus.next = (bus.next + 1) % cap(bus.replay)
+	}
+
+	for l := range bus.listeners {
+		select {
+		case l.ch <- e:
+		default:
+			bus.drop(l)
+		}
+	}
+}
+
+// Subscribe registers a listener. With resume it also returns the buffered
+// events after lastID, to be delivered before anything on the listener; ok
+// is false when some of the events after lastID are no longer buffered or
+// lastID was never issued, and the caller has to resynchronise.
+func (bus *Bus) Subscribe(lastID uint64, resume bool) (listener *Listener, replay []Event, ok bool) {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+
+	ch := make(chan Event, listenerBuffer)
+	listener = &Listener{C: ch, ch: ch, bus: bus}
+	if bus.closed {
+		close(ch)
+		return listener, nil, true
+	}
+	bus.listeners[listener] = struct{}{}
+	if !resume {
+		return listener, nil, true
+	}
+
+	buffered := bus.buffered()
+	oldest := bus.lastID + 1
+	if len(buffered) > 0 {
+		oldest = buffered[0].ID
+	}
+	if lastID > bus.lastID || lastID+1 < oldest {
+		return listener, nil, false
+	}
+	for _, e := range buffered {
+		if e.ID > lastID {
+			replay = append(replay, e)
+		}
+	}
+	return listener, replay, true
+}
+
+// Close closes every listener and stops accepting events, so that streams
+// end when the server shuts down.
+func (bus *Bus) Close() {
+	bus.mu.Lock()
+	defer bus.mu.Unlock()
+	bus.closed = true
+	for l := range bus.listeners {
+		bus.drop(l)
+	}
+}
+
+// buffered returns the replay buffer oldest first.
+func (bus *Bus) buffered() []Event {
+	if len(bus.replay) < cap(bus.replay) {
+		return bus.replay
+	}
+	return append(append([]Event(nil), bus.replay[bus.next:]...), bus.replay[:bus.next]...)
+}
+
+func (bus *Bus) drop(l *Listener) {
+	if _, ok := bus.listeners[l]; ok {
+		delete(bus.listeners, l)
+		close(l.ch)
+	}
+}
@@ -0,0 +1,115 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func publishN(bus *Bus, n int) []uint64 {
+	ids := make([]uint64, n)
+	for i := range ids {
+		bus.Publish(Event{Type: TypeCreated, SubscriptionID: uuid.New()})
+		ids[i] = bus.lastID
+	}
+	return ids
+}
+
+func TestBusDelivers(t *testing.T) {
+	bus := NewBus(10)
+	listener, _, _ := bus.Subscribe(0, false)
+	defer listener.Close()
+
+	ids := publishN(bus, 3)
+	for _, id := range ids {
+		e := <-listener.C
+		if e.ID != id || e.Time.IsZero() {
+			t.Fatalf("event = %+v, want ID %d with a time", e, id)
+		}
+	}
+}
+
+func TestBusReplay(t *testing.T) {
+	bus := NewBus(3)
+	ids := publishN(bus, 5)
+
+	tests := []struct {
+		name   string
+		lastID uint64
+		want   []uint64
+		ok     bool
+	}{
+		{"buffered", ids[2], ids[3:], true},
+		{"oldest buffered", ids[1], ids[2:], true},
+		{"up to date", ids[4], nil, true},
+		{"gap", ids[0], nil, false},
+		{"future", ids[4] + 1, nil, false},
+		{"unknown", 7, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, replay, ok := bus.Subscribe(tt.lastID, true)
+			defer listener.Close()
+			if ok != tt.ok {
+				t.Fatalf("ok = %t, want %t", ok, tt.ok)
+			}
+			if len(replay) != len(tt.want) {
+				t.Fatalf("replayed %d events, want %d", len(replay), len(tt.want))
+			}
+			for i, e := range replay {
+				if e.ID != tt.want[i] {
+					t.Errorf("replay[%d].ID = %d, want %d", i, e.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestBusWithoutReplay(t *testing.T) {
+	bus := NewBus(0)
+	ids := publishN(bus, 2)
+	if _, _, ok := bus.Subscribe(ids[1], true); !ok {
+		t.Fatal("resuming from the last event failed")
+	}
+	if _, _, ok := bus.Subscribe(ids[0], true); ok {
+		t.Fatal("resuming past an unbuffered event succeeded")
+	}
+}
+
+func TestBusDropsSlowListener(t *testing.T) {
+	bus := NewBus(0)
+	slow, _, _ := bus.Subscribe(0, false)
+	fast, _, _ := bus.Subscribe(0, false)
+	defer fast.Close()
+
+	for range listenerBuffer + 1 {
+		publishN(bus, 1)
+		<-fast.C
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	if received != listenerBuffer {
+		t.Fatalf("slow listener got %d events before being dropped, want %d", received, listenerBuffer)
+	}
+}
+
+func TestBusClose(t *testing.T) {
+	bus := NewBus(10)
+	listener, _, _ := bus.Subscribe(0, false)
+	bus.Close()
+	if _, open := <-listener.C; open {
+		t.Fatal("listener still open after Close")
+	}
+	listener.Close()
+
+	bus.Publish(Event{Type: TypeCreated})
+	late, _, _ := bus.Subscribe(0, false)
+	if _, open := <-late.C; open {
+		t.Fatal("listener of a closed bus is open")
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(Event{Type: TypeCreated})
+}
@@ -0,0 +1,133 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"github.com/google/uuid"
+)
+
+const (
+	ErrInvalidUserUUID = "USER UUID IS INVALID"
+	ErrStreaming       = "STREAMING IS NOT SUPPORTED"
+)
+
+// ProblemInvalidUserID is shared with the subscription endpoints.
+const ProblemInvalidUserID = res.ProblemInvalidUserID
+
+const ContentTypeEventStream = "text/event-stream"
+
+// retryDelay is how long EventSource waits before reconnecting.
+const retryDelay = 3 * time.Second
+
+type EventsHandlerDeps struct {
+	Bus *Bus
+	// Heartbeat is how often a comment is sent on an idle stream, so that
+	// proxies don't close it and clients notice a dead connection.
+	Heartbeat time.Duration
+}
+
+type EventsHandler struct {
+	Bus       *Bus
+	Heartbeat time.Duration
+}
+
+func NewEventsHandler(router apiversion.Router, deps *EventsHandlerDeps) {
+	handler := EventsHandler{Bus: deps.Bus, Heartbeat: deps.Heartbeat}
+	router.HandleFunc("GET /events", handler.Stream())
+}
+
+// Stream sends subscription changes as Server-Sent Events until the client
+// goes away, optionally only those of the user_id query parameter. A client
+// reconnecting with Last-Event-ID first gets the events it missed.
+func (handler *EventsHandler) Stream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var userID *uuid.UUID
+		if s := r.URL.Query().Get("user_id"); s != "" {
+			id, err := uuid.Parse(s)
+			if err != nil {
+				logger.FromRequest(r).Warnf("Stream invalid user_id=%s", s)
+				res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidUserID, ErrInvalidUserUUID)
+				return
+			}
+			userID = &id
+		}
+
+		// The stream outlives the server's write timeout; without a
+		// deadline it ends with the client or the bus.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			logger.FromRequest(r).Errorf("Stream cannot clear write deadline: %v", err)
+			res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrStreaming)
+			return
+		}
+
+		lastEventID := r.Header.Get("Last-Event-ID")
+		lastID, err := strconv.ParseUint(lastEventID, 10, 64)
+		resume := lastEventID != ""
+		listener, replay, ok := handler.Bus.Subscribe(lastID, resume && err == nil)
+		defer listener.Close()
+
+		h := w.Header()
+		h.Set("Content-Type", ContentTypeEventStream)
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", retryDelay.Milliseconds())
+
+		if resume && (err != nil || !ok) {
+			logger.FromRequest(r).Infof("Stream cannot resume last_event_id=%s, sending reset", lastEventID)
+			fmt.Fprintf(w, "event: %s\ndata: {\"type\":%q}\n\n", TypeReset, TypeReset)
+		}
+		for _, e := range replay {
+			writeEvent(w, e, userID)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(handler.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case e, open := <-listener.C:
+				if !open {
+					// Dropped for falling behind, or the server is
+					// shutting down: the client reconnects and resumes.
+					return
+				}
+				if !writeEvent(w, e, userID) {
+					continue
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes e unless it belongs to another user than userID, and
+// reports whether it did.
+func writeEvent(w http.ResponseWriter, e Event, userID *uuid.UUID) bool {
+	if userID != nil && e.UserID != *userID {
+		return false
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		logger.Log.Errorf("Stream marshal event id=%d err=%v", e.ID, err)
+		return false
+	}
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return true
+}
@@ -0,0 +1,161 @@
+package events_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/events"
+	"github.com/google/uuid"
+)
+
+type sseEvent struct {
+	id   string
+	typ  string
+	data string
+}
+
+// openStream starts GET /events and returns a function reading the next
+// event, skipping comments and the retry field. The handler has subscribed
+// to the bus by the time it returns, since headers are only sent after that.
+func openStream(t *testing.T, server *httptest.Server, query, lastEventID string) func() sseEvent {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/events"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != events.ContentTypeEventStream {
+		t.Fatalf("Content-Type = %q", got)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	return func() sseEvent {
+		t.Helper()
+		var e sseEvent
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					t.Fatal("stream ended")
+				}
+				field, value, _ := strings.Cut(line, ": ")
+				switch field {
+				case "id":
+					e.id = value
+				case "event":
+					e.typ = value
+				case "data":
+					e.data = value
+				case "":
+					if e.typ != "" {
+						return e
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for an event")
+			}
+		}
+	}
+}
+
+func newServer(t *testing.T, bus *events.Bus) *httptest.Server {
+	t.Helper()
+	router := http.NewServeMux()
+	events.NewEventsHandler(router, &events.EventsHandlerDeps{Bus: bus, Heartbeat: time.Minute})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	t.Cleanup(bus.Close)
+	return server
+}
+
+func TestStreamFiltersByUser(t *testing.T) {
+	bus := events.NewBus(10)
+	server := newServer(t, bus)
+	alice, bob := uuid.New(), uuid.New()
+
+	all := openStream(t, server, "", "")
+	filtered := openStream(t, server, "?user_id="+alice.String(), "")
+	bus.Publish(events.Event{Type: events.TypeCreated, UserID: bob})
+	bus.Publish(events.Event{Type: events.TypeDeleted, UserID: alice, SubscriptionID: uuid.New()})
+
+	if e := all(); e.typ != events.TypeCreated || !strings.Contains(e.data, bob.String()) {
+		t.Fatalf("unfiltered stream got %+v, want bob's event", e)
+	}
+	if e := all(); e.typ != events.TypeDeleted {
+		t.Fatalf("unfiltered stream got %+v, want alice's event", e)
+	}
+	e := filtered()
+	if e.typ != events.TypeDeleted {
+		t.Fatalf("filtered stream got %+v, want only alice's event", e)
+	}
+	var data events.Event
+	if err := json.Unmarshal([]byte(e.data), &data); err != nil {
+		t.Fatal(err)
+	}
+	if data.UserID != alice || data.Subscription != nil || data.Time.IsZero() {
+		t.Fatalf("data = %+v", data)
+	}
+}
+
+func TestStreamResumes(t *testing.T) {
+	bus := events.NewBus(2)
+	server := newServer(t, bus)
+	all := openStream(t, server, "", "")
+	for range 4 {
+		bus.Publish(events.Event{Type: events.TypeUpdated, UserID: uuid.New()})
+	}
+	var last sseEvent
+	for range 4 {
+		last = all()
+	}
+	lastID, _ := strconv.ParseUint(last.id, 10, 64)
+
+	// Events lastID-1 and lastID are buffered.
+	next := openStream(t, server, "", strconv.FormatUint(lastID-2, 10))
+	for _, want := range []uint64{lastID - 1, lastID} {
+		if e := next(); e.id != strconv.FormatUint(want, 10) {
+			t.Fatalf("replayed %+v, want id %d", e, want)
+		}
+	}
+
+	for _, lastEventID := range []string{strconv.FormatUint(lastID-3, 10), "garbage"} {
+		if e := openStream(t, server, "", lastEventID)(); e.typ != events.TypeReset {
+			t.Fatalf("Last-Event-ID %s: got %+v, want a reset", lastEventID, e)
+		}
+	}
+}
+
+func TestStreamRejectsInvalidUser(t *testing.T) {
+	server := newServer(t, events.NewBus(0))
+	resp, err := server.Client().Get(server.URL + "/events?user_id=nope")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", resp.StatusCode)
+	}
+}
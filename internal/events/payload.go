@@ -0,0 +1,29 @@
+package events
+
+import (
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/google/uuid"
+)
+
+// Event types. TypeReset is only sent to a resuming client whose missed
+// events are no longer buffered: it has to reload what it shows.
+const (
+	TypeCreated = "created"
+	TypeUpdated = "updated"
+	TypeDeleted = "deleted"
+	TypeReset   = "reset"
+)
+
+// Event is a change of one subscription. ID is the SSE event ID and is not
+// part of the data. Subscription is the state after the change and is
+// absent for TypeDeleted.
+type Event struct {
+	ID             uint64               `json:"-"`
+	Type           string               `json:"type"`
+	SubscriptionID uuid.UUID            `json:"subscription_id"`
+	UserID         uuid.UUID            `json:"user_id"`
+	Subscription   *models.Subscription `json:"subscription,omitempty"`
+	Time           time.Time            `json:"time"`
+}
@@ -0,0 +1,249 @@
+package grpcserver
+
+//go:generate protoc -I ../../api/proto --go_out=../../pkg/pb --go_opt=module=github.com/SenechkaP/subs-tracker/pkg/pb --go-grpc_out=../../pkg/pb --go-grpc_opt=module=github.com/SenechkaP/subs-tracker/pkg/pb substracker/v1/subscription.proto
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	pb "github.com/SenechkaP/subs-tracker/pkg/pb/substracker/v1"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultListLimit      = 10
+	defaultStreamPageSize = 100
+	maxStreamPageSize     = 1000
+)
+
+const ErrInternal = "INTERNAL ERROR"
+
+// ErrorDomain is the ErrorInfo domain of errors returned by the service.
+const ErrorDomain = "substracker"
+
+type SubscriptionServerDeps struct {
+	Service *subscription.SubscriptionService
+}
+
+// SubscriptionServer implements pb.SubscriptionServiceServer on top of the
+// same SubscriptionService as the REST handlers.
+type SubscriptionServer struct {
+	pb.UnimplementedSubscriptionServiceServer
+	Service *subscription.SubscriptionService
+}
+
+// NewServer returns a gRPC server exposing the subscription API together with
+// the standard health and reflection services.
+func NewServer(deps *SubscriptionServerDeps) *grpc.Server {
+	server := grpc.NewServer()
+	pb.RegisterSubscriptionServiceServer(server, &SubscriptionServer{Service: deps.Service})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(pb.SubscriptionService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
+
+func (server *SubscriptionServer) GetSubscription(ctx context.Context, in *pb.GetSubscriptionRequest) (*pb.Subscription, error) {
+	id, err := uuid.Parse(in.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, subscription.ErrInvalidSubscriptionUUID)
+	}
+	sub, err := server.Service.Get(ctx, id)
+	if err != nil {
+		return nil, toStatus("GetSubscription", err)
+	}
+	return toProto(sub), nil
+}
+
+func (server *SubscriptionServer) CreateSubscription(ctx context.Context, in *pb.CreateSubscriptionRequest) (*pb.CreateSubscriptionResponse, error) {
+	created, err := server.Service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service:   in.GetServiceName(),
+		PriceRUB:  in.GetPrice(),
+		UserID:    in.GetUserId(),
+		StartDate: in.GetStartDate(),
+		EndDate:   in.EndDate,
+	})
+	if err != nil {
+		return nil, toStatus("CreateSubscription", err)
+	}
+
+	out := &pb.CreateSubscriptionResponse{Subscription: toProto(created.Subscription)}
+	if len(created.OverlapsWith) > 0 {
+		out.Warning = subscription.WarnOverlappingSubscription
+		out.OverlapsWith = created.OverlapsWith
+	}
+	return out, nil
+}
+
+func (server *SubscriptionServer) PatchSubscription(ctx context.Context, in *pb.PatchSubscriptionRequest) (*pb.Subscription, error) {
+	id, err := uuid.Parse(in.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, subscription.ErrInvalidSubscriptionUUID)
+	}
+	sub, err := server.Service.Patch(ctx, id, &subscription.SubscriptionPatchRequest{
+		PriceRUB:  in.Price,
+		StartDate: in.StartDate,
+		EndDate:   in.EndDate,
+	}, ifMatch(in.GetExpectedVersion()))
+	if err != nil {
+		return nil, toStatus("PatchSubscription", err)
+	}
+	return toProto(sub), nil
+}
+
+func (server *SubscriptionServer) DeleteSubscription(ctx context.Context, in *pb.DeleteSubscriptionRequest) (*pb.DeleteSubscriptionResponse, error) {
+	id, err := uuid.Parse(in.GetId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, subscription.ErrInvalidSubscriptionUUID)
+	}
+	if err := server.Service.Delete(ctx, id, ifMatch(in.GetExpectedVersion())); err != nil {
+		return nil, toStatus("DeleteSubscription", err)
+	}
+	return &pb.DeleteSubscriptionResponse{}, nil
+}
+
+func (server *SubscriptionServer) ListUserSubscriptions(ctx context.Context, in *pb.ListUserSubscriptionsRequest) (*pb.ListUserSubscriptionsResponse, error) {
+	userID, err := uuid.Parse(in.GetUserId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, subscription.ErrInvalidUserUUID)
+	}
+	limit := int(in.GetLimit())
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+	subs, err := server.Service.ListByUser(ctx, userID, int(in.GetOffset()), limit)
+	if err != nil {
+		return nil, toStatus("ListUserSubscriptions", err)
+	}
+
+	out := &pb.ListUserSubscriptionsResponse{Subscriptions: make([]*pb.Subscription, 0, len(subs))}
+	for i := range subs {
+		out.Subscriptions = append(out.Subscriptions, toProto(&subs[i]))
+	}
+	return out, nil
+}
+
+func (server *SubscriptionServer) StreamUserSubscriptions(in *pb.StreamUserSubscriptionsRequest, stream grpc.ServerStreamingServer[pb.Subscription]) error {
+	userID, err := uuid.Parse(in.GetUserId())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, subscription.ErrInvalidUserUUID)
+	}
+	pageSize := int(in.GetPageSize())
+	if pageSize == 0 {
+		pageSize = defaultStreamPageSize
+	}
+	if pageSize < 0 || pageSize > maxStreamPageSize {
+		return status.Error(codes.InvalidArgument, subscription.ErrInvalidParameter)
+	}
+
+	for offset := 0; ; offset += pageSize {
+		subs, err := server.Service.ListByUser(stream.Context(), userID, offset, pageSize)
+		if err != nil {
+			return toStatus("StreamUserSubscriptions", err)
+		}
+		for i := range subs {
+			if err := stream.Send(toProto(&subs[i])); err != nil {
+				return err
+			}
+		}
+		if len(subs) < pageSize {
+			return nil
+		}
+	}
+}
+
+func (server *SubscriptionServer) SumByMonthRange(ctx context.Context, in *pb.SumByMonthRangeRequest) (*pb.SumByMonthRangeResponse, error) {
+	var userID *uuid.UUID
+	if in.UserId != nil && *in.UserId != "" {
+		uid, err := uuid.Parse(*in.UserId)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, subscription.ErrInvalidUserUUID)
+		}
+		userID = &uid
+	}
+	sum, err := server.Service.SumByMonthRange(ctx, in.GetStart(), in.GetEnd(), userID, in.ServiceName)
+	if err != nil {
+		return nil, toStatus("SumByMonthRange", err)
+	}
+	return &pb.SumByMonthRangeResponse{TotalSum: sum}, nil
+}
+
+// ifMatch turns an expected version into the If-Match value understood by
+// SubscriptionService. Zero means the operation is unconditional.
+func ifMatch(version int64) string {
+	if version == 0 {
+		return ""
+	}
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// toStatus maps a SubscriptionService error to a gRPC status. Internal errors
+// are logged and hidden from the client.
+func toStatus(op string, err error) error {
+	var svcErr *subscription.ServiceError
+	if !errors.As(err, &svcErr) {
+		logger.Log.Errorf("grpc %s db error err=%v", op, err)
+		return status.Error(codes.Internal, ErrInternal)
+	}
+	logger.Log.Warnf("grpc %s err=%v", op, svcErr)
+	code := codes.InvalidArgument
+	switch svcErr.Kind {
+	case subscription.KindNotFound:
+		code = codes.NotFound
+	case subscription.KindPreconditionFailed, subscription.KindPreconditionRequired:
+		code = codes.FailedPrecondition
+	}
+	// The problem code travels as ErrorInfo.Reason, the same value HTTP
+	// clients get in the code of a problem+json response.
+	details := []protoadapt.MessageV1{&errdetails.ErrorInfo{Reason: svcErr.Code, Domain: ErrorDomain}}
+	if len(svcErr.Violations) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, v := range svcErr.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Reason:      v.Code,
+				Description: v.Message,
+			})
+		}
+		details = append(details, badRequest)
+	}
+	st := status.New(code, svcErr.Message)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+func toProto(s *models.Subscription) *pb.Subscription {
+	out := &pb.Subscription{
+		Id:          s.ID.String(),
+		ServiceName: s.Service,
+		Price:       s.PriceRUB,
+		UserId:      s.UserID.String(),
+		StartDate:   s.StartDate.UTC().Format("01-2006"),
+		Version:     s.Version,
+		CreatedAt:   timestamppb.New(s.CreatedAt),
+		UpdatedAt:   timestamppb.New(s.UpdatedAt),
+	}
+	if s.EndDate != nil {
+		end := s.EndDate.UTC().Format("01-2006")
+		out.EndDate = &end
+	}
+	return out
+}
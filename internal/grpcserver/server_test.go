@@ -0,0 +1,217 @@
+package grpcserver_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/grpcserver"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	pb "github.com/SenechkaP/subs-tracker/pkg/pb/substracker/v1"
+	"github.com/google/uuid"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newClient(t *testing.T) *grpc.ClientConn {
+	return newClientWith(t, subscription.NewMemoryRepository())
+}
+
+func newClientWith(t *testing.T, repository subscription.Repository) *grpc.ClientConn {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpcserver.NewServer(&grpcserver.SubscriptionServerDeps{
+		Service: subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+			Repository: repository,
+		}),
+	})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func assertCode(t *testing.T, err error, code codes.Code, reason, msg string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code || st.Message() != msg {
+		t.Fatalf("got %v, want %s %q", err, code, msg)
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok && info.GetReason() == reason && info.GetDomain() == grpcserver.ErrorDomain {
+			return
+		}
+	}
+	t.Fatalf("details = %v, want ErrorInfo with reason %q", st.Details(), reason)
+}
+
+func TestSubscriptionServer(t *testing.T) {
+	ctx := context.Background()
+	client := pb.NewSubscriptionServiceClient(newClient(t))
+	userID := uuid.NewString()
+
+	created, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{
+		ServiceName: "Netflix", Price: 499, UserId: userID, StartDate: "01-2025",
+	})
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	id := created.GetSubscription().GetId()
+
+	got, err := client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: id})
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if got.GetServiceName() != "Netflix" || got.GetStartDate() != "01-2025" || got.EndDate != nil || got.GetVersion() != 1 {
+		t.Fatalf("GetSubscription returned %v", got)
+	}
+
+	end := "06-2025"
+	_, err = client.PatchSubscription(ctx, &pb.PatchSubscriptionRequest{Id: id, EndDate: &end, ExpectedVersion: 5})
+	assertCode(t, err, codes.FailedPrecondition, subscription.ProblemPreconditionFailed, subscription.ErrPreconditionFailed)
+
+	patched, err := client.PatchSubscription(ctx, &pb.PatchSubscriptionRequest{Id: id, EndDate: &end, ExpectedVersion: 1})
+	if err != nil {
+		t.Fatalf("PatchSubscription: %v", err)
+	}
+	if patched.GetEndDate() != "06-2025" || patched.GetVersion() != 2 {
+		t.Fatalf("PatchSubscription returned %v", patched)
+	}
+
+	sum, err := client.SumByMonthRange(ctx, &pb.SumByMonthRangeRequest{Start: "03-2025", End: "04-2025", UserId: &userID})
+	if err != nil {
+		t.Fatalf("SumByMonthRange: %v", err)
+	}
+	if sum.GetTotalSum() != 499 {
+		t.Fatalf("SumByMonthRange = %d, want 499", sum.GetTotalSum())
+	}
+
+	_, err = client.SumByMonthRange(ctx, &pb.SumByMonthRangeRequest{Start: "05-2025", End: "04-2025"})
+	assertCode(t, err, codes.InvalidArgument, subscription.ProblemInvalidDateRange, subscription.ErrInvalidDateInterval)
+
+	if _, err := client.DeleteSubscription(ctx, &pb.DeleteSubscriptionRequest{Id: id}); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	_, err = client.GetSubscription(ctx, &pb.GetSubscriptionRequest{Id: id})
+	assertCode(t, err, codes.NotFound, subscription.ProblemSubscriptionNotFound, subscription.ErrSubscriptionNotFound)
+}
+
+func TestStreamUserSubscriptions(t *testing.T) {
+	ctx := context.Background()
+	client := pb.NewSubscriptionServiceClient(newClient(t))
+	userID := uuid.NewString()
+
+	for _, start := range []string{"01-2025", "02-2025", "03-2025", "04-2025", "05-2025"} {
+		_, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{
+			ServiceName: "Spotify", Price: 199, UserId: userID, StartDate: start,
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+	}
+
+	stream, err := client.StreamUserSubscriptions(ctx, &pb.StreamUserSubscriptionsRequest{UserId: userID, PageSize: 2})
+	if err != nil {
+		t.Fatalf("StreamUserSubscriptions: %v", err)
+	}
+	var starts []string
+	for {
+		sub, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		starts = append(starts, sub.GetStartDate())
+	}
+	want := []string{"05-2025", "04-2025", "03-2025", "02-2025", "01-2025"}
+	if len(starts) != len(want) {
+		t.Fatalf("streamed %v, want %v", starts, want)
+	}
+	for i := range want {
+		if starts[i] != want[i] {
+			t.Fatalf("streamed %v, want %v", starts, want)
+		}
+	}
+}
+
+func TestStreamUserSubscriptionsSameStart(t *testing.T) {
+	// Paging goes through SQL here, where rows with the same start date are
+	// only paged consistently if the query orders them fully.
+	gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.AutoMigrate(gormDB); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	ctx := context.Background()
+	client := pb.NewSubscriptionServiceClient(newClientWith(t, subscription.NewSubscriptionRepository(gormDB)))
+	userID := uuid.NewString()
+
+	created := make(map[string]bool)
+	for range 7 {
+		resp, err := client.CreateSubscription(ctx, &pb.CreateSubscriptionRequest{
+			ServiceName: "Spotify", Price: 199, UserId: userID, StartDate: "01-2025",
+		})
+		if err != nil {
+			t.Fatalf("CreateSubscription: %v", err)
+		}
+		created[resp.GetSubscription().GetId()] = true
+	}
+
+	stream, err := client.StreamUserSubscriptions(ctx, &pb.StreamUserSubscriptionsRequest{UserId: userID, PageSize: 3})
+	if err != nil {
+		t.Fatalf("StreamUserSubscriptions: %v", err)
+	}
+	streamed := make(map[string]int)
+	for {
+		sub, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv: %v", err)
+		}
+		streamed[sub.GetId()]++
+	}
+	for id := range created {
+		if streamed[id] != 1 {
+			t.Errorf("subscription %s streamed %d times, want once", id, streamed[id])
+		}
+	}
+	if len(streamed) != len(created) {
+		t.Fatalf("streamed %d subscriptions, want %d", len(streamed), len(created))
+	}
+}
+
+func TestHealth(t *testing.T) {
+	client := healthpb.NewHealthClient(newClient(t))
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: pb.SubscriptionService_ServiceDesc.ServiceName})
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("status = %s, want SERVING", resp.GetStatus())
+	}
+}
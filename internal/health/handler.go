@@ -0,0 +1,104 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+)
+
+const readinessTimeout = 2 * time.Second
+
+type HealthHandlerDeps struct {
+	// DB is nil when the app runs without a database.
+	DB *gorm.DB
+	// CheckMigrations makes readiness fail while versioned migrations are
+	// pending. It only applies to Postgres; SQLite is auto-migrated.
+	CheckMigrations bool
+}
+
+type HealthHandler struct {
+	DB              *gorm.DB
+	CheckMigrations bool
+}
+
+func NewHealthHandler(router *http.ServeMux, deps *HealthHandlerDeps) {
+	handler := HealthHandler{
+		DB:              deps.DB,
+		CheckMigrations: deps.CheckMigrations,
+	}
+	router.HandleFunc("GET /healthz", handler.Liveness())
+	router.HandleFunc("GET /readyz", handler.Readiness())
+}
+
+// Liveness reports that the process is up and serving HTTP. It never touches
+// the database, so a database outage does not get the app restarted.
+func (handler *HealthHandler) Liveness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res.JsonDump(w, HealthResponse{Status: StatusOK}, http.StatusOK)
+	}
+}
+
+// Readiness reports whether the app can serve requests: the database answers
+// a ping and, if enabled, all migrations are applied.
+func (handler *HealthHandler) Readiness() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if handler.DB == nil {
+			res.JsonDump(w, HealthResponse{Status: StatusOK}, http.StatusOK)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := map[string]error{"database": handler.pingDB(ctx)}
+		if handler.CheckMigrations {
+			checks["migrations"] = handler.checkMigrations(ctx)
+		}
+
+		// Failures are logged rather than returned, the endpoint is reachable
+		// by anyone who can reach the API.
+		out := HealthResponse{Status: StatusOK, Checks: map[string]string{}}
+		status := http.StatusOK
+		for name, err := range checks {
+			out.Checks[name] = StatusOK
+			if err != nil {
+				logger.FromRequest(r).Warnf("Readiness check failed check=%s err=%v", name, err)
+				out.Checks[name] = StatusUnavailable
+				out.Status = StatusUnavailable
+				status = http.StatusServiceUnavailable
+			}
+		}
+		res.JsonDump(w, out, status)
+	}
+}
+
+func (handler *HealthHandler) pingDB(ctx context.Context) error {
+	sqlDB, err := handler.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func (handler *HealthHandler) checkMigrations(ctx context.Context) error {
+	pending, err := migrations.Pending(handler.DB.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
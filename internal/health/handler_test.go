@@ -0,0 +1,49 @@
+package health_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/health"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+)
+
+func get(t *testing.T, router http.Handler, path string) (int, health.HealthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	var out health.HealthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return rec.Code, out
+}
+
+func TestReadinessFollowsDatabase(t *testing.T) {
+	database, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := http.NewServeMux()
+	health.NewHealthHandler(router, &health.HealthHandlerDeps{DB: database})
+
+	if code, out := get(t, router, "/readyz"); code != http.StatusOK || out.Checks["database"] != health.StatusOK {
+		t.Fatalf("readyz = %d %+v, want 200 with database ok", code, out)
+	}
+
+	sqlDB, err := database.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	if code, out := get(t, router, "/readyz"); code != http.StatusServiceUnavailable || out.Checks["database"] != health.StatusUnavailable {
+		t.Fatalf("readyz after close = %d %+v, want 503 with database unavailable", code, out)
+	}
+	if code, _ := get(t, router, "/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz after close = %d, want 200", code)
+	}
+}
@@ -0,0 +1,243 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"gorm.io/gorm"
+)
+
+const (
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderReplayed       = "Idempotent-Replayed"
+)
+
+const (
+	ErrInvalidIdempotencyKey = "IDEMPOTENCY KEY IS INVALID"
+	ErrKeyReused             = "IDEMPOTENCY KEY WAS ALREADY USED WITH A DIFFERENT REQUEST"
+	ErrRequestInProgress     = "REQUEST WITH THIS IDEMPOTENCY KEY IS STILL IN PROGRESS"
+	ErrIdempotencyStore      = "FAILED TO PROCESS IDEMPOTENCY KEY"
+	ErrBodyTooLarge          = "BODY IS TOO LARGE"
+	ErrReadBody              = "FAILED TO READ BODY"
+)
+
+const (
+	ProblemInvalidIdempotencyKey = "invalid_idempotency_key"
+	ProblemKeyReused             = "idempotency_key_reused"
+	ProblemRequestInProgress     = "request_in_progress"
+	ProblemBodyTooLarge          = res.ProblemBodyTooLarge
+	ProblemReadBody              = "unreadable_body"
+)
+
+const maxKeyLength = 255
+
+// pendingTTL bounds how long a reservation blocks retries if the process dies
+// before the original request completes.
+const pendingTTL = time.Minute
+
+// purgeInterval is how often expired keys are purged from the table.
+const purgeInterval = time.Minute
+
+// Guard replays stored responses for requests carrying an Idempotency-Key
+// header, so a retried request is executed at most once per key.
+type Guard struct {
+	Repository *IdempotencyRepository
+	TTL        time.Duration
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func NewGuard(repository *IdempotencyRepository, ttl time.Duration) *Guard {
+	return &Guard{Repository: repository, TTL: ttl}
+}
+
+// Wrap returns next unchanged when the guard is nil, so callers can leave
+// idempotency disabled without branching.
+func (guard *Guard) Wrap(next http.Handler) http.Handler {
+	if guard == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(HeaderIdempotencyKey)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			logger.FromRequest(r).Warnf("Idempotency key too long len=%d", len(key))
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidIdempotencyKey, ErrInvalidIdempotencyKey)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			logger.FromRequest(r).Warnf("Idempotency read body key=%s err=%v", key, err)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				res.WriteProblem(w, r, http.StatusRequestEntityTooLarge, ProblemBodyTooLarge, ErrBodyTooLarge)
+				return
+			}
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemReadBody, ErrReadBody)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(r, body)
+		storedKey := scopedKey(r, key)
+
+		guard.purgeExpired(r)
+
+		reserved, err := guard.Repository.Reserve(r.Context(), &models.IdempotencyKey{
+			Key:         storedKey,
+			Fingerprint: fingerprint,
+			ExpiresAt:   time.Now().UTC().Add(min(pendingTTL, guard.TTL)),
+		})
+		if err != nil {
+			logger.FromRequest(r).Errorf("Idempotency reserve db error key=%s err=%v", key, err)
+			res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrIdempotencyStore)
+			return
+		}
+		if !reserved {
+			guard.replay(w, r, key, storedKey, fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				guard.release(r, key, storedKey)
+			}
+		}()
+
+		next.ServeHTTP(recorder, r)
+
+		if recorder.statusCode >= http.StatusInternalServerError {
+			return
+		}
+		err = guard.Repository.Complete(
+			context.WithoutCancel(r.Context()),
+			storedKey,
+			recorder.statusCode,
+			recorder.Header().Get("Content-Type"),
+			recorder.body.Bytes(),
+			time.Now().UTC().Add(guard.TTL),
+		)
+		if err != nil {
+			logger.FromRequest(r).Errorf("Idempotency complete db error key=%s err=%v", key, err)
+			return
+		}
+		completed = true
+	})
+}
+
+func (guard *Guard) replay(w http.ResponseWriter, r *http.Request, key, storedKey, fingerprint string) {
+	stored, err := guard.Repository.Get(r.Context(), storedKey)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The reservation expired or was released between our insert
+			// attempt and this read; the client may simply retry.
+			logger.FromRequest(r).Warnf("Idempotency key vanished key=%s", key)
+			res.WriteProblem(w, r, http.StatusConflict, ProblemRequestInProgress, ErrRequestInProgress)
+			return
+		}
+		logger.FromRequest(r).Errorf("Idempotency get db error key=%s err=%v", key, err)
+		res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, ErrIdempotencyStore)
+		return
+	}
+	if stored.Fingerprint != fingerprint {
+		logger.FromRequest(r).Warnf("Idempotency key reused with different request key=%s", key)
+		res.WriteProblem(w, r, http.StatusConflict, ProblemKeyReused, ErrKeyReused)
+		return
+	}
+	if stored.StatusCode == nil {
+		logger.FromRequest(r).Warnf("Idempotency request in progress key=%s", key)
+		res.WriteProblem(w, r, http.StatusConflict, ProblemRequestInProgress, ErrRequestInProgress)
+		return
+	}
+
+	logger.FromRequest(r).Infof("Idempotency replaying response key=%s status=%d", key, *stored.StatusCode)
+	if stored.ContentType != "" {
+		w.Header().Set("Content-Type", stored.ContentType)
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(*stored.StatusCode)
+	w.Write(stored.ResponseBody)
+}
+
+// purgeExpired starts a purge of expired keys in the background unless one
+// ran within purgeInterval, so that requests don't each scan the table.
+func (guard *Guard) purgeExpired(r *http.Request) {
+	guard.mu.Lock()
+	now := time.Now()
+	if now.Sub(guard.lastPurge) < purgeInterval {
+		guard.mu.Unlock()
+		return
+	}
+	guard.lastPurge = now
+	guard.mu.Unlock()
+
+	log := logger.FromRequest(r)
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		if err := guard.Repository.DeleteExpired(ctx); err != nil {
+			log.Warnf("Idempotency purge expired keys err=%v", err)
+		}
+	}()
+}
+
+func (guard *Guard) release(r *http.Request, key, storedKey string) {
+	if err := guard.Repository.Release(context.Background(), storedKey); err != nil {
+		logger.FromRequest(r).Errorf("Idempotency release db error key=%s err=%v", key, err)
+	}
+}
+
+// scopedKey is the key stored for the Idempotency-Key key of r. Keys are
+// per caller, see middleware.ClientID, so that one client cannot replay or
+// block another's requests; only allow-listed API keys count, otherwise the
+// caller's IP does. Hashing keeps the result within the column size
+// and API keys out of the table.
+func scopedKey(r *http.Request, key string) string {
+	h := sha256.New()
+	io.WriteString(h, middleware.ClientID(r))
+	io.WriteString(h, "\n")
+	io.WriteString(h, key)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	io.WriteString(h, " ")
+	io.WriteString(h, r.URL.Path)
+	io.WriteString(h, "\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+}
+
+func (w *responseRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,200 @@
+package idempotency_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/idempotency"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+)
+
+func newGuard(t *testing.T, ttl time.Duration) *idempotency.Guard {
+	t.Helper()
+	gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.AutoMigrate(gormDB); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return idempotency.NewGuard(idempotency.NewIdempotencyRepository(gormDB), ttl)
+}
+
+func TestGuard(t *testing.T) {
+	type request struct {
+		body       string
+		wantStatus int
+		wantBody   string
+		wantCode   string
+		replayed   bool
+	}
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		status   int
+		requests []request
+	}{
+		{
+			name:   "replay of a stored response",
+			ttl:    time.Hour,
+			status: http.StatusCreated,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`},
+				{body: `{"a":1}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`, replayed: true},
+			},
+		},
+		{
+			name:   "different body",
+			ttl:    time.Hour,
+			status: http.StatusCreated,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`},
+				{body: `{"a":2}`, wantStatus: http.StatusConflict, wantCode: idempotency.ProblemKeyReused},
+			},
+		},
+		{
+			name:   "server errors are not stored",
+			ttl:    time.Hour,
+			status: http.StatusInternalServerError,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusInternalServerError, wantBody: `{"call":1}`},
+				{body: `{"a":1}`, wantStatus: http.StatusInternalServerError, wantBody: `{"call":2}`},
+			},
+		},
+		{
+			// An expired key is taken over even before the table is purged.
+			name:   "expired key",
+			ttl:    time.Nanosecond,
+			status: http.StatusCreated,
+			requests: []request{
+				{body: `{"a":1}`, wantStatus: http.StatusCreated, wantBody: `{"call":1}`},
+				{body: `{"a":2}`, wantStatus: http.StatusCreated, wantBody: `{"call":2}`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			handler := newGuard(t, tt.ttl).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"call":%d}`, calls)
+			}))
+			for i, req := range tt.requests {
+				r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(req.body))
+				r.Header.Set(idempotency.HeaderIdempotencyKey, "key-1")
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, r)
+
+				if w.Code != req.wantStatus {
+					t.Fatalf("request %d: status = %d, want %d: %s", i, w.Code, req.wantStatus, w.Body)
+				}
+				if req.wantBody != "" && w.Body.String() != req.wantBody {
+					t.Errorf("request %d: body = %s, want %s", i, w.Body, req.wantBody)
+				}
+				if req.wantCode != "" && !strings.Contains(w.Body.String(), `"code":"`+req.wantCode+`"`) {
+					t.Errorf("request %d: body = %s, want code %s", i, w.Body, req.wantCode)
+				}
+				if replayed := w.Header().Get(idempotency.HeaderReplayed) == "true"; replayed != req.replayed {
+					t.Errorf("request %d: replayed = %v, want %v", i, replayed, req.replayed)
+				}
+				if req.replayed && w.Header().Get("Content-Type") != "application/json" {
+					t.Errorf("request %d: Content-Type = %q", i, w.Header().Get("Content-Type"))
+				}
+			}
+		})
+	}
+}
+
+func TestGuardInProgress(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := newGuard(t, time.Hour).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		w.WriteHeader(http.StatusCreated)
+	}))
+	do := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{}`))
+		r.Header.Set(idempotency.HeaderIdempotencyKey, "key-1")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- do() }()
+	<-started
+
+	w := do()
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"code":"`+idempotency.ProblemRequestInProgress+`"`) {
+		t.Fatalf("retry while in progress: status = %d: %s", w.Code, w.Body)
+	}
+	close(finish)
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Fatalf("first request: status = %d: %s", w.Code, w.Body)
+	}
+	if w := do(); w.Code != http.StatusCreated || w.Header().Get(idempotency.HeaderReplayed) != "true" {
+		t.Fatalf("retry after completion: status = %d, replayed = %q", w.Code, w.Header().Get(idempotency.HeaderReplayed))
+	}
+}
+
+func TestGuardScopesKeysByCaller(t *testing.T) {
+	calls := 0
+	guard := newGuard(t, time.Hour)
+	handler := middleware.APIKeys([]string{"billing", "crm"})(guard.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(strconv.Itoa(calls)))
+	})))
+
+	do := func(addr, apiKey string) string {
+		r := httptest.NewRequest(http.MethodPost, "/subscriptions", strings.NewReader(`{}`))
+		r.RemoteAddr = addr
+		r.Header.Set(idempotency.HeaderIdempotencyKey, "key-1")
+		if apiKey != "" {
+			r.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("status = %d: %s", w.Code, w.Body)
+		}
+		return w.Body.String()
+	}
+
+	tests := []struct {
+		name   string
+		addr   string
+		apiKey string
+		want   string
+	}{
+		{"first", "10.0.0.1:1000", "", "1"},
+		{"retry from the same IP", "10.0.0.1:2000", "", "1"},
+		{"another IP", "10.0.0.2:1000", "", "2"},
+		{"API key", "10.0.0.1:1000", "billing", "3"},
+		{"same API key from another IP", "10.0.0.3:1000", "billing", "3"},
+		{"another API key", "10.0.0.1:1000", "crm", "4"},
+		// An unknown key identifies nobody, so the IP scope applies.
+		{"unknown API key", "10.0.0.2:1000", "billing2", "2"},
+	}
+	for _, tt := range tests {
+		if got := do(tt.addr, tt.apiKey); got != tt.want {
+			t.Errorf("%s: response %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
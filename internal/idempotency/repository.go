@@ -0,0 +1,71 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+func (repository *IdempotencyRepository) Get(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	var k models.IdempotencyKey
+	err := repository.db.WithContext(ctx).
+		First(&k, "idempotency_key = ? AND expires_at > ?", key, time.Now().UTC()).Error
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+// Reserve inserts a pending record for the key, replacing an expired one. It
+// returns false without an error if an unexpired record for the key exists.
+func (repository *IdempotencyRepository) Reserve(ctx context.Context, k *models.IdempotencyKey) (bool, error) {
+	reserved := false
+	err := repository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Delete(&models.IdempotencyKey{}, "idempotency_key = ? AND expires_at <= ?", k.Key, time.Now().UTC()).Error
+		if err != nil {
+			return err
+		}
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(k)
+		if result.Error != nil {
+			return result.Error
+		}
+		reserved = result.RowsAffected == 1
+		return nil
+	})
+	return reserved, err
+}
+
+func (repository *IdempotencyRepository) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte, expiresAt time.Time) error {
+	return repository.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("idempotency_key = ?", key).
+		Updates(map[string]any{
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+			"expires_at":    expiresAt,
+		}).Error
+}
+
+func (repository *IdempotencyRepository) Release(ctx context.Context, key string) error {
+	return repository.db.WithContext(ctx).
+		Delete(&models.IdempotencyKey{}, "idempotency_key = ?", key).Error
+}
+
+// DeleteExpired purges all expired records. Reserve doesn't depend on it, it
+// only keeps the table from growing.
+func (repository *IdempotencyRepository) DeleteExpired(ctx context.Context) error {
+	return repository.db.WithContext(ctx).
+		Delete(&models.IdempotencyKey{}, "expires_at <= ?", time.Now().UTC()).Error
+}
@@ -0,0 +1,95 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/SenechkaP/subs-tracker/pkg/requestid"
+	"github.com/sirupsen/logrus"
+)
+
+type requestFieldsKey struct{}
+
+// requestFields is shared by pointer through the request context, so that a
+// user ID found by a handler also reaches the access log written after it.
+type requestFields struct {
+	mu     sync.Mutex
+	userID string
+}
+
+// WithRequestID stores the request ID in ctx, see package requestid, and
+// starts the per-request log fields. It is called once per request by the
+// request ID middleware.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	ctx = requestid.NewContext(ctx, requestID)
+	return context.WithValue(ctx, requestFieldsKey{}, &requestFields{})
+}
+
+func fieldsFromContext(ctx context.Context) *requestFields {
+	fields, _ := ctx.Value(requestFieldsKey{}).(*requestFields)
+	return fields
+}
+
+// RequestID returns the ID of the request served with ctx, or "".
+func RequestID(ctx context.Context) string {
+	return requestid.FromContext(ctx)
+}
+
+// SetUserID records the user the request acts on. Lines logged with ctx
+// afterwards, including the access log line, carry it.
+func SetUserID(ctx context.Context, userID string) {
+	fields := fieldsFromContext(ctx)
+	if fields == nil || userID == "" {
+		return
+	}
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	fields.userID = userID
+}
+
+// Ctx returns Log with the request ID and user ID of ctx attached.
+func Ctx(ctx context.Context) *logrus.Entry {
+	return contextEntry(Log, ctx)
+}
+
+// FromRequest is Ctx plus the matched route. A user_id path value is used
+// when the handler has not set a user ID yet.
+func FromRequest(r *http.Request) *logrus.Entry {
+	return requestEntry(Log, r)
+}
+
+// AccessFromRequest is FromRequest for the access log.
+func AccessFromRequest(r *http.Request) *logrus.Entry {
+	return requestEntry(Access, r)
+}
+
+func contextEntry(logger *logrus.Logger, ctx context.Context) *logrus.Entry {
+	entry := logrus.NewEntry(logger)
+	if requestID := requestid.FromContext(ctx); requestID != "" {
+		entry = entry.WithField(FieldRequestID, requestID)
+	}
+	fields := fieldsFromContext(ctx)
+	if fields == nil {
+		return entry
+	}
+	fields.mu.Lock()
+	defer fields.mu.Unlock()
+	if fields.userID != "" {
+		entry = entry.WithField(FieldUserID, fields.userID)
+	}
+	return entry
+}
+
+func requestEntry(logger *logrus.Logger, r *http.Request) *logrus.Entry {
+	entry := contextEntry(logger, r.Context())
+	if r.Pattern != "" {
+		entry = entry.WithField(FieldRoute, r.Pattern)
+	}
+	if _, ok := entry.Data[FieldUserID]; !ok {
+		if userID := r.PathValue("user_id"); userID != "" {
+			entry = entry.WithField(FieldUserID, userID)
+		}
+	}
+	return entry
+}
@@ -2,10 +2,26 @@ package logger
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Field names shared by the app and access logs.
+const (
+	FieldRequestID = "request_id"
+	FieldRoute     = "route"
+	FieldUserID    = "user_id"
+)
+
 type PlainFormatter struct{}
 
 func (f *PlainFormatter) Format(entry *logrus.Entry) ([]byte, error) {
@@ -13,20 +29,52 @@ func (f *PlainFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 
 	level := entry.Level.String()
 
-	log := fmt.Sprintf("%s %s %s\n",
+	log := fmt.Sprintf("%s %s %s%s\n",
 		timestamp,
 		level,
 		entry.Message,
+		formatFields(entry.Data, nil),
 	)
 
 	return []byte(log), nil
 }
 
+// AccessFormatter prints the access log message followed by the request
+// context fields. The other fields repeat the message and are only useful
+// in JSON.
 type AccessFormatter struct{}
 
+var accessTextFields = []string{FieldRequestID, FieldRoute, FieldUserID}
+
 func (f *AccessFormatter) Format(entry *logrus.Entry) ([]byte, error) {
 	timestamp := entry.Time.Format("2006/01/02 15:04:05")
-	return fmt.Appendf(nil, "%s %s\n", timestamp, entry.Message), nil
+	return fmt.Appendf(nil, "%s %s%s\n", timestamp, entry.Message, formatFields(entry.Data, accessTextFields)), nil
+}
+
+// formatFields renders fields as " key=value" pairs, sorted by key. When only
+// is not nil, the other fields are left out.
+func formatFields(data logrus.Fields, only []string) string {
+	keys := only
+	if keys == nil {
+		keys = make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	var b strings.Builder
+	for _, k := range keys {
+		v, ok := data[k]
+		if !ok {
+			continue
+		}
+		value := fmt.Sprint(v)
+		if strings.ContainsAny(value, " \"=") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, " %s=%s", k, value)
+	}
+	return b.String()
 }
 
 var Log = logrus.New()
@@ -38,3 +86,29 @@ func init() {
 
 	Access.SetFormatter(&AccessFormatter{})
 }
+
+// SetFormat switches both loggers between the plain text formatters and
+// JSON with one object per line.
+func SetFormat(format string) error {
+	switch format {
+	case FormatText:
+		Log.SetFormatter(&PlainFormatter{})
+		Access.SetFormatter(&AccessFormatter{})
+	case FormatJSON:
+		Log.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+		Access.SetFormatter(&logrus.JSONFormatter{TimestampFormat: time.RFC3339Nano})
+	default:
+		return fmt.Errorf("unknown log format %q, expected %s or %s", format, FormatText, FormatJSON)
+	}
+	return nil
+}
+
+// SetLevel sets the level of the app log. The access log is not leveled.
+func SetLevel(level string) error {
+	parsed, err := logrus.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	Log.SetLevel(parsed)
+	return nil
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const CommandUsage = "up | down [N] | to ID | status"
+
+var ErrInvalidCommand = errors.New("invalid migrate command, expected " + CommandUsage)
+
+// RunCommand runs a migrate subcommand given as command-line arguments and
+// returns the resulting status of every migration.
+func RunCommand(db *gorm.DB, args []string) ([]MigrationStatus, error) {
+	if len(args) == 0 {
+		return nil, ErrInvalidCommand
+	}
+
+	var err error
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		err = RunMigrations(db)
+	case args[0] == "down" && len(args) <= 2:
+		n := 1
+		if len(args) == 2 {
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: N must be a positive number", ErrInvalidCommand)
+			}
+		}
+		err = Rollback(db, n)
+	case args[0] == "to" && len(args) == 2:
+		err = MigrateTo(db, args[1])
+	case args[0] == "status" && len(args) == 1:
+	default:
+		return nil, ErrInvalidCommand
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Status(db)
+}
@@ -1,45 +1,200 @@
 package migrations
 
 import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
 	"github.com/go-gormigrate/gormigrate/v2"
 	"gorm.io/gorm"
 )
 
+// Migrations live in sql/ as NNNN_<id>.up.sql and NNNN_<id>.down.sql. The
+// numeric prefix only orders the files; the part after it is the ID recorded
+// in the migrations table, so it must never change once released.
+//
+//go:embed sql/*.sql
+var sqlFiles embed.FS
+
+var ErrUnknownMigration = errors.New("unknown migration")
+
+// GetMigrations returns the embedded migrations in order. The files are part
+// of the binary, so a malformed set is a programming error and panics.
 func GetMigrations() []*gormigrate.Migration {
-	return []*gormigrate.Migration{
-		{
-			ID: "20250917_create_subscriptions",
-			Migrate: func(tx *gorm.DB) error {
-				return tx.Exec(`
-					CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
-
-					CREATE TABLE IF NOT EXISTS subscriptions (
-						id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-						service VARCHAR(255) NOT NULL,
-						price_rub BIGINT NOT NULL,
-						user_id UUID NOT NULL,
-						start_date TIMESTAMP NOT NULL,
-						end_date TIMESTAMP NULL,
-						created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-						updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-					);
-
-					CREATE INDEX idx_subscriptions_id ON subscriptions(id);
-					CREATE INDEX idx_subscriptions_user_id ON subscriptions(user_id);
-				`).Error
-			},
-			Rollback: func(tx *gorm.DB) error {
-				return tx.Exec(`
-					DROP INDEX IF EXISTS idx_subscriptions_id;
-					DROP INDEX IF EXISTS idx_subscriptions_user_id;
-					DROP TABLE IF EXISTS subscriptions;
-				`).Error
-			},
+	migrations, err := loadMigrations(sqlFiles, "sql")
+	if err != nil {
+		panic(err)
+	}
+	return migrations
+}
+
+func loadMigrations(fsys fs.FS, dir string) ([]*gormigrate.Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	up := make(map[string]string)
+	down := make(map[string]string)
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		var base string
+		var target map[string]string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			base, target = strings.TrimSuffix(name, ".up.sql"), up
+			names = append(names, base)
+		case strings.HasSuffix(name, ".down.sql"):
+			base, target = strings.TrimSuffix(name, ".down.sql"), down
+		default:
+			return nil, fmt.Errorf("migration %s: expected .up.sql or .down.sql", name)
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		target[base] = string(body)
+	}
+	sort.Strings(names)
+
+	seen := make(map[string]bool)
+	out := make([]*gormigrate.Migration, 0, len(names))
+	for _, base := range names {
+		_, id, ok := strings.Cut(base, "_")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("migration %s: expected NNNN_<id>", base)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("migration %s: duplicate id %s", base, id)
+		}
+		seen[id] = true
+		rollback, ok := down[base]
+		if !ok {
+			return nil, fmt.Errorf("migration %s: missing %s.down.sql", base, base)
+		}
+		out = append(out, sqlMigration(id, up[base], rollback))
+	}
+	if len(down) != len(up) {
+		return nil, errors.New("migrations: .down.sql without a matching .up.sql")
+	}
+	return out, nil
+}
+
+func sqlMigration(id, up, down string) *gormigrate.Migration {
+	return &gormigrate.Migration{
+		ID: id,
+		Migrate: func(tx *gorm.DB) error {
+			return tx.Exec(up).Error
+		},
+		Rollback: func(tx *gorm.DB) error {
+			return tx.Exec(down).Error
 		},
 	}
 }
 
+func newMigrator(db *gorm.DB) *gormigrate.Gormigrate {
+	return gormigrate.New(db, gormigrate.DefaultOptions, GetMigrations())
+}
+
 func RunMigrations(db *gorm.DB) error {
-	m := gormigrate.New(db, gormigrate.DefaultOptions, GetMigrations())
-	return m.Migrate()
+	return newMigrator(db).Migrate()
+}
+
+// AutoMigrate creates the schema from the models. The versioned migrations
+// above are written for Postgres; this is used for the SQLite backend.
+func AutoMigrate(db *gorm.DB) error {
+	newChangeLog := !db.Migrator().HasTable(&models.SubscriptionChange{})
+	if err := db.AutoMigrate(&models.Subscription{}, &models.IdempotencyKey{}, &models.SubscriptionChange{}); err != nil {
+		return err
+	}
+	if !newChangeLog {
+		return nil
+	}
+	// Like the Postgres migration, start the change log with the
+	// subscriptions that exist already.
+	return db.Exec(`INSERT INTO subscription_changes (op, changed_at, subscription_id, user_id, service, price_rub, start_date, end_date, version, created_at, updated_at)
+		SELECT ?, updated_at, id, user_id, service, price_rub, start_date, end_date, version, created_at, updated_at
+		FROM subscriptions
+		ORDER BY updated_at, id`, models.ChangeCreated).Error
+}
+
+// MigrationStatus tells whether a known migration has been applied.
+type MigrationStatus struct {
+	ID      string `json:"id"`
+	Applied bool   `json:"applied"`
+}
+
+// Rollback reverts the last n applied migrations.
+func Rollback(db *gorm.DB, n int) error {
+	m := newMigrator(db)
+	for i := 0; i < n; i++ {
+		if err := m.RollbackLast(); err != nil {
+			if errors.Is(err, gormigrate.ErrNoRunMigration) {
+				return fmt.Errorf("only %d migrations were applied", i)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// MigrateTo brings the schema to the state right after migration id: it
+// applies the migrations up to id and rolls back the ones after it.
+func MigrateTo(db *gorm.DB, id string) error {
+	m := newMigrator(db)
+	known := false
+	for _, migration := range GetMigrations() {
+		known = known || migration.ID == id
+	}
+	if !known {
+		return fmt.Errorf("%w %q", ErrUnknownMigration, id)
+	}
+	if err := m.MigrateTo(id); err != nil {
+		return err
+	}
+	return m.RollbackTo(id)
+}
+
+// Pending returns the IDs of the migrations that have not been applied yet.
+func Pending(db *gorm.DB) ([]string, error) {
+	status, err := Status(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []string
+	for _, s := range status {
+		if !s.Applied {
+			pending = append(pending, s.ID)
+		}
+	}
+	return pending, nil
+}
+
+// Status reports every known migration in order and whether it has been
+// applied to the database.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	applied := make(map[string]bool)
+	if db.Migrator().HasTable(gormigrate.DefaultOptions.TableName) {
+		var ids []string
+		err := db.Table(gormigrate.DefaultOptions.TableName).
+			Pluck(gormigrate.DefaultOptions.IDColumnName, &ids).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			applied[id] = true
+		}
+	}
+
+	var out []MigrationStatus
+	for _, m := range GetMigrations() {
+		out = append(out, MigrationStatus{ID: m.ID, Applied: applied[m.ID]})
+	}
+	return out, nil
 }
@@ -0,0 +1,99 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/google/uuid"
+)
+
+func TestGetMigrationsKeepsReleasedIDs(t *testing.T) {
+	// These IDs are recorded in deployed databases and must keep their order.
+	want := []string{
+		"20250917_create_subscriptions",
+		"20261019_add_subscription_date_indexes",
+		"20261019_create_idempotency_keys",
+		"20261019_add_subscription_version",
+		"20261019_create_subscription_changes",
+	}
+	got := GetMigrations()
+	if len(got) < len(want) {
+		t.Fatalf("got %d migrations, want at least %d", len(got), len(want))
+	}
+	for i, id := range want {
+		if got[i].ID != id {
+			t.Fatalf("migration %d = %s, want %s", i, got[i].ID, id)
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsMalformedSets(t *testing.T) {
+	file := func(body string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(body)} }
+
+	tests := []struct {
+		name  string
+		files fstest.MapFS
+	}{
+		{name: "missing down", files: fstest.MapFS{"sql/0001_a.up.sql": file("SELECT 1;")}},
+		{name: "missing up", files: fstest.MapFS{
+			"sql/0001_a.up.sql":   file("SELECT 1;"),
+			"sql/0001_a.down.sql": file("SELECT 1;"),
+			"sql/0002_b.down.sql": file("SELECT 1;"),
+		}},
+		{name: "no prefix", files: fstest.MapFS{"sql/a.up.sql": file(""), "sql/a.down.sql": file("")}},
+		{name: "duplicate id", files: fstest.MapFS{
+			"sql/0001_a.up.sql":   file(""),
+			"sql/0001_a.down.sql": file(""),
+			"sql/0002_a.up.sql":   file(""),
+			"sql/0002_a.down.sql": file(""),
+		}},
+		{name: "unknown file", files: fstest.MapFS{"sql/0001_a.sql": file("")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadMigrations(tt.files, "sql"); err == nil {
+				t.Fatal("loadMigrations succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestRunCommandRejectsBadArguments(t *testing.T) {
+	for _, args := range [][]string{nil, {"sideways"}, {"down", "0"}, {"down", "x"}, {"to"}, {"up", "extra"}} {
+		if _, err := RunCommand(nil, args); err == nil {
+			t.Fatalf("RunCommand(%q) succeeded, want an error", args)
+		}
+	}
+}
+
+func TestAutoMigrateBackfillsChangeLog(t *testing.T) {
+	gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	// A database from before the change log.
+	if err := gormDB.AutoMigrate(&models.Subscription{}); err != nil {
+		t.Fatal(err)
+	}
+	sub := models.Subscription{ID: uuid.New(), Service: "A", PriceRUB: 1, UserID: uuid.New(), StartDate: time.Now(), Version: 3}
+	if err := gormDB.Create(&sub).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		if err := AutoMigrate(gormDB); err != nil {
+			t.Fatalf("AutoMigrate: %v", err)
+		}
+	}
+	var changes []models.SubscriptionChange
+	if err := gormDB.Find(&changes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Op != models.ChangeCreated || changes[0].SubscriptionID != sub.ID || changes[0].Version != 3 {
+		t.Fatalf("change log = %+v, want one created entry for %s", changes, sub.ID)
+	}
+}
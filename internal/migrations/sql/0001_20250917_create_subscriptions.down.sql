@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_subscriptions_id;
+DROP INDEX IF EXISTS idx_subscriptions_user_id;
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_subscriptions_start_date;
+DROP INDEX IF EXISTS idx_subscriptions_end_date;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_subscriptions_start_date ON subscriptions(start_date);
+CREATE INDEX IF NOT EXISTS idx_subscriptions_end_date ON subscriptions(end_date);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS subscription_changes (
+    seq BIGSERIAL PRIMARY KEY,
+    op VARCHAR(16) NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    subscription_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    service VARCHAR(255) NOT NULL,
+    price_rub BIGINT NOT NULL,
+    start_date TIMESTAMP NOT NULL,
+    end_date TIMESTAMP NULL,
+    version BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+
+-- Subscriptions that exist already enter the log as created, so that a
+-- consumer reading it from the start sees every subscription.
+INSERT INTO subscription_changes (op, changed_at, subscription_id, user_id, service, price_rub, start_date, end_date, version, created_at, updated_at)
+SELECT 'created', updated_at, id, user_id, service, price_rub, start_date, end_date, version, created_at, updated_at
+FROM subscriptions
+ORDER BY updated_at, id;
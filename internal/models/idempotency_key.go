@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	Key          string `gorm:"column:idempotency_key;primaryKey"`
+	Fingerprint  string `gorm:"not null"`
+	StatusCode   *int   // nil while the original request is still being processed
+	ContentType  string `gorm:"not null;default:''"`
+	ResponseBody []byte
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
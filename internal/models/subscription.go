@@ -14,6 +14,7 @@ type Subscription struct {
 	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
 	StartDate time.Time  `gorm:"not null" json:"start_date"`
 	EndDate   *time.Time `json:"end_date,omitempty"`
+	Version   int64      `gorm:"not null;default:1" json:"version"`
 	CreatedAt time.Time  `json:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change log operations.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// SubscriptionChange is an entry of the subscription change log. Seq grows
+// in commit order. The other fields hold the subscription after the change,
+// or as it was when it got deleted.
+type SubscriptionChange struct {
+	Seq            int64     `gorm:"primaryKey;autoIncrement"`
+	Op             string    `gorm:"not null"`
+	ChangedAt      time.Time `gorm:"not null"`
+	SubscriptionID uuid.UUID `gorm:"type:uuid;not null"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null"`
+	Service        string    `gorm:"not null"`
+	PriceRUB       int64     `gorm:"not null"`
+	StartDate      time.Time `gorm:"not null"`
+	EndDate        *time.Time
+	Version        int64     `gorm:"not null"`
+	CreatedAt      time.Time `gorm:"not null;autoCreateTime:false"`
+	UpdatedAt      time.Time `gorm:"not null;autoUpdateTime:false"`
+}
+
+func NewSubscriptionChange(op string, s *Subscription, changedAt time.Time) SubscriptionChange {
+	return SubscriptionChange{
+		Op:             op,
+		ChangedAt:      changedAt,
+		SubscriptionID: s.ID,
+		UserID:         s.UserID,
+		Service:        s.Service,
+		PriceRUB:       s.PriceRUB,
+		StartDate:      s.StartDate,
+		EndDate:        s.EndDate,
+		Version:        s.Version,
+		CreatedAt:      s.CreatedAt,
+		UpdatedAt:      s.UpdatedAt,
+	}
+}
+
+// Subscription returns the recorded state of the subscription.
+func (c *SubscriptionChange) Subscription() *Subscription {
+	return &Subscription{
+		ID:        c.SubscriptionID,
+		Service:   c.Service,
+		PriceRUB:  c.PriceRUB,
+		UserID:    c.UserID,
+		StartDate: c.StartDate,
+		EndDate:   c.EndDate,
+		Version:   c.Version,
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}
+}
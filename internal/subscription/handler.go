@@ -6,14 +6,14 @@ import (
 	"io"
 	"net/http"
 	"strconv"
-	"time"
 
+	"github.com/SenechkaP/subs-tracker/internal/idempotency"
 	"github.com/SenechkaP/subs-tracker/internal/logger"
 	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
 	"github.com/SenechkaP/subs-tracker/pkg/req"
 	"github.com/SenechkaP/subs-tracker/pkg/res"
 	"github.com/google/uuid"
-	"gorm.io/gorm"
 )
 
 const (
@@ -24,27 +24,148 @@ const (
 	ErrInvalidDateInterval     = "START DATE MUST BE BEFORE OR EQUAL TO END DATE"
 	ErrSubscriptionNotFound    = "SUBSCRIPTION WITH PROVIDED UUID DOESN'T EXIST"
 	ErrEmptyBody               = "BODY IS EMPTY"
+	ErrBodyTooLarge            = "BODY IS TOO LARGE"
 	ErrFetchSubscriptions      = "FAILED TO FETCH SUBSCRIPTIONS"
 	ErrMissingParameter        = "MISSING QUERY PARAMETER"
 	ErrInvalidParameter        = "QUERY PARAMETER IS INVALID"
+	ErrPreconditionFailed      = "SUBSCRIPTION WAS MODIFIED, IF-MATCH PRECONDITION FAILED"
+	ErrIfMatchRequired         = "IF-MATCH HEADER IS REQUIRED"
+	ErrEmptyBatch              = "BATCH CONTAINS NO OPERATIONS"
+	ErrBatchTooLarge           = "BATCH CONTAINS TOO MANY OPERATIONS"
+	ErrInvalidBatchOperation   = "BATCH OPERATION TYPE IS INVALID"
+	ErrOperationNotRun         = "OPERATION WAS NOT RUN BECAUSE AN EARLIER ONE FAILED"
+	ErrValidationFailed        = "REQUEST VALIDATION FAILED"
+	ErrMalformedBody           = "BODY IS NOT VALID JSON"
+	ErrInternal                = "INTERNAL ERROR"
+	ErrInvalidCursor           = "CURSOR IS INVALID"
+	ErrFetchChanges            = "FAILED TO FETCH CHANGES"
+)
+
+// Problem codes are the stable identifiers clients branch on; they are sent
+// as the code of a problem+json response and never change with the message.
+const (
+	ProblemInvalidSubscriptionID = "invalid_subscription_id"
+	ProblemInvalidUserID         = res.ProblemInvalidUserID
+	ProblemInvalidStartDate      = res.ProblemInvalidStartDate
+	ProblemInvalidEndDate        = res.ProblemInvalidEndDate
+	ProblemInvalidDateRange      = res.ProblemInvalidDateRange
+	ProblemSubscriptionNotFound  = "subscription_not_found"
+	ProblemEmptyBody             = "empty_body"
+	ProblemBodyTooLarge          = res.ProblemBodyTooLarge
+	ProblemMalformedBody         = "malformed_body"
+	ProblemMissingParameter      = res.ProblemMissingParameter
+	ProblemInvalidParameter      = res.ProblemInvalidParameter
+	ProblemPreconditionFailed    = "precondition_failed"
+	ProblemIfMatchRequired       = "if_match_required"
+	ProblemEmptyBatch            = "empty_batch"
+	ProblemBatchTooLarge         = "batch_too_large"
+	ProblemInvalidBatchOperation = "invalid_batch_operation"
+	ProblemNotRun                = "not_run"
+	ProblemValidationFailed      = "validation_failed"
+	ProblemInvalidCursor         = "invalid_cursor"
+)
+
+const (
+	WarnOverlappingSubscription = "SUBSCRIPTION OVERLAPS WITH AN EXISTING SUBSCRIPTION FOR THE SAME SERVICE"
 )
 
 type SubscriptionHandlerDeps struct {
-	Repository *SubscriptionRepository
+	Service     *SubscriptionService
+	Idempotency *idempotency.Guard
 }
 
 type SubscriptionHandler struct {
-	Repository *SubscriptionRepository
+	Service *SubscriptionService
 }
 
-func NewSubscriptionHandler(router *http.ServeMux, deps *SubscriptionHandlerDeps) {
-	handler := SubscriptionHandler{Repository: deps.Repository}
+func NewSubscriptionHandler(router apiversion.Router, deps *SubscriptionHandlerDeps) {
+	handler := SubscriptionHandler{Service: deps.Service}
 	router.HandleFunc("GET /subscriptions/{sub_id}", handler.GetSubscription())
-	router.HandleFunc("POST /subscriptions", handler.CreateSubscription())
+	router.Handle("POST /subscriptions", deps.Idempotency.Wrap(handler.CreateSubscription()))
+	router.Handle("POST /subscriptions/batch", deps.Idempotency.Wrap(handler.BatchSubscriptions()))
 	router.HandleFunc("PATCH /subscriptions/{sub_id}", handler.PatchSubscription())
 	router.HandleFunc("DELETE /subscriptions/{sub_id}", handler.DeleteSubscription())
 	router.HandleFunc("GET /subscriptions/sum", handler.GetSubscriptionsSumByMonth())
 	router.HandleFunc("GET /users/{user_id}/subscriptions", handler.GetUserSubscriptions())
+	router.HandleFunc("GET /users/{user_id}/duplicates", handler.GetUserDuplicates())
+	router.HandleFunc("GET /changes", handler.GetChanges())
+}
+
+// HTTPStatus maps an error returned by SubscriptionService to the HTTP
+// status code the API responds with.
+func HTTPStatus(err error) int {
+	var svcErr *ServiceError
+	if !errors.As(err, &svcErr) {
+		return http.StatusInternalServerError
+	}
+	switch svcErr.Kind {
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case KindNotRun:
+		return http.StatusFailedDependency
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// writeServiceError logs err and writes it to the client as a problem.
+// Internal errors are only logged; the client gets internalMsg, or
+// ErrInternal when internalMsg is empty.
+func writeServiceError(w http.ResponseWriter, r *http.Request, op string, err error, internalMsg string) {
+	var svcErr *ServiceError
+	if !errors.As(err, &svcErr) {
+		logger.FromRequest(r).Errorf("%s db error err=%v", op, err)
+		if internalMsg == "" {
+			internalMsg = ErrInternal
+		}
+		res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, internalMsg)
+		return
+	}
+	logger.FromRequest(r).Warnf("%s err=%v", op, svcErr)
+	status := HTTPStatus(err)
+	res.ProblemDump(w, ErrorResponse{
+		Problem:    res.NewProblem(r, status, svcErr.Code, svcErr.Message),
+		Violations: svcErr.Violations,
+	}, status)
+}
+
+// decodeBody reads the request body into T. Unknown and mistyped fields are
+// reported together with the violations validate finds in the rest of the
+// body. On failure it writes the response and returns false.
+func decodeBody[T any](w http.ResponseWriter, r *http.Request, op string, validate func(*T) []FieldViolation) (*T, bool) {
+	body, err := req.HandleBody[T](r)
+	if err == nil {
+		return body, true
+	}
+	found, ok := DecodeViolations(err)
+	if !ok {
+		writeBodyError(w, r, op, err)
+		return nil, false
+	}
+	if validate != nil {
+		found = mergeViolations(found, validate(body))
+	}
+	writeServiceError(w, r, op, invalidRequest(found), "")
+	return nil, false
+}
+
+// writeBodyError reports a request body that req.HandleBody could not read.
+func writeBodyError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	switch {
+	case errors.Is(err, io.EOF):
+		logger.FromRequest(r).Warnf("%s empty body", op)
+		res.WriteProblem(w, r, http.StatusBadRequest, ProblemEmptyBody, ErrEmptyBody)
+	case errors.Is(err, req.ErrBodyTooLarge):
+		logger.FromRequest(r).Warnf("%s body too large err=%v", op, err)
+		res.WriteProblem(w, r, http.StatusRequestEntityTooLarge, ProblemBodyTooLarge, ErrBodyTooLarge)
+	default:
+		logger.FromRequest(r).Warnf("%s bad request parse body err=%v", op, err)
+		res.WriteProblem(w, r, http.StatusBadRequest, ProblemMalformedBody, ErrMalformedBody)
+	}
 }
 
 func (handler *SubscriptionHandler) GetSubscription() http.HandlerFunc {
@@ -52,83 +173,43 @@ func (handler *SubscriptionHandler) GetSubscription() http.HandlerFunc {
 		subIDstring := r.PathValue("sub_id")
 		subID, err := uuid.Parse(subIDstring)
 		if err != nil {
-			logger.Log.Warnf("GetSubscription invalid uuid sub_id=%s err=%v", subIDstring, err)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidSubscriptionUUID}, http.StatusBadRequest)
+			logger.FromRequest(r).Warnf("GetSubscription invalid uuid sub_id=%s err=%v", subIDstring, err)
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidSubscriptionID, ErrInvalidSubscriptionUUID)
 			return
 		}
-		sub, err := handler.Repository.GetByID(r.Context(), subID)
+		sub, err := handler.Service.Get(r.Context(), subID)
 		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				logger.Log.Warnf("GetSubscription not found sub_id=%s", subID.String())
-				res.JsonDump(w, ErrorResponse{Error: ErrSubscriptionNotFound}, http.StatusNotFound)
-				return
-			}
-			logger.Log.Errorf("GetSubscription db error sub_id=%s err=%v", subID.String(), err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusInternalServerError)
+			writeServiceError(w, r, fmt.Sprintf("GetSubscription sub_id=%s", subID), err, "")
 			return
 		}
+		logger.SetUserID(r.Context(), sub.UserID.String())
 
+		w.Header().Set("ETag", formatETag(sub.Version))
 		res.JsonDump(w, sub, http.StatusOK)
 	}
 }
 
 func (handler *SubscriptionHandler) CreateSubscription() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		body, err := req.HandleBody[SubscriptionCreateRequest](r)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				logger.Log.Warnf("CreateSubscription empty body")
-				res.JsonDump(w, ErrorResponse{Error: ErrEmptyBody}, http.StatusBadRequest)
-				return
-			}
-			logger.Log.Warnf("CreateSubscription bad request parse body err=%v", err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusBadRequest)
+		body, ok := decodeBody(w, r, "CreateSubscription", ValidateCreateRequest)
+		if !ok {
 			return
 		}
-		userID, err := uuid.Parse(body.UserID)
-		if err != nil {
-			logger.Log.Warnf("CreateSubscription invalid user uuid user_id=%s", body.UserID)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidUserUUID}, http.StatusBadRequest)
-			return
-		}
-		startDate, err := parseMonthYear(body.StartDate)
+
+		logger.SetUserID(r.Context(), body.UserID)
+		created, err := handler.Service.Create(r.Context(), body)
 		if err != nil {
-			logger.Log.Warnf("CreateSubscription invalid start date user_id=%s start=%s", userID.String(), body.StartDate)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidStartDate}, http.StatusBadRequest)
+			writeServiceError(w, r, fmt.Sprintf("CreateSubscription user_id=%s service=%s", body.UserID, body.Service), err, "")
 			return
 		}
-		var endDate *time.Time
-		if body.EndDate != nil && *body.EndDate != "" {
-			t, err := parseMonthYear(*body.EndDate)
-			if err != nil {
-				logger.Log.Warnf("CreateSubscription invalid start date user_id=%s end=%s", userID.String(), *body.EndDate)
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidEndDate}, http.StatusBadRequest)
-				return
-			}
-			endDate = &t
-			if endDate.Before(startDate) {
-				logger.Log.Warnf("CreateSubscription invalid interval user_id=%s start=%s end=%s", userID.String(), body.StartDate, *body.EndDate)
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidDateInterval}, http.StatusBadRequest)
-				return
-			}
-		}
 
-		sub := &models.Subscription{
-			Service:   body.Service,
-			PriceRUB:  body.PriceRUB,
-			UserID:    userID,
-			StartDate: startDate,
-			EndDate:   endDate,
+		out := SubscriptionCreateResponse{SubID: created.Subscription.ID.String()}
+		if len(created.OverlapsWith) > 0 {
+			logger.FromRequest(r).Infof("CreateSubscription overlapping subscription sub_id=%s overlaps=%v", out.SubID, created.OverlapsWith)
+			out.Warning = WarnOverlappingSubscription
+			out.OverlapsWith = created.OverlapsWith
 		}
-		sub.GenerateNewUUID(handler.Repository.db)
-
-		if err = handler.Repository.Create(r.Context(), sub); err != nil {
-			logger.Log.Errorf("CreateSubscription db error user_id=%s service=%s err=%v", userID.String(), body.Service, err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusInternalServerError)
-			return
-		}
-
-		res.JsonDump(w, SubscriptionCreateResponse{SubID: sub.ID.String()}, http.StatusOK)
+		res.JsonDump(w, out, http.StatusOK)
 	}
 }
 
@@ -137,75 +218,23 @@ func (handler *SubscriptionHandler) PatchSubscription() http.HandlerFunc {
 		subIDstring := r.PathValue("sub_id")
 		subID, err := uuid.Parse(subIDstring)
 		if err != nil {
-			logger.Log.Warnf("PatchSubscription invalid sub uuid sub_id=%s", subIDstring)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidSubscriptionUUID}, http.StatusBadRequest)
-			return
-		}
-		body, err := req.HandleBody[SubscriptionPatchRequest](r)
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				logger.Log.Warnf("PatchSubscription empty body")
-				res.JsonDump(w, ErrorResponse{Error: ErrEmptyBody}, http.StatusBadRequest)
-				return
-			}
-			logger.Log.Warnf("PatchSubscription bad request parse body err=%v", err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusBadRequest)
-			return
-		}
-
-		existingSub, err := handler.Repository.GetByID(r.Context(), subID)
-		if err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				logger.Log.Warnf("PatchSubscription not found sub_id=%s", subID.String())
-				res.JsonDump(w, ErrorResponse{Error: ErrSubscriptionNotFound}, http.StatusNotFound)
-				return
-			}
-			logger.Log.Errorf("PatchSubscription db error sub_id=%s err=%v", subID.String(), err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusInternalServerError)
+			logger.FromRequest(r).Warnf("PatchSubscription invalid sub uuid sub_id=%s", subIDstring)
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidSubscriptionID, ErrInvalidSubscriptionUUID)
 			return
 		}
-
-		if body.PriceRUB != nil {
-			existingSub.PriceRUB = *body.PriceRUB
-		}
-
-		if body.StartDate != nil {
-			startDate, err := parseMonthYear(*body.StartDate)
-			if err != nil {
-				logger.Log.Warnf("PatchSubscription invalid start date sub_id=%s start=%s", subID.String(), *body.StartDate)
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidStartDate}, http.StatusBadRequest)
-				return
-			}
-			existingSub.StartDate = startDate
-		}
-
-		if body.EndDate != nil {
-			if *body.EndDate == "" {
-				existingSub.EndDate = nil
-			} else {
-				endDate, err := parseMonthYear(*body.EndDate)
-				if err != nil {
-					logger.Log.Warnf("PatchSubscription invalid end date sub_id=%s end=%s", subID.String(), *body.EndDate)
-					res.JsonDump(w, ErrorResponse{Error: ErrInvalidEndDate}, http.StatusBadRequest)
-					return
-				}
-				existingSub.EndDate = &endDate
-			}
-		}
-
-		if existingSub.EndDate != nil && existingSub.EndDate.Before(existingSub.StartDate) {
-			logger.Log.Warnf("PatchSubscription invalid interval sub_id=%s start=%s end=%s", subID.String(), *body.StartDate, *body.EndDate)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidDateInterval}, http.StatusBadRequest)
+		body, ok := decodeBody(w, r, "PatchSubscription", ValidatePatchRequest)
+		if !ok {
 			return
 		}
 
-		sub, err := handler.Repository.Update(r.Context(), existingSub)
+		sub, err := handler.Service.Patch(r.Context(), subID, body, r.Header.Get("If-Match"))
 		if err != nil {
-			logger.Log.Errorf("PatchSubscription db error sub_id=%s err=%v", subID.String(), err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusInternalServerError)
+			writeServiceError(w, r, fmt.Sprintf("PatchSubscription sub_id=%s", subID), err, "")
 			return
 		}
+		logger.SetUserID(r.Context(), sub.UserID.String())
 
+		w.Header().Set("ETag", formatETag(sub.Version))
 		res.JsonDump(w, sub, http.StatusOK)
 	}
 }
@@ -215,18 +244,12 @@ func (handler *SubscriptionHandler) DeleteSubscription() http.HandlerFunc {
 		subIDstring := r.PathValue("sub_id")
 		subID, err := uuid.Parse(subIDstring)
 		if err != nil {
-			logger.Log.Warnf("DeleteSubscription invalid sub uuid sub_id=%s", subIDstring)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidSubscriptionUUID}, http.StatusBadRequest)
+			logger.FromRequest(r).Warnf("DeleteSubscription invalid sub uuid sub_id=%s", subIDstring)
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidSubscriptionID, ErrInvalidSubscriptionUUID)
 			return
 		}
-		if err = handler.Repository.Delete(r.Context(), subID); err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				logger.Log.Warnf("DeleteSubscription not found sub_id=%s", subID.String())
-				res.JsonDump(w, ErrorResponse{Error: ErrSubscriptionNotFound}, http.StatusNotFound)
-				return
-			}
-			logger.Log.Errorf("DeleteSubscription db error sub_id=%s err=%v", subID.String(), err)
-			res.JsonDump(w, ErrorResponse{Error: err.Error()}, http.StatusInternalServerError)
+		if err = handler.Service.Delete(r.Context(), subID, r.Header.Get("If-Match")); err != nil {
+			writeServiceError(w, r, fmt.Sprintf("DeleteSubscription sub_id=%s", subID), err, "")
 			return
 		}
 		res.JsonDump(
@@ -243,8 +266,8 @@ func (handler *SubscriptionHandler) GetUserSubscriptions() http.HandlerFunc {
 		userIDstring := r.PathValue("user_id")
 		userID, err := uuid.Parse(userIDstring)
 		if err != nil {
-			logger.Log.Warnf("GetUserSubscriptions invalid user uuid user_id=%s", userIDstring)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidUserUUID}, http.StatusBadRequest)
+			logger.FromRequest(r).Warnf("GetUserSubscriptions invalid user uuid user_id=%s", userIDstring)
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidUserID, ErrInvalidUserUUID)
 			return
 		}
 
@@ -253,27 +276,26 @@ func (handler *SubscriptionHandler) GetUserSubscriptions() http.HandlerFunc {
 		limit := 10
 
 		if offsetStr := q.Get("offset"); offsetStr != "" {
-			if v, err := strconv.Atoi(offsetStr); err == nil && v >= 0 {
+			if v, err := strconv.Atoi(offsetStr); err == nil {
 				offset = v
 			} else {
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidParameter}, http.StatusBadRequest)
+				res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, ErrInvalidParameter)
 				return
 			}
 		}
 
 		if limitStr := q.Get("limit"); limitStr != "" {
-			if v, err := strconv.Atoi(limitStr); err == nil && v > 0 {
+			if v, err := strconv.Atoi(limitStr); err == nil {
 				limit = v
 			} else {
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidParameter}, http.StatusBadRequest)
+				res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, ErrInvalidParameter)
 				return
 			}
 		}
 
-		subList, err := handler.Repository.ListByUser(r.Context(), userID, offset, limit)
+		subList, err := handler.Service.ListByUser(r.Context(), userID, offset, limit)
 		if err != nil {
-			logger.Log.Errorf("GetUserSubscriptions db error user_id=%s err=%v", userID.String(), err)
-			res.JsonDump(w, ErrorResponse{Error: ErrFetchSubscriptions}, http.StatusInternalServerError)
+			writeServiceError(w, r, fmt.Sprintf("GetUserSubscriptions user_id=%s", userID), err, ErrFetchSubscriptions)
 			return
 		}
 
@@ -281,46 +303,40 @@ func (handler *SubscriptionHandler) GetUserSubscriptions() http.HandlerFunc {
 	}
 }
 
-func (handler *SubscriptionHandler) GetSubscriptionsSumByMonth() http.HandlerFunc {
+func (handler *SubscriptionHandler) GetUserDuplicates() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		q := r.URL.Query()
-
-		startParam := q.Get("start")
-		endParam := q.Get("end")
-		if startParam == "" || endParam == "" {
-			logger.Log.Warnf("GetSubscriptionsSumByMonth missing params")
-			res.JsonDump(w, ErrorResponse{Error: ErrMissingParameter}, http.StatusBadRequest)
-			return
-		}
-
-		start, err := parseMonthYear(startParam)
+		userIDstring := r.PathValue("user_id")
+		userID, err := uuid.Parse(userIDstring)
 		if err != nil {
-			logger.Log.Warnf("GetSubscriptionsSumByMonth invalid start date param=%s err=%v", startParam, err)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidStartDate}, http.StatusBadRequest)
+			logger.FromRequest(r).Warnf("GetUserDuplicates invalid user uuid user_id=%s", userIDstring)
+			res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidUserID, ErrInvalidUserUUID)
 			return
 		}
-		end, err := parseMonthYear(endParam)
+
+		duplicates, err := handler.Service.Duplicates(r.Context(), userID)
 		if err != nil {
-			logger.Log.Warnf("GetSubscriptionsSumByMonth invalid end date param=%s err=%v", endParam, err)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidEndDate}, http.StatusBadRequest)
+			writeServiceError(w, r, fmt.Sprintf("GetUserDuplicates user_id=%s", userID), err, ErrFetchSubscriptions)
 			return
 		}
 
-		if end.Before(start) {
-			logger.Log.Warnf("GetSubscriptionsSumByMonth invalid interval start=%s end=%s", startParam, endParam)
-			res.JsonDump(w, ErrorResponse{Error: ErrInvalidDateInterval}, http.StatusBadRequest)
-			return
-		}
+		res.JsonDump(w, DuplicateSubscriptionsResponse{Duplicates: duplicates}, http.StatusOK)
+	}
+}
+
+func (handler *SubscriptionHandler) GetSubscriptionsSumByMonth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
 
 		var userID *uuid.UUID
 		if userParam := q.Get("user_id"); userParam != "" {
 			uid, err := uuid.Parse(userParam)
 			if err != nil {
-				logger.Log.Warnf("GetSubscriptionsSumByMonth invalid user uuid user_id=%s", userParam)
-				res.JsonDump(w, ErrorResponse{Error: ErrInvalidUserUUID}, http.StatusBadRequest)
+				logger.FromRequest(r).Warnf("GetSubscriptionsSumByMonth invalid user uuid user_id=%s", userParam)
+				res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidUserID, ErrInvalidUserUUID)
 				return
 			}
 			userID = &uid
+			logger.SetUserID(r.Context(), uid.String())
 		}
 
 		var service *string
@@ -328,13 +344,106 @@ func (handler *SubscriptionHandler) GetSubscriptionsSumByMonth() http.HandlerFun
 			service = &s
 		}
 
-		sum, err := handler.Repository.SumPriceByMonthRange(r.Context(), start, end, userID, service)
+		sum, err := handler.Service.SumByMonthRange(r.Context(), q.Get("start"), q.Get("end"), userID, service)
 		if err != nil {
-			logger.Log.Errorf("GetSubscriptionsSumByMonth db error: %v", err)
-			res.JsonDump(w, ErrorResponse{Error: ErrFetchSubscriptions}, http.StatusInternalServerError)
+			writeServiceError(w, r, fmt.Sprintf("GetSubscriptionsSumByMonth start=%s end=%s", q.Get("start"), q.Get("end")), err, ErrFetchSubscriptions)
 			return
 		}
 
 		res.JsonDump(w, SubscriptionsPriceSumResponse{PriceSum: sum}, http.StatusOK)
 	}
 }
+
+func (handler *SubscriptionHandler) BatchSubscriptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, ok := decodeBody[BatchRequest](w, r, "BatchSubscriptions", nil)
+		if !ok {
+			return
+		}
+
+		results, committed, err := handler.Service.Batch(r.Context(), body.Operations)
+		if err != nil {
+			writeServiceError(w, r, fmt.Sprintf("BatchSubscriptions operations=%d", len(body.Operations)), err, "")
+			return
+		}
+
+		out, status := NewBatchResponse(results, committed)
+		for i, result := range results {
+			if result.Err != nil && !errors.Is(result.Err, ErrNotRun) {
+				logger.FromRequest(r).Warnf("BatchSubscriptions rolled back index=%d op=%s status=%d err=%v", i, result.Op, status, result.Err)
+			}
+		}
+		if committed {
+			logger.FromRequest(r).Infof("BatchSubscriptions committed operations=%d", len(results))
+		}
+		res.JsonDump(w, out, status)
+	}
+}
+
+// NewBatchResponse renders the results of SubscriptionService.Batch. status
+// is the status of the failed operation, not of those skipped after it, or
+// 200 if none failed. Internal errors are reported as ErrInternal.
+func NewBatchResponse(results []BatchResult, committed bool) (out *BatchResponse, status int) {
+	out = &BatchResponse{Committed: committed, Results: make([]BatchOperationResult, 0, len(results))}
+	status = http.StatusOK
+	for i, result := range results {
+		item := BatchOperationResult{
+			Index:        i,
+			Op:           result.Op,
+			Status:       http.StatusOK,
+			ID:           result.ID,
+			Subscription: result.Subscription,
+		}
+		if result.Err != nil {
+			item.Status = HTTPStatus(result.Err)
+			item.Code, item.Error = res.ProblemInternal, ErrInternal
+			var svcErr *ServiceError
+			if errors.As(result.Err, &svcErr) {
+				item.Code, item.Error, item.Violations = svcErr.Code, svcErr.Message, svcErr.Violations
+			}
+			if !errors.Is(result.Err, ErrNotRun) {
+				status = item.Status
+			}
+		}
+		out.Results = append(out.Results, item)
+	}
+	return out, status
+}
+
+func (handler *SubscriptionHandler) GetChanges() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		limit := DefaultChangesLimit
+		if limitStr := q.Get("limit"); limitStr != "" {
+			v, err := strconv.Atoi(limitStr)
+			if err != nil {
+				res.WriteProblem(w, r, http.StatusBadRequest, ProblemInvalidParameter, ErrInvalidParameter)
+				return
+			}
+			limit = v
+		}
+
+		page, err := handler.Service.Changes(r.Context(), q.Get("since"), limit)
+		if err != nil {
+			writeServiceError(w, r, fmt.Sprintf("GetChanges since=%s limit=%d", q.Get("since"), limit), err, ErrFetchChanges)
+			return
+		}
+
+		out := ChangesResponse{Changes: make([]Change, 0, len(page.Changes)), NextCursor: page.NextCursor, HasMore: page.HasMore}
+		for _, c := range page.Changes {
+			item := Change{
+				Cursor:         strconv.FormatInt(c.Seq, 10),
+				Op:             c.Op,
+				SubscriptionID: c.SubscriptionID.String(),
+				UserID:         c.UserID.String(),
+				Version:        c.Version,
+				ChangedAt:      c.ChangedAt,
+			}
+			if c.Op != models.ChangeDeleted {
+				item.Subscription = c.Subscription()
+			}
+			out.Changes = append(out.Changes, item)
+		}
+		res.JsonDump(w, out, http.StatusOK)
+	}
+}
@@ -0,0 +1,399 @@
+package subscription_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"github.com/google/uuid"
+)
+
+func newRouter() *http.ServeMux {
+	router := http.NewServeMux()
+	subscription.NewSubscriptionHandler(router, &subscription.SubscriptionHandlerDeps{Service: newService(false)})
+	return router
+}
+
+func TestHandlerReportsAllViolations(t *testing.T) {
+	router := newRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   []string
+	}{
+		{
+			name:   "create",
+			method: "POST",
+			path:   "/subscriptions",
+			body:   `{"service_name":"","price":"free","user_id":"nope","start_date":"01-2025","color":"red","Price":1}`,
+			want:   []string{"price invalid_type", "Price unknown_field", "color unknown_field", "service_name required", "user_id invalid_format"},
+		},
+		{
+			name:   "create with several mistyped values",
+			method: "POST",
+			path:   "/subscriptions",
+			body:   `{"service_name":7,"price":"free","user_id":"6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e","start_date":["01-2025"]}`,
+			want:   []string{"price invalid_type", "service_name invalid_type", "start_date invalid_type"},
+		},
+		{
+			name:   "patch",
+			method: "PATCH",
+			path:   "/subscriptions/6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e",
+			body:   `{"service_name":"Netflix","price":-1}`,
+			want:   []string{"service_name unknown_field", "price out_of_range"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body)
+			}
+			var resp subscription.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range resp.Violations {
+				got = append(got, v.Field+" "+v.Code)
+			}
+			if resp.Code != subscription.ProblemValidationFailed || strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Fatalf("got %s %v, want %s %v", resp.Code, got, subscription.ProblemValidationFailed, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerBatchReportsViolations(t *testing.T) {
+	router := newRouter()
+	body := `{"operations":[{"op":"create","data":{"service_name":"Netflix","price":100,"user_id":"6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e","start_date":"01-2025","extra":1}},{"op":"delete","id":"0b4a3c1e-9d2f-4e6a-8b7c-5d4e3f2a1b0c"}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/subscriptions/batch", strings.NewReader(body)))
+
+	var resp subscription.BatchResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || resp.Committed || len(resp.Results) != 2 {
+		t.Fatalf("status = %d, response = %+v", w.Code, resp)
+	}
+	if skipped := resp.Results[1]; skipped.Index != 1 || skipped.Status != http.StatusFailedDependency || skipped.Code != subscription.ProblemNotRun {
+		t.Fatalf("operation after the failed one = %+v, want it not run", skipped)
+	}
+	violations := resp.Results[0].Violations
+	if len(violations) != 1 || violations[0].Field != "extra" || violations[0].Code != subscription.CodeUnknownField {
+		t.Fatalf("violations = %+v, want extra unknown_field", violations)
+	}
+}
+
+// failingRepository fails every lookup the way a broken database would.
+type failingRepository struct {
+	*subscription.MemoryRepository
+}
+
+func (failingRepository) GetByID(context.Context, uuid.UUID) (*models.Subscription, error) {
+	return nil, errors.New(`pq: relation "subscriptions" does not exist`)
+}
+
+func TestHandlerWritesProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		repository subscription.Repository
+		status     int
+		code       string
+	}{
+		{name: "not found", repository: subscription.NewMemoryRepository(), status: http.StatusNotFound, code: subscription.ProblemSubscriptionNotFound},
+		{name: "internal", repository: failingRepository{subscription.NewMemoryRepository()}, status: http.StatusInternalServerError, code: res.ProblemInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := http.NewServeMux()
+			service := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{Repository: tt.repository})
+			subscription.NewSubscriptionHandler(router, &subscription.SubscriptionHandlerDeps{Service: service})
+
+			path := "/subscriptions/" + uuid.NewString()
+			r := httptest.NewRequest("GET", path, nil)
+			r = r.WithContext(logger.WithRequestID(r.Context(), "req-1"))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Type"); got != res.ContentTypeProblem {
+				t.Fatalf("Content-Type = %q, want %q", got, res.ContentTypeProblem)
+			}
+			if strings.Contains(w.Body.String(), "relation") {
+				t.Fatalf("response leaks the database error: %s", w.Body)
+			}
+			var resp subscription.ErrorResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			want := res.Problem{
+				Type:      res.ProblemTypePrefix + tt.code,
+				Code:      tt.code,
+				Title:     http.StatusText(tt.status),
+				Status:    tt.status,
+				Detail:    resp.Detail,
+				Instance:  path,
+				RequestID: "req-1",
+			}
+			if w.Code != tt.status || resp.Problem != want || resp.Detail == "" {
+				t.Fatalf("got %d %+v, want %d %+v", w.Code, resp.Problem, tt.status, want)
+			}
+		})
+	}
+}
+
+func TestHandlerChanges(t *testing.T) {
+	router := newRouter()
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+	changes := func(path string) subscription.ChangesResponse {
+		t.Helper()
+		w := do("GET", path, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: status = %d: %s", path, w.Code, w.Body)
+		}
+		var resp subscription.ChangesResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	if resp := changes("/changes"); len(resp.Changes) != 0 || resp.NextCursor != "0" || resp.HasMore {
+		t.Fatalf("empty log = %+v", resp)
+	}
+	var created subscription.SubscriptionCreateResponse
+	w := do("POST", "/subscriptions", `{"service_name":"Netflix","price":100,"user_id":"6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e","start_date":"01-2025"}`)
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	do("PATCH", "/subscriptions/"+created.SubID, `{"price":200}`)
+	do("DELETE", "/subscriptions/"+created.SubID, "")
+
+	first := changes("/changes?limit=2")
+	if len(first.Changes) != 2 || !first.HasMore || first.NextCursor != first.Changes[1].Cursor {
+		t.Fatalf("first page = %+v", first)
+	}
+	if c := first.Changes[1]; c.Op != models.ChangeUpdated || c.Subscription == nil || c.Subscription.PriceRUB != 200 || c.Version != 2 {
+		t.Fatalf("update = %+v", c)
+	}
+	rest := changes("/changes?since=" + first.NextCursor)
+	if len(rest.Changes) != 1 || rest.HasMore || rest.Changes[0].Op != models.ChangeDeleted || rest.Changes[0].Subscription != nil {
+		t.Fatalf("second page = %+v", rest)
+	}
+	if resp := changes("/changes?since=" + rest.NextCursor); len(resp.Changes) != 0 || resp.NextCursor != rest.NextCursor {
+		t.Fatalf("caught up = %+v", resp)
+	}
+
+	for path, code := range map[string]string{
+		"/changes?since=abc":  subscription.ProblemInvalidCursor,
+		"/changes?since=-1":   subscription.ProblemInvalidCursor,
+		"/changes?limit=0":    subscription.ProblemInvalidParameter,
+		"/changes?limit=1001": subscription.ProblemInvalidParameter,
+		"/changes?limit=lots": subscription.ProblemInvalidParameter,
+	} {
+		w := do("GET", path, "")
+		var resp subscription.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Code != code {
+			t.Errorf("GET %s = %d %s, want 400 %s", path, w.Code, resp.Code, code)
+		}
+	}
+}
+
+func TestHandlerDuplicates(t *testing.T) {
+	router := newRouter()
+	userID := uuid.NewString()
+	create := func(body string) subscription.SubscriptionCreateResponse {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/subscriptions", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("create: status = %d: %s", w.Code, w.Body)
+		}
+		var resp subscription.SubscriptionCreateResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	first := create(`{"service_name":"Netflix","price":100,"user_id":"` + userID + `","start_date":"01-2025","end_date":"06-2025"}`)
+	if first.Warning != "" || first.OverlapsWith != nil {
+		t.Fatalf("first subscription = %+v, want no warning", first)
+	}
+	// Starts the month after the first one ends, so it doesn't overlap.
+	if next := create(`{"service_name":"Netflix","price":100,"user_id":"` + userID + `","start_date":"07-2025"}`); next.Warning != "" {
+		t.Fatalf("adjacent subscription = %+v, want no warning", next)
+	}
+	overlapping := create(`{"service_name":" NETFLIX ","price":150,"user_id":"` + userID + `","start_date":"06-2025","end_date":"06-2025"}`)
+	if overlapping.Warning != subscription.WarnOverlappingSubscription || len(overlapping.OverlapsWith) != 1 || overlapping.OverlapsWith[0] != first.SubID {
+		t.Fatalf("overlapping subscription = %+v, want a warning naming %s", overlapping, first.SubID)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/"+userID+"/duplicates", nil))
+	var resp subscription.DuplicateSubscriptionsResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(resp.Duplicates) != 1 {
+		t.Fatalf("duplicates = %d %+v, want one pair", w.Code, resp)
+	}
+	pair := resp.Duplicates[0]
+	if pair.Service != "netflix" || pair.OverlapStart != "06-2025" || pair.OverlapEnd == nil || *pair.OverlapEnd != "06-2025" {
+		t.Fatalf("pair = %+v, want netflix overlapping in 06-2025", pair)
+	}
+	if ids := pair.Subscriptions[0].ID.String() + " " + pair.Subscriptions[1].ID.String(); ids != first.SubID+" "+overlapping.SubID {
+		t.Fatalf("pair subscriptions = %s, want %s and %s", ids, first.SubID, overlapping.SubID)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/"+uuid.NewString()+"/duplicates", nil))
+	if body := strings.TrimSpace(w.Body.String()); w.Code != http.StatusOK || body != `{"duplicates":[]}` {
+		t.Fatalf("user without subscriptions = %d %s, want an empty list", w.Code, body)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/nope/duplicates", nil))
+	var problem subscription.ErrorResponse
+	json.NewDecoder(w.Body).Decode(&problem)
+	if w.Code != http.StatusBadRequest || problem.Code != subscription.ProblemInvalidUserID {
+		t.Fatalf("bad user = %d %s, want 400 %s", w.Code, problem.Code, subscription.ProblemInvalidUserID)
+	}
+}
+
+func TestHandlerIfMatch(t *testing.T) {
+	for _, requireIfMatch := range []bool{false, true} {
+		router := http.NewServeMux()
+		subscription.NewSubscriptionHandler(router, &subscription.SubscriptionHandlerDeps{Service: newService(requireIfMatch)})
+		do := func(method, path, ifMatch, body string) *httptest.ResponseRecorder {
+			t.Helper()
+			r := httptest.NewRequest(method, path, strings.NewReader(body))
+			if ifMatch != "" {
+				r.Header.Set("If-Match", ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			return w
+		}
+		expect := func(w *httptest.ResponseRecorder, status int, code string) {
+			t.Helper()
+			if w.Code != status {
+				t.Fatalf("require If-Match %t: status = %d, want %d: %s", requireIfMatch, w.Code, status, w.Body)
+			}
+			if code == "" {
+				return
+			}
+			var resp subscription.ErrorResponse
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Code != code {
+				t.Fatalf("require If-Match %t: code = %s, want %s", requireIfMatch, resp.Code, code)
+			}
+		}
+
+		var created subscription.SubscriptionCreateResponse
+		w := do("POST", "/subscriptions", "", `{"service_name":"Netflix","price":100,"user_id":"6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e","start_date":"01-2025"}`)
+		if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+		path := "/subscriptions/" + created.SubID
+
+		if etag := do("GET", path, "", "").Header().Get("ETag"); etag != `"1"` {
+			t.Fatalf("GET ETag = %s, want \"1\"", etag)
+		}
+		expect(do("PATCH", path, `"2"`, `{"price":200}`), http.StatusPreconditionFailed, subscription.ProblemPreconditionFailed)
+		// If-Match uses strong comparison, so a weak tag never matches.
+		expect(do("PATCH", path, `W/"1"`, `{"price":200}`), http.StatusPreconditionFailed, subscription.ProblemPreconditionFailed)
+		if requireIfMatch {
+			expect(do("PATCH", path, "", `{"price":200}`), http.StatusPreconditionRequired, subscription.ProblemIfMatchRequired)
+			expect(do("DELETE", path, "", ""), http.StatusPreconditionRequired, subscription.ProblemIfMatchRequired)
+		}
+
+		w = do("PATCH", path, `"1"`, `{"price":200}`)
+		expect(w, http.StatusOK, "")
+		if etag := w.Header().Get("ETag"); etag != `"2"` {
+			t.Fatalf("PATCH ETag = %s, want \"2\"", etag)
+		}
+		expect(do("DELETE", path, `"1"`, ""), http.StatusPreconditionFailed, subscription.ProblemPreconditionFailed)
+		expect(do("DELETE", path, `"2"`, ""), http.StatusOK, "")
+		expect(do("GET", path, "", ""), http.StatusNotFound, subscription.ProblemSubscriptionNotFound)
+	}
+}
+
+func TestHandlerBatch(t *testing.T) {
+	const create = `{"op":"create","data":{"service_name":"Netflix","price":100,"user_id":"6f1b7c52-6a55-4b25-8a43-0d3a5f5f1f6e","start_date":"01-2025"}}`
+	tests := []struct {
+		name       string
+		body       string
+		status     int
+		committed  bool
+		codes      []string
+		wantDetail string
+	}{
+		{name: "committed", body: `{"operations":[` + create + `,` + create + `]}`, status: http.StatusOK, committed: true, codes: []string{"", ""}},
+		{name: "data is not an object", body: `{"operations":[` + create + `,{"op":"create","data":[1]},` + create + `]}`,
+			status: http.StatusBadRequest, codes: []string{"", subscription.ProblemMalformedBody, subscription.ProblemNotRun}, wantDetail: subscription.ErrMalformedBody},
+		{name: "data is missing", body: `{"operations":[{"op":"create"}]}`,
+			status: http.StatusBadRequest, codes: []string{subscription.ProblemEmptyBody}, wantDetail: subscription.ErrEmptyBody},
+		{name: "unknown operation", body: `{"operations":[{"op":"upsert"},` + create + `]}`,
+			status: http.StatusBadRequest, codes: []string{subscription.ProblemInvalidBatchOperation, subscription.ProblemNotRun}, wantDetail: subscription.ErrInvalidBatchOperation},
+		{name: "missing subscription", body: `{"operations":[{"op":"delete","id":"0b4a3c1e-9d2f-4e6a-8b7c-5d4e3f2a1b0c"}]}`,
+			status: http.StatusNotFound, codes: []string{subscription.ProblemSubscriptionNotFound}, wantDetail: subscription.ErrSubscriptionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newRouter()
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/subscriptions/batch", strings.NewReader(tt.body)))
+			if strings.Contains(w.Body.String(), "subscription.") {
+				t.Fatalf("response leaks a Go type name: %s", w.Body)
+			}
+			var resp subscription.BatchResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.status || resp.Committed != tt.committed || len(resp.Results) != len(tt.codes) {
+				t.Fatalf("got %d %+v, want %d committed=%t with %d results", w.Code, resp, tt.status, tt.committed, len(tt.codes))
+			}
+			for i, code := range tt.codes {
+				if result := resp.Results[i]; result.Index != i || result.Code != code {
+					t.Errorf("results[%d] = %+v, want code %q", i, result, code)
+				}
+				if code != "" && code != subscription.ProblemNotRun && resp.Results[i].Error != tt.wantDetail {
+					t.Errorf("results[%d] error = %q, want %q", i, resp.Results[i].Error, tt.wantDetail)
+				}
+			}
+		})
+	}
+
+	for body, code := range map[string]string{
+		`{"operations":[]}`: subscription.ProblemEmptyBatch,
+		`{"operations":[` + strings.Repeat(create+",", 1000) + create + `]}`: subscription.ProblemBatchTooLarge,
+	} {
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, httptest.NewRequest("POST", "/subscriptions/batch", strings.NewReader(body)))
+		var resp subscription.ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusBadRequest || resp.Code != code {
+			t.Errorf("batch = %d %s, want 400 %s", w.Code, resp.Code, code)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package subscription
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/google/uuid"
+)
+
+var errDuplicateID = errors.New("subscription with this id already exists")
+
+// MemoryRepository implements Repository in process memory. It is meant for
+// tests and local runs without a database.
+type MemoryRepository struct {
+	// mu is nil for the repository handed to a Transaction callback, since
+	// the parent already holds the lock for the whole transaction.
+	mu      *sync.RWMutex
+	subs    map[uuid.UUID]models.Subscription
+	changes []models.SubscriptionChange
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		mu:   &sync.RWMutex{},
+		subs: make(map[uuid.UUID]models.Subscription),
+	}
+}
+
+func (repository *MemoryRepository) lock() func() {
+	if repository.mu == nil {
+		return func() {}
+	}
+	repository.mu.Lock()
+	return repository.mu.Unlock
+}
+
+func (repository *MemoryRepository) rlock() func() {
+	if repository.mu == nil {
+		return func() {}
+	}
+	repository.mu.RLock()
+	return repository.mu.RUnlock
+}
+
+// Transaction runs fn against a copy of the data and swaps it in only if fn
+// succeeds. Transactions are serialized with all other writes.
+func (repository *MemoryRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	unlock := repository.lock()
+	defer unlock()
+
+	// Appends of tx to changes may write past its length into the shared
+	// array; that is harmless, as nobody else writes while the lock is held.
+	tx := &MemoryRepository{subs: maps.Clone(repository.subs), changes: repository.changes}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	repository.subs = tx.subs
+	repository.changes = tx.changes
+	return nil
+}
+
+func (repository *MemoryRepository) Create(ctx context.Context, s *models.Subscription) error {
+	unlock := repository.lock()
+	defer unlock()
+
+	s.GenerateNewUUID(nil)
+	if _, exists := repository.subs[s.ID]; exists {
+		return errDuplicateID
+	}
+	now := time.Now()
+	if s.CreatedAt.IsZero() {
+		s.CreatedAt = now
+	}
+	if s.UpdatedAt.IsZero() {
+		s.UpdatedAt = now
+	}
+	if s.Version == 0 {
+		s.Version = 1
+	}
+	repository.subs[s.ID] = cloneSubscription(s)
+	repository.logChange(models.ChangeCreated, s)
+	return nil
+}
+
+func (repository *MemoryRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	unlock := repository.rlock()
+	defer unlock()
+
+	s, ok := repository.subs[id]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	out := cloneSubscription(&s)
+	return &out, nil
+}
+
+func (repository *MemoryRepository) Update(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
+	unlock := repository.lock()
+	defer unlock()
+
+	stored, ok := repository.subs[s.ID]
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+	if stored.Version != s.Version {
+		return nil, ErrVersionConflict
+	}
+
+	stored.Service = s.Service
+	stored.PriceRUB = s.PriceRUB
+	stored.StartDate = s.StartDate
+	stored.EndDate = s.EndDate
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	repository.subs[s.ID] = cloneSubscription(&stored)
+	repository.logChange(models.ChangeUpdated, &stored)
+
+	s.Version = stored.Version
+	s.UpdatedAt = stored.UpdatedAt
+	return s, nil
+}
+
+func (repository *MemoryRepository) Delete(ctx context.Context, id uuid.UUID, version *int64) error {
+	unlock := repository.lock()
+	defer unlock()
+
+	stored, ok := repository.subs[id]
+	if !ok {
+		return ErrRecordNotFound
+	}
+	if version != nil && stored.Version != *version {
+		return ErrVersionConflict
+	}
+	delete(repository.subs, id)
+	repository.logChange(models.ChangeDeleted, &stored)
+	return nil
+}
+
+// logChange appends to the change log; the caller holds the write lock.
+func (repository *MemoryRepository) logChange(op string, s *models.Subscription) {
+	change := models.NewSubscriptionChange(op, s, time.Now())
+	change.Seq = int64(len(repository.changes)) + 1
+	if change.EndDate != nil {
+		end := *change.EndDate
+		change.EndDate = &end
+	}
+	repository.changes = append(repository.changes, change)
+}
+
+func (repository *MemoryRepository) ListChanges(ctx context.Context, after int64, limit int) ([]models.SubscriptionChange, error) {
+	unlock := repository.rlock()
+	defer unlock()
+
+	// Seq is the position in changes plus one.
+	start := int(min(max(after, 0), int64(len(repository.changes))))
+	end := min(start+limit, len(repository.changes))
+	return slices.Clone(repository.changes[start:end]), nil
+}
+
+func (repository *MemoryRepository) ListByUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]models.Subscription, error) {
+	// filterByUser sorts by ID, which breaks start date ties.
+	out := repository.filterByUser(userID)
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].StartDate.After(out[j].StartDate)
+	})
+	if offset >= len(out) {
+		return []models.Subscription{}, nil
+	}
+	out = out[offset:]
+	if limit < len(out) {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (repository *MemoryRepository) ListAllByUser(ctx context.Context, userID uuid.UUID) ([]models.Subscription, error) {
+	out := repository.filterByUser(userID)
+	sort.SliceStable(out, func(i, j int) bool {
+		if !out[i].StartDate.Equal(out[j].StartDate) {
+			return out[i].StartDate.Before(out[j].StartDate)
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out, nil
+}
+
+func (repository *MemoryRepository) SumPriceByMonthRange(
+	ctx context.Context,
+	intervalStart time.Time,
+	intervalEnd time.Time,
+	userID *uuid.UUID,
+	service *string,
+) (int64, error) {
+	unlock := repository.rlock()
+	defer unlock()
+
+	var total int64
+	for _, s := range repository.subs {
+		if userID != nil && s.UserID != *userID {
+			continue
+		}
+		if service != nil && *service != "" && s.Service != *service {
+			continue
+		}
+		var active bool
+		if s.EndDate != nil {
+			active = !s.StartDate.After(intervalEnd) && !s.EndDate.Before(intervalStart)
+		} else {
+			active = !s.StartDate.Before(intervalStart) && !s.StartDate.After(intervalEnd)
+		}
+		if active {
+			total += s.PriceRUB
+		}
+	}
+	return total, nil
+}
+
+// filterByUser returns copies of the user's subscriptions ordered by ID, so
+// that the stable sorts of the callers are deterministic.
+func (repository *MemoryRepository) filterByUser(userID uuid.UUID) []models.Subscription {
+	unlock := repository.rlock()
+	defer unlock()
+
+	out := []models.Subscription{}
+	for _, s := range repository.subs {
+		if s.UserID == userID {
+			out = append(out, cloneSubscription(&s))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID.String() < out[j].ID.String()
+	})
+	return out
+}
+
+func cloneSubscription(s *models.Subscription) models.Subscription {
+	out := *s
+	if s.EndDate != nil {
+		end := *s.EndDate
+		out.EndDate = &end
+	}
+	return out
+}
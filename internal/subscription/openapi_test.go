@@ -0,0 +1,325 @@
+package subscription_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/api"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// specSchemas maps component schema names of api/openapi.yaml to the Go types
+// they describe. Every struct declared in payload.go has to be listed.
+var specSchemas = map[string]reflect.Type{
+	"Subscription":                   reflect.TypeFor[models.Subscription](),
+	"SubscriptionCreateRequest":      reflect.TypeFor[subscription.SubscriptionCreateRequest](),
+	"SubscriptionPatchRequest":       reflect.TypeFor[subscription.SubscriptionPatchRequest](),
+	"SubscriptionCreateResponse":     reflect.TypeFor[subscription.SubscriptionCreateResponse](),
+	"SubscriptionsPriceSumResponse":  reflect.TypeFor[subscription.SubscriptionsPriceSumResponse](),
+	"BatchOperation":                 reflect.TypeFor[subscription.BatchOperation](),
+	"BatchRequest":                   reflect.TypeFor[subscription.BatchRequest](),
+	"BatchOperationResult":           reflect.TypeFor[subscription.BatchOperationResult](),
+	"BatchResponse":                  reflect.TypeFor[subscription.BatchResponse](),
+	"DuplicatePair":                  reflect.TypeFor[subscription.DuplicatePair](),
+	"DuplicateSubscriptionsResponse": reflect.TypeFor[subscription.DuplicateSubscriptionsResponse](),
+	"ErrorResponse":                  reflect.TypeFor[subscription.ErrorResponse](),
+	"FieldViolation":                 reflect.TypeFor[subscription.FieldViolation](),
+	"MessageResponse":                reflect.TypeFor[subscription.MessageResponse](),
+	"Change":                         reflect.TypeFor[subscription.Change](),
+	"ChangesResponse":                reflect.TypeFor[subscription.ChangesResponse](),
+}
+
+var httpMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// routeSources are the functions registering the routes api/openapi.yaml
+// documents, keyed by the file declaring them.
+var routeSources = map[string]string{
+	"handler.go":              "NewSubscriptionHandler",
+	"../analytics/handler.go": "NewAnalyticsHandler",
+	"../events/handler.go":    "NewEventsHandler",
+	"../health/handler.go":    "NewHealthHandler",
+	"../docs/handler.go":      "NewDocsHandler",
+	"../../cmd/main.go":       "App",
+}
+
+// undocumentedRoutes are registered without being part of the API.
+var undocumentedRoutes = []string{
+	// Swagger UI assets of /docs.
+	"GET /docs/{$}",
+	"GET /docs/",
+}
+
+type openAPISpec struct {
+	// Paths maps paths to path items; besides operations keyed by method
+	// they may hold servers and parameters.
+	Paths      map[string]map[string]yaml.Node `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*specSchema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+type specSchema struct {
+	Ref        string                 `yaml:"$ref"`
+	Type       string                 `yaml:"type"`
+	Nullable   bool                   `yaml:"nullable"`
+	Properties map[string]*specSchema `yaml:"properties"`
+	Required   []string               `yaml:"required"`
+	Items      *specSchema            `yaml:"items"`
+	AllOf      []*specSchema          `yaml:"allOf"`
+	OneOf      []*specSchema          `yaml:"oneOf"`
+}
+
+func loadSpec(t *testing.T) *openAPISpec {
+	t.Helper()
+	var spec openAPISpec
+	if err := yaml.Unmarshal(api.OpenAPI, &spec); err != nil {
+		t.Fatalf("parse api/openapi.yaml: %v", err)
+	}
+	return &spec
+}
+
+func parseFile(t *testing.T, name string) *ast.File {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), name, nil, 0)
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+	return file
+}
+
+// registeredRoutes returns the patterns the routeSources register on their
+// routers, read from the source so that new routes can't be missed.
+func registeredRoutes(t *testing.T) []string {
+	t.Helper()
+	var routes []string
+	for file, name := range routeSources {
+		found := false
+		for _, decl := range parseFile(t, file).Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Name.Name != name {
+				continue
+			}
+			found = true
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok || len(call.Args) == 0 {
+					return true
+				}
+				sel, ok := call.Fun.(*ast.SelectorExpr)
+				if !ok || (sel.Sel.Name != "Handle" && sel.Sel.Name != "HandleFunc") {
+					return true
+				}
+				lit, ok := call.Args[0].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					t.Errorf("%s: route pattern at %v is not a string literal", name, call.Args[0])
+					return true
+				}
+				pattern, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatalf("unquote %s: %v", lit.Value, err)
+				}
+				routes = append(routes, pattern)
+				return true
+			})
+		}
+		if !found {
+			t.Errorf("%s not found in %s", name, file)
+		}
+	}
+	slices.Sort(routes)
+	return routes
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	spec := loadSpec(t)
+
+	var documented []string
+	for path, item := range spec.Paths {
+		for method := range item {
+			if !slices.Contains(httpMethods, method) {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(documented)
+
+	registered := registeredRoutes(t)
+	for _, route := range registered {
+		if !slices.Contains(documented, route) && !slices.Contains(undocumentedRoutes, route) {
+			t.Errorf("route %q is not documented in api/openapi.yaml", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(registered, route) {
+			t.Errorf("api/openapi.yaml documents %q, which no handler registers", route)
+		}
+	}
+}
+
+func TestOpenAPIPayloads(t *testing.T) {
+	spec := loadSpec(t)
+
+	for _, decl := range parseFile(t, "payload.go").Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gen.Specs {
+			ts := s.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); !ok {
+				continue
+			}
+			if _, ok := specSchemas[ts.Name.Name]; !ok {
+				t.Errorf("payload %s has no entry in specSchemas", ts.Name.Name)
+			}
+		}
+	}
+
+	for name, typ := range specSchemas {
+		schema, ok := spec.Components.Schemas[name]
+		if !ok {
+			t.Errorf("schema %s is missing from api/openapi.yaml", name)
+			continue
+		}
+		for _, err := range compareStruct(spec, typ, schema) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+var (
+	timeType = reflect.TypeFor[time.Time]()
+	uuidType = reflect.TypeFor[uuid.UUID]()
+	rawType  = reflect.TypeFor[json.RawMessage]()
+)
+
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// jsonFields lists the fields encoding/json writes for typ, including the
+// fields of embedded structs.
+func jsonFields(typ reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := range typ.NumField() {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || !f.IsExported() {
+			continue
+		}
+		if f.Anonymous && tag == "" {
+			fields = append(fields, jsonFields(f.Type)...)
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, typ: f.Type, omitempty: strings.Contains(opts, "omitempty")})
+	}
+	return fields
+}
+
+// compareStruct checks that schema has exactly the properties typ encodes
+// to, with matching types, that only always-present properties are
+// required, and that nil pointers written as null are nullable.
+func compareStruct(spec *openAPISpec, typ reflect.Type, schema *specSchema) []error {
+	var errs []error
+	fields := jsonFields(typ)
+	for _, f := range fields {
+		prop, ok := schema.Properties[f.name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("property %q is not documented", f.name))
+			continue
+		}
+		if f.omitempty && slices.Contains(schema.Required, f.name) {
+			errs = append(errs, fmt.Errorf("property %q is required but omitted when empty", f.name))
+		}
+		if f.typ.Kind() == reflect.Pointer && !f.omitempty && !prop.Nullable {
+			errs = append(errs, fmt.Errorf("property %q can be null but is not nullable", f.name))
+		}
+		if err := compareType(spec, f.typ, prop); err != nil {
+			errs = append(errs, fmt.Errorf("property %q: %w", f.name, err))
+		}
+	}
+	for name := range schema.Properties {
+		if !slices.ContainsFunc(fields, func(f jsonField) bool { return f.name == name }) {
+			errs = append(errs, fmt.Errorf("documented property %q doesn't exist", name))
+		}
+	}
+	return errs
+}
+
+func compareType(spec *openAPISpec, typ reflect.Type, schema *specSchema) error {
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if len(schema.AllOf) == 1 && schema.Type == "" {
+		schema = schema.AllOf[0]
+	}
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		ref, ok := spec.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		// Named structs are checked against their own schema.
+		if typ.Kind() == reflect.Struct && typ != timeType {
+			if name != typ.Name() {
+				return fmt.Errorf("refers to %s, want %s", name, typ.Name())
+			}
+			return nil
+		}
+		schema = ref
+	}
+
+	var want string
+	switch {
+	case typ == rawType:
+		if schema.Type != "" || len(schema.OneOf) == 0 {
+			return fmt.Errorf("raw JSON should be documented with oneOf")
+		}
+		return nil
+	case typ == timeType, typ == uuidType:
+		want = "string"
+	default:
+		switch typ.Kind() {
+		case reflect.String:
+			want = "string"
+		case reflect.Bool:
+			want = "boolean"
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			want = "integer"
+		case reflect.Float32, reflect.Float64:
+			want = "number"
+		case reflect.Slice:
+			if schema.Type != "array" || schema.Items == nil {
+				return fmt.Errorf("type is %q, want array", schema.Type)
+			}
+			return compareType(spec, typ.Elem(), schema.Items)
+		case reflect.Struct:
+			return fmt.Errorf("%s should refer to its schema", typ.Name())
+		default:
+			return fmt.Errorf("unsupported Go type %s", typ)
+		}
+	}
+	if schema.Type != want {
+		return fmt.Errorf("type is %q, want %q", schema.Type, want)
+	}
+	return nil
+}
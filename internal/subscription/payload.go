@@ -1,5 +1,13 @@
 package subscription
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+)
+
 type SubscriptionCreateRequest struct {
 	Service   string  `json:"service_name"`
 	PriceRUB  int64   `json:"price"`
@@ -15,15 +23,82 @@ type SubscriptionPatchRequest struct {
 }
 
 type SubscriptionCreateResponse struct {
-	SubID string `json:"subscription_id"`
+	SubID        string   `json:"subscription_id"`
+	Warning      string   `json:"warning,omitempty"`
+	OverlapsWith []string `json:"overlaps_with,omitempty"`
 }
 
 type SubscriptionsPriceSumResponse struct {
 	PriceSum int64 `json:"total_sum"`
 }
 
+const (
+	BatchOpCreate = "create"
+	BatchOpPatch  = "patch"
+	BatchOpDelete = "delete"
+)
+
+type BatchOperation struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	IfMatch string          `json:"if_match,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchOperationResult struct {
+	Index        int                  `json:"index"`
+	Op           string               `json:"op"`
+	Status       int                  `json:"status"`
+	ID           string               `json:"id,omitempty"`
+	Subscription *models.Subscription `json:"subscription,omitempty"`
+	Code         string               `json:"code,omitempty"`
+	Error        string               `json:"error,omitempty"`
+	Violations   []FieldViolation     `json:"violations,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool                   `json:"committed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+type DuplicatePair struct {
+	Service       string                `json:"service_name"`
+	OverlapStart  string                `json:"overlap_start"`
+	OverlapEnd    *string               `json:"overlap_end"`
+	Subscriptions []models.Subscription `json:"subscriptions"`
+}
+
+type DuplicateSubscriptionsResponse struct {
+	Duplicates []DuplicatePair `json:"duplicates"`
+}
+
+// Change is an entry of GET /changes. Subscription is the state after the
+// change and is absent for deletes.
+type Change struct {
+	Cursor         string               `json:"cursor"`
+	Op             string               `json:"op"`
+	SubscriptionID string               `json:"subscription_id"`
+	UserID         string               `json:"user_id"`
+	Version        int64                `json:"version"`
+	ChangedAt      time.Time            `json:"changed_at"`
+	Subscription   *models.Subscription `json:"subscription,omitempty"`
+}
+
+type ChangesResponse struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"next_cursor"`
+	HasMore    bool     `json:"has_more"`
+}
+
+// ErrorResponse is the problem+json body of every failed request. Violations
+// is set for ProblemValidationFailed.
 type ErrorResponse struct {
-	Error string `json:"error"`
+	res.Problem
+	Violations []FieldViolation `json:"violations,omitempty"`
 }
 
 type MessageResponse struct {
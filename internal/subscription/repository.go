@@ -3,13 +3,56 @@ package subscription
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrRecordNotFound is returned when no subscription has the given ID.
+	ErrRecordNotFound = errors.New("subscription not found")
+	// ErrVersionConflict is returned when a conditional update or delete finds
+	// the subscription at a different version than the caller expected.
+	ErrVersionConflict = errors.New("subscription version conflict")
+)
+
+// Repository is the storage used by the subscription handlers. Every
+// implementation must pass the conformance suite in repository_test.go.
+type Repository interface {
+	// Transaction runs fn against a repository whose changes are applied
+	// atomically; they are discarded if fn returns an error.
+	Transaction(ctx context.Context, fn func(tx Repository) error) error
+	// Create stores s, assigning a new ID if it has none.
+	Create(ctx context.Context, s *models.Subscription) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error)
+	// Update writes s only if the stored row is still at s.Version, and bumps
+	// the version on success.
+	Update(ctx context.Context, s *models.Subscription) (*models.Subscription, error)
+	// Delete removes the subscription. If version is not nil the row is only
+	// deleted while it is still at that version.
+	Delete(ctx context.Context, id uuid.UUID, version *int64) error
+	// ListByUser returns a page of the user's subscriptions, newest start
+	// first and then by ID, so that pages neither repeat nor skip rows.
+	ListByUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]models.Subscription, error)
+	// ListAllByUser returns all of the user's subscriptions, oldest start first.
+	ListAllByUser(ctx context.Context, userID uuid.UUID) ([]models.Subscription, error)
+	// ListChanges returns up to limit entries of the change log after seq
+	// after, oldest first. Create, Update and Delete append to the log
+	// atomically with the change, and entries become visible in the order
+	// of their Seq.
+	ListChanges(ctx context.Context, after int64, limit int) ([]models.SubscriptionChange, error)
+	// SumPriceByMonthRange sums the prices of subscriptions active in the
+	// range: ended subscriptions overlapping it, and open-ended ones that
+	// started within it.
+	SumPriceByMonthRange(ctx context.Context, intervalStart, intervalEnd time.Time, userID *uuid.UUID, service *string) (int64, error)
+}
+
+// SubscriptionRepository implements Repository on top of GORM. It works with
+// both the Postgres and the SQLite dialects.
 type SubscriptionRepository struct {
 	db *gorm.DB
 }
@@ -18,39 +61,148 @@ func NewSubscriptionRepository(db *gorm.DB) *SubscriptionRepository {
 	return &SubscriptionRepository{db: db}
 }
 
+func (repository *SubscriptionRepository) Transaction(ctx context.Context, fn func(tx Repository) error) error {
+	return repository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&SubscriptionRepository{db: tx})
+	})
+}
+
 func (repository *SubscriptionRepository) Create(ctx context.Context, s *models.Subscription) error {
-	return repository.db.WithContext(ctx).Create(s).Error
+	s.GenerateNewUUID(repository.db)
+	return repository.write(ctx, func(tx *gorm.DB) error {
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		return logChange(tx, models.ChangeCreated, s)
+	})
 }
 
 func (repository *SubscriptionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
 	var s models.Subscription
 	if err := repository.db.WithContext(ctx).First(&s, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecordNotFound
+		}
 		return nil, err
 	}
 	return &s, nil
 }
 
 func (repository *SubscriptionRepository) Update(ctx context.Context, s *models.Subscription) (*models.Subscription, error) {
-	if err := repository.db.WithContext(ctx).Save(s).Error; err != nil {
+	now := time.Now()
+	err := repository.write(ctx, func(tx *gorm.DB) error {
+		result := tx.
+			Model(&models.Subscription{}).
+			Where("id = ? AND version = ?", s.ID, s.Version).
+			Updates(map[string]any{
+				"service":    s.Service,
+				"price_rub":  s.PriceRUB,
+				"start_date": s.StartDate,
+				"end_date":   s.EndDate,
+				"updated_at": now,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return missingOrConflict(tx, s.ID)
+		}
+		updated := *s
+		updated.Version++
+		updated.UpdatedAt = now
+		return logChange(tx, models.ChangeUpdated, &updated)
+	})
+	if err != nil {
 		return nil, err
 	}
+	s.Version++
+	s.UpdatedAt = now
 	return s, nil
 }
 
-func (repository *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID) error {
-	result := repository.db.WithContext(ctx).Delete(&models.Subscription{}, "id = ?", id)
-	if result.Error != nil {
-		return result.Error
+func (repository *SubscriptionRepository) Delete(ctx context.Context, id uuid.UUID, version *int64) error {
+	return repository.write(ctx, func(tx *gorm.DB) error {
+		// The log records the subscription as it was deleted.
+		var existing models.Subscription
+		if err := tx.First(&existing, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRecordNotFound
+			}
+			return err
+		}
+		if version != nil && existing.Version != *version {
+			return ErrVersionConflict
+		}
+		result := tx.Where("id = ? AND version = ?", id, existing.Version).Delete(&models.Subscription{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return missingOrConflict(tx, id)
+		}
+		return logChange(tx, models.ChangeDeleted, &existing)
+	})
+}
+
+// changeLogLock is the key of the Postgres advisory lock that serializes
+// writers, "subs" in ASCII.
+const changeLogLock int64 = 0x73756273
+
+// write runs fn in a transaction, nested in the current one if there is
+// any. On Postgres it first takes changeLogLock until the commit, so that
+// change log sequence numbers are handed out in commit order: otherwise a
+// reader could move past a number whose transaction commits later. The lock
+// is taken before any row is touched to rule out deadlocks. SQLite
+// serializes writers by itself.
+func (repository *SubscriptionRepository) write(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return repository.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", changeLogLock).Error; err != nil {
+				return err
+			}
+		}
+		return fn(tx)
+	})
+}
+
+func logChange(tx *gorm.DB, op string, s *models.Subscription) error {
+	change := models.NewSubscriptionChange(op, s, time.Now())
+	return tx.Create(&change).Error
+}
+
+func (repository *SubscriptionRepository) ListChanges(ctx context.Context, after int64, limit int) ([]models.SubscriptionChange, error) {
+	var out []models.SubscriptionChange
+	q := db.FromReplica(repository.db.WithContext(ctx)).Where("seq > ?", after).Order("seq").Limit(limit)
+	if err := q.Find(&out).Error; err != nil {
+		return nil, err
 	}
-	if result.RowsAffected == 0 {
-		return gorm.ErrRecordNotFound
+	return out, nil
+}
+
+func missingOrConflict(tx *gorm.DB, id uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.Subscription{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrRecordNotFound
 	}
-	return nil
+	return ErrVersionConflict
 }
 
 func (repository *SubscriptionRepository) ListByUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]models.Subscription, error) {
 	var out []models.Subscription
-	q := repository.db.WithContext(ctx).Where("user_id = ?", userID).Order("start_date desc").Offset(offset).Limit(limit)
+	q := db.FromReplica(repository.db.WithContext(ctx)).Where("user_id = ?", userID).Order("start_date desc, id").Offset(offset).Limit(limit)
+	if err := q.Find(&out).Error; err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (repository *SubscriptionRepository) ListAllByUser(ctx context.Context, userID uuid.UUID) ([]models.Subscription, error) {
+	var out []models.Subscription
+	q := repository.db.WithContext(ctx).Where("user_id = ?", userID).Order("start_date asc, created_at asc")
 	if err := q.Find(&out).Error; err != nil {
 		return nil, err
 	}
@@ -66,7 +218,7 @@ func (repo *SubscriptionRepository) SumPriceByMonthRange(
 ) (int64, error) {
 	var total sql.NullInt64
 
-	q := repo.db.WithContext(ctx).
+	q := db.FromReplica(repo.db.WithContext(ctx)).
 		Model(&models.Subscription{}).
 		Select("COALESCE(SUM(price_rub), 0) as total").
 		Where(`
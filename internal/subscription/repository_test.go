@@ -0,0 +1,421 @@
+package subscription_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// postgresDSNEnv points the conformance suite at a real Postgres. The
+// Postgres run is skipped when it is unset. The database is wiped.
+const postgresDSNEnv = "SUBS_TRACKER_TEST_POSTGRES_DSN"
+
+func TestMemoryRepository(t *testing.T) {
+	runRepositoryConformance(t, func(t *testing.T) subscription.Repository {
+		return subscription.NewMemoryRepository()
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	runRepositoryConformance(t, func(t *testing.T) subscription.Repository {
+		gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+		if err != nil {
+			t.Fatalf("open sqlite: %v", err)
+		}
+		if err := migrations.AutoMigrate(gormDB); err != nil {
+			t.Fatalf("migrate sqlite: %v", err)
+		}
+		t.Cleanup(func() {
+			if sqlDB, err := gormDB.DB(); err == nil {
+				sqlDB.Close()
+			}
+		})
+		return subscription.NewSubscriptionRepository(gormDB)
+	})
+}
+
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresDSNEnv)
+	}
+	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		t.Fatalf("open postgres: %v", err)
+	}
+	if err := migrations.RunMigrations(gormDB); err != nil {
+		t.Fatalf("migrate postgres: %v", err)
+	}
+	runRepositoryConformance(t, func(t *testing.T) subscription.Repository {
+		if err := gormDB.Exec("TRUNCATE subscriptions, subscription_changes").Error; err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return subscription.NewSubscriptionRepository(gormDB)
+	})
+}
+
+func month(s string) time.Time {
+	t, err := time.Parse("01-2006", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func monthPtr(s string) *time.Time {
+	t := month(s)
+	return &t
+}
+
+func newSub(userID uuid.UUID, service string, price int64, start string, end string) *models.Subscription {
+	s := &models.Subscription{
+		Service:   service,
+		PriceRUB:  price,
+		UserID:    userID,
+		StartDate: month(start),
+		Version:   1,
+	}
+	if end != "" {
+		s.EndDate = monthPtr(end)
+	}
+	return s
+}
+
+func mustCreate(t *testing.T, repo subscription.Repository, s *models.Subscription) *models.Subscription {
+	t.Helper()
+	if err := repo.Create(context.Background(), s); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return s
+}
+
+// runRepositoryConformance checks that a Repository implementation behaves
+// like the reference Postgres one. newRepo must return an empty repository.
+func runRepositoryConformance(t *testing.T, newRepo func(t *testing.T) subscription.Repository) {
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New()
+		s := mustCreate(t, repo, newSub(userID, "Netflix", 499, "01-2025", "06-2025"))
+		if s.ID == uuid.Nil {
+			t.Fatal("Create did not assign an ID")
+		}
+
+		got, err := repo.GetByID(ctx, s.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.Service != "Netflix" || got.PriceRUB != 499 || got.UserID != userID || got.Version != 1 {
+			t.Fatalf("GetByID returned %+v", got)
+		}
+		if !got.StartDate.Equal(month("01-2025")) || got.EndDate == nil || !got.EndDate.Equal(month("06-2025")) {
+			t.Fatalf("GetByID dates start=%v end=%v", got.StartDate, got.EndDate)
+		}
+
+		if _, err := repo.GetByID(ctx, uuid.New()); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("GetByID missing: got %v, want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		repo := newRepo(t)
+		s := mustCreate(t, repo, newSub(uuid.New(), "Spotify", 199, "01-2025", "03-2025"))
+
+		s.PriceRUB = 299
+		s.EndDate = nil
+		updated, err := repo.Update(ctx, s)
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		if updated.Version != 2 {
+			t.Fatalf("Update version = %d, want 2", updated.Version)
+		}
+
+		got, err := repo.GetByID(ctx, s.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.PriceRUB != 299 || got.EndDate != nil || got.Version != 2 {
+			t.Fatalf("GetByID after update returned %+v", got)
+		}
+
+		stale := *got
+		stale.Version = 1
+		if _, err := repo.Update(ctx, &stale); !errors.Is(err, subscription.ErrVersionConflict) {
+			t.Fatalf("Update stale: got %v, want ErrVersionConflict", err)
+		}
+
+		missing := newSub(uuid.New(), "Spotify", 1, "01-2025", "")
+		missing.ID = uuid.New()
+		if _, err := repo.Update(ctx, missing); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("Update missing: got %v, want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		repo := newRepo(t)
+		s := mustCreate(t, repo, newSub(uuid.New(), "Yandex Plus", 299, "01-2025", ""))
+
+		stale := int64(7)
+		if err := repo.Delete(ctx, s.ID, &stale); !errors.Is(err, subscription.ErrVersionConflict) {
+			t.Fatalf("Delete stale: got %v, want ErrVersionConflict", err)
+		}
+		current := int64(1)
+		if err := repo.Delete(ctx, s.ID, &current); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := repo.GetByID(ctx, s.ID); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("GetByID after delete: got %v, want ErrRecordNotFound", err)
+		}
+		if err := repo.Delete(ctx, s.ID, nil); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("Delete missing: got %v, want ErrRecordNotFound", err)
+		}
+		if err := repo.Delete(ctx, s.ID, &current); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("Delete missing with version: got %v, want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("ListByUser", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New()
+		a := mustCreate(t, repo, newSub(userID, "A", 1, "01-2025", ""))
+		b := mustCreate(t, repo, newSub(userID, "B", 1, "03-2025", ""))
+		c := mustCreate(t, repo, newSub(userID, "C", 1, "02-2025", ""))
+		mustCreate(t, repo, newSub(uuid.New(), "D", 1, "04-2025", ""))
+
+		page, err := repo.ListByUser(ctx, userID, 0, 2)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		assertIDs(t, "ListByUser page 1", page, b, c)
+
+		page, err = repo.ListByUser(ctx, userID, 2, 2)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		assertIDs(t, "ListByUser page 2", page, a)
+
+		page, err = repo.ListByUser(ctx, userID, 5, 2)
+		if err != nil {
+			t.Fatalf("ListByUser: %v", err)
+		}
+		assertIDs(t, "ListByUser past end", page)
+
+		all, err := repo.ListAllByUser(ctx, userID)
+		if err != nil {
+			t.Fatalf("ListAllByUser: %v", err)
+		}
+		assertIDs(t, "ListAllByUser", all, a, c, b)
+	})
+
+	t.Run("ListByUserSameStart", func(t *testing.T) {
+		repo := newRepo(t)
+		userID := uuid.New()
+		var subs []*models.Subscription
+		for range 5 {
+			subs = append(subs, mustCreate(t, repo, newSub(userID, "A", 1, "01-2025", "")))
+		}
+		sort.Slice(subs, func(i, j int) bool { return subs[i].ID.String() < subs[j].ID.String() })
+
+		var got []models.Subscription
+		for offset := 0; offset < len(subs); offset += 2 {
+			page, err := repo.ListByUser(ctx, userID, offset, 2)
+			if err != nil {
+				t.Fatalf("ListByUser: %v", err)
+			}
+			got = append(got, page...)
+		}
+		assertIDs(t, "ListByUser pages with the same start", got, subs...)
+	})
+
+	t.Run("SumPriceByMonthRange", func(t *testing.T) {
+		repo := newRepo(t)
+		alice, bob := uuid.New(), uuid.New()
+		mustCreate(t, repo, newSub(alice, "Netflix", 100, "01-2025", "03-2025"))
+		mustCreate(t, repo, newSub(alice, "Spotify", 20, "02-2025", ""))
+		mustCreate(t, repo, newSub(alice, "Netflix", 1000, "06-2025", "08-2025"))
+		mustCreate(t, repo, newSub(bob, "Netflix", 5, "12-2024", ""))
+		mustCreate(t, repo, newSub(bob, "Spotify", 3, "03-2025", "03-2025"))
+
+		netflix := "Netflix"
+		empty := ""
+		tests := []struct {
+			name       string
+			start, end string
+			userID     *uuid.UUID
+			service    *string
+			want       int64
+		}{
+			// Ended subscriptions count when their range overlaps the interval.
+			{name: "overlap at end", start: "03-2025", end: "05-2025", want: 100 + 3},
+			{name: "overlap at start", start: "08-2025", end: "12-2025", want: 1000},
+			{name: "inside", start: "07-2025", end: "07-2025", want: 1000},
+			// Open-ended subscriptions only count if they start in the interval.
+			{name: "open-ended started before", start: "04-2025", end: "05-2025", want: 0},
+			{name: "open-ended started inside", start: "02-2025", end: "02-2025", want: 100 + 20},
+			{name: "everything", start: "01-2024", end: "12-2025", want: 100 + 20 + 1000 + 5 + 3},
+			{name: "by user", start: "01-2024", end: "12-2025", userID: &alice, want: 100 + 20 + 1000},
+			{name: "by service", start: "01-2024", end: "12-2025", service: &netflix, want: 100 + 1000 + 5},
+			{name: "by user and service", start: "01-2024", end: "12-2025", userID: &bob, service: &netflix, want: 5},
+			{name: "empty service ignored", start: "01-2024", end: "12-2025", userID: &bob, service: &empty, want: 5 + 3},
+			{name: "nothing", start: "01-2020", end: "12-2020", want: 0},
+		}
+		for _, tt := range tests {
+			got, err := repo.SumPriceByMonthRange(ctx, month(tt.start), month(tt.end), tt.userID, tt.service)
+			if err != nil {
+				t.Fatalf("%s: SumPriceByMonthRange: %v", tt.name, err)
+			}
+			if got != tt.want {
+				t.Errorf("%s: SumPriceByMonthRange = %d, want %d", tt.name, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("ChangeLog", func(t *testing.T) {
+		repo := newRepo(t)
+		start, err := repo.ListChanges(ctx, 0, 1000)
+		if err != nil {
+			t.Fatalf("ListChanges: %v", err)
+		}
+		var after int64
+		if len(start) > 0 {
+			after = start[len(start)-1].Seq
+		}
+
+		a := mustCreate(t, repo, newSub(uuid.New(), "A", 1, "01-2025", ""))
+		a.PriceRUB = 2
+		if _, err := repo.Update(ctx, a); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		errAbort := errors.New("abort")
+		repo.Transaction(ctx, func(tx subscription.Repository) error {
+			mustCreate(t, tx, newSub(uuid.New(), "rolled back", 1, "01-2025", ""))
+			return errAbort
+		})
+		if err := repo.Delete(ctx, uuid.New(), nil); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("Delete missing: got %v, want ErrRecordNotFound", err)
+		}
+		var b *models.Subscription
+		if err := repo.Transaction(ctx, func(tx subscription.Repository) error {
+			b = mustCreate(t, tx, newSub(uuid.New(), "B", 3, "02-2025", "03-2025"))
+			return tx.Delete(ctx, a.ID, &a.Version)
+		}); err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+
+		changes, err := repo.ListChanges(ctx, after, 10)
+		if err != nil {
+			t.Fatalf("ListChanges: %v", err)
+		}
+		want := []struct {
+			op      string
+			sub     *models.Subscription
+			price   int64
+			version int64
+		}{
+			{models.ChangeCreated, a, 1, 1},
+			{models.ChangeUpdated, a, 2, 2},
+			{models.ChangeCreated, b, 3, 1},
+			{models.ChangeDeleted, a, 2, 2},
+		}
+		if len(changes) != len(want) {
+			t.Fatalf("got %d changes, want %d: %+v", len(changes), len(want), changes)
+		}
+		for i, w := range want {
+			c := changes[i]
+			if c.Op != w.op || c.SubscriptionID != w.sub.ID || c.UserID != w.sub.UserID || c.PriceRUB != w.price || c.Version != w.version {
+				t.Errorf("change %d = %s %s price=%d version=%d, want %s %s price=%d version=%d",
+					i, c.Op, c.SubscriptionID, c.PriceRUB, c.Version, w.op, w.sub.ID, w.price, w.version)
+			}
+			if i > 0 && c.Seq <= changes[i-1].Seq {
+				t.Errorf("change %d has seq %d after %d", i, c.Seq, changes[i-1].Seq)
+			}
+		}
+		if got := changes[2].Subscription(); got.EndDate == nil || !got.EndDate.Equal(month("03-2025")) {
+			t.Errorf("created B has end date %v, want 03-2025", got.EndDate)
+		}
+
+		page, err := repo.ListChanges(ctx, changes[1].Seq, 1)
+		if err != nil || len(page) != 1 || page[0].Seq != changes[2].Seq {
+			t.Fatalf("ListChanges after %d limit 1 = %+v, %v", changes[1].Seq, page, err)
+		}
+		if rest, err := repo.ListChanges(ctx, changes[3].Seq, 10); err != nil || len(rest) != 0 {
+			t.Fatalf("ListChanges after the last = %+v, %v", rest, err)
+		}
+	})
+
+	t.Run("TransactionCommit", func(t *testing.T) {
+		repo := newRepo(t)
+		existing := mustCreate(t, repo, newSub(uuid.New(), "A", 1, "01-2025", ""))
+
+		var created *models.Subscription
+		err := repo.Transaction(ctx, func(tx subscription.Repository) error {
+			created = mustCreate(t, tx, newSub(uuid.New(), "B", 2, "01-2025", ""))
+			return tx.Delete(ctx, existing.ID, nil)
+		})
+		if err != nil {
+			t.Fatalf("Transaction: %v", err)
+		}
+		if _, err := repo.GetByID(ctx, created.ID); err != nil {
+			t.Fatalf("GetByID created in transaction: %v", err)
+		}
+		if _, err := repo.GetByID(ctx, existing.ID); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("GetByID deleted in transaction: got %v, want ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("TransactionRollback", func(t *testing.T) {
+		repo := newRepo(t)
+		existing := mustCreate(t, repo, newSub(uuid.New(), "A", 1, "01-2025", ""))
+		errAbort := errors.New("abort")
+
+		var created *models.Subscription
+		err := repo.Transaction(ctx, func(tx subscription.Repository) error {
+			created = mustCreate(t, tx, newSub(uuid.New(), "B", 2, "01-2025", ""))
+			existing.PriceRUB = 42
+			if _, err := tx.Update(ctx, existing); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Transaction: got %v, want errAbort", err)
+		}
+		if _, err := repo.GetByID(ctx, created.ID); !errors.Is(err, subscription.ErrRecordNotFound) {
+			t.Fatalf("GetByID created in rolled back transaction: got %v, want ErrRecordNotFound", err)
+		}
+		got, err := repo.GetByID(ctx, existing.ID)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		if got.PriceRUB != 1 || got.Version != 1 {
+			t.Fatalf("rolled back update is visible: %+v", got)
+		}
+	})
+}
+
+func assertIDs(t *testing.T, what string, got []models.Subscription, want ...*models.Subscription) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d subscriptions, want %d", what, len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID {
+			t.Fatalf("%s: item %d is %s (%s), want %s (%s)", what, i, got[i].ID, got[i].Service, want[i].ID, want[i].Service)
+		}
+	}
+}
@@ -1,7 +1,643 @@
 package subscription
 
-import "time"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/events"
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/req"
+	"github.com/google/uuid"
+)
+
+// ErrorKind classifies a ServiceError so that transports can map it to their
+// own status codes.
+type ErrorKind int
+
+const (
+	KindInvalid ErrorKind = iota + 1
+	KindNotFound
+	KindPreconditionFailed
+	KindPreconditionRequired
+	// KindNotRun marks a batch operation skipped after an earlier one
+	// failed.
+	KindNotRun
+)
+
+// ServiceError is a business rule violation reported by SubscriptionService.
+// Code is one of the Problem* codes and Message usually the matching Err*
+// message constant; both are safe to show to clients, like Violations. Any
+// other error returned by the service is an internal failure.
+type ServiceError struct {
+	Kind       ErrorKind
+	Code       string
+	Message    string
+	Violations []FieldViolation
+}
+
+func (e *ServiceError) Error() string {
+	if len(e.Violations) == 0 {
+		return e.Message
+	}
+	parts := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		parts[i] = v.Field + " " + v.Message
+	}
+	return e.Message + ": " + strings.Join(parts, "; ")
+}
+
+// Is makes errors.Is match any ServiceError with the same kind and message.
+func (e *ServiceError) Is(target error) bool {
+	t, ok := target.(*ServiceError)
+	return ok && t.Kind == e.Kind && t.Message == e.Message
+}
+
+func invalid(code, msg string) *ServiceError {
+	return &ServiceError{Kind: KindInvalid, Code: code, Message: msg}
+}
+
+var (
+	ErrNotFound             = &ServiceError{Kind: KindNotFound, Code: ProblemSubscriptionNotFound, Message: ErrSubscriptionNotFound}
+	ErrPrecondition         = &ServiceError{Kind: KindPreconditionFailed, Code: ProblemPreconditionFailed, Message: ErrPreconditionFailed}
+	ErrPreconditionRequired = &ServiceError{Kind: KindPreconditionRequired, Code: ProblemIfMatchRequired, Message: ErrIfMatchRequired}
+	ErrNotRun               = &ServiceError{Kind: KindNotRun, Code: ProblemNotRun, Message: ErrOperationNotRun}
+)
+
+const maxBatchOperations = 1000
+
+const (
+	DefaultChangesLimit = 100
+	MaxChangesLimit     = 1000
+)
+
+// errBatchAborted rolls back a batch transaction after an operation failed.
+var errBatchAborted = errors.New("batch aborted")
+
+type SubscriptionServiceDeps struct {
+	Repository     Repository
+	RequireIfMatch bool
+	// Events receives a change event for every successful write. It may be
+	// nil.
+	Events *events.Bus
+}
+
+// SubscriptionService holds the business rules for subscriptions
+// independently of the transport they are exposed through.
+type SubscriptionService struct {
+	Repository     Repository
+	RequireIfMatch bool
+	Events         *events.Bus
+}
+
+func NewSubscriptionService(deps *SubscriptionServiceDeps) *SubscriptionService {
+	return &SubscriptionService{
+		Repository:     deps.Repository,
+		RequireIfMatch: deps.RequireIfMatch,
+		Events:         deps.Events,
+	}
+}
+
+type CreateResult struct {
+	Subscription *models.Subscription
+	// OverlapsWith lists existing subscriptions of the same user and service
+	// whose date ranges overlap the new one.
+	OverlapsWith []string
+}
+
+type BatchResult struct {
+	Op           string
+	ID           string
+	Subscription *models.Subscription
+	Err          error
+
+	// deleted is the subscription a delete operation removed, kept for the
+	// change event published once the batch is committed.
+	deleted *models.Subscription
+}
+
+// ChangesPage is a page of the change log. NextCursor resumes after its
+// last change, or where the request started when it is empty.
+type ChangesPage struct {
+	Changes    []models.SubscriptionChange
+	NextCursor string
+	HasMore    bool
+}
+
+// MonthSpend is the total price of a user's subscriptions active in Month,
+// formatted as MM-YYYY.
+type MonthSpend struct {
+	Month string
+	Total int64
+}
+
+func (service *SubscriptionService) Get(ctx context.Context, id uuid.UUID) (*models.Subscription, error) {
+	sub, err := service.Repository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (service *SubscriptionService) Create(ctx context.Context, body *SubscriptionCreateRequest) (*CreateResult, error) {
+	sub, err := buildSubscription(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var overlapping []string
+	if existing, err := service.Repository.ListAllByUser(ctx, sub.UserID); err != nil {
+		logger.Ctx(ctx).Warnf("SubscriptionService overlap check failed user_id=%s err=%v", sub.UserID.String(), err)
+	} else {
+		overlapping = findOverlapping(existing, sub)
+	}
+
+	if err := service.Repository.Create(ctx, sub); err != nil {
+		return nil, err
+	}
+	service.publish(events.TypeCreated, sub)
+	return &CreateResult{Subscription: sub, OverlapsWith: overlapping}, nil
+}
+
+// Patch applies body to the subscription. ifMatch is the raw If-Match value
+// and may be empty unless RequireIfMatch is set.
+func (service *SubscriptionService) Patch(ctx context.Context, id uuid.UUID, body *SubscriptionPatchRequest, ifMatch string) (*models.Subscription, error) {
+	sub, err := patchSubscription(ctx, service.Repository, service.RequireIfMatch, id, body, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	service.publish(events.TypeUpdated, sub)
+	return sub, nil
+}
+
+// Delete removes the subscription. ifMatch is handled as in Patch.
+func (service *SubscriptionService) Delete(ctx context.Context, id uuid.UUID, ifMatch string) error {
+	deleted, err := deleteSubscription(ctx, service.Repository, service.RequireIfMatch, id, ifMatch)
+	if err != nil {
+		return err
+	}
+	service.publish(events.TypeDeleted, deleted)
+	return nil
+}
+
+// publish announces a change of sub on the event bus. sub is the state after
+// the change, or the removed subscription for events.TypeDeleted.
+func (service *SubscriptionService) publish(typ string, sub *models.Subscription) {
+	e := events.Event{Type: typ, SubscriptionID: sub.ID, UserID: sub.UserID}
+	if typ != events.TypeDeleted {
+		e.Subscription = sub
+	}
+	service.Events.Publish(e)
+}
+
+func (service *SubscriptionService) ListByUser(ctx context.Context, userID uuid.UUID, offset, limit int) ([]models.Subscription, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, invalid(ProblemInvalidParameter, ErrInvalidParameter)
+	}
+	return service.Repository.ListByUser(ctx, userID, offset, limit)
+}
+
+// Changes returns up to limit changes committed after cursor, oldest first.
+// An empty cursor starts from the beginning of the log. Cursors are the
+// decimal sequence numbers of the changes, but clients should treat them as
+// opaque.
+func (service *SubscriptionService) Changes(ctx context.Context, cursor string, limit int) (*ChangesPage, error) {
+	var after int64
+	if cursor != "" {
+		var err error
+		if after, err = strconv.ParseInt(cursor, 10, 64); err != nil || after < 0 {
+			return nil, invalid(ProblemInvalidCursor, ErrInvalidCursor)
+		}
+	}
+	if limit <= 0 || limit > MaxChangesLimit {
+		return nil, invalid(ProblemInvalidParameter, ErrInvalidParameter)
+	}
+
+	changes, err := service.Repository.ListChanges(ctx, after, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	page := &ChangesPage{Changes: changes, NextCursor: strconv.FormatInt(after, 10)}
+	if len(changes) > limit {
+		page.Changes, page.HasMore = changes[:limit], true
+	}
+	if n := len(page.Changes); n > 0 {
+		page.NextCursor = strconv.FormatInt(page.Changes[n-1].Seq, 10)
+	}
+	return page, nil
+}
+
+func (service *SubscriptionService) Duplicates(ctx context.Context, userID uuid.UUID) ([]DuplicatePair, error) {
+	subs, err := service.Repository.ListAllByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return findDuplicates(subs), nil
+}
+
+// SumByMonthRange sums the prices of subscriptions active between the start
+// and end months, given as MM-YYYY.
+func (service *SubscriptionService) SumByMonthRange(ctx context.Context, start, end string, userID *uuid.UUID, serviceName *string) (int64, error) {
+	startDate, endDate, err := parseMonthRange(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return service.Repository.SumPriceByMonthRange(ctx, startDate, endDate, userID, serviceName)
+}
+
+// MonthlySpend returns what the user pays in every month between start and
+// end, given as MM-YYYY. A subscription counts in each month from its start
+// to its end; open-ended ones count until the end of the range.
+func (service *SubscriptionService) MonthlySpend(ctx context.Context, userID uuid.UUID, start, end string) ([]MonthSpend, error) {
+	startDate, endDate, err := parseMonthRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := service.Repository.ListAllByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var out []MonthSpend
+	for month := startDate; !month.After(endDate); month = month.AddDate(0, 1, 0) {
+		spend := MonthSpend{Month: formatMonthYear(month)}
+		for _, sub := range subs {
+			if !sub.StartDate.After(month) && (sub.EndDate == nil || !sub.EndDate.Before(month)) {
+				spend.Total += sub.PriceRUB
+			}
+		}
+		out = append(out, spend)
+	}
+	return out, nil
+}
+
+// parseMonthRange parses the MM-YYYY bounds of a month range.
+func parseMonthRange(start, end string) (time.Time, time.Time, error) {
+	if start == "" || end == "" {
+		return time.Time{}, time.Time{}, invalid(ProblemMissingParameter, ErrMissingParameter)
+	}
+	startDate, err := parseMonthYear(start)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalid(ProblemInvalidStartDate, ErrInvalidStartDate)
+	}
+	endDate, err := parseMonthYear(end)
+	if err != nil {
+		return time.Time{}, time.Time{}, invalid(ProblemInvalidEndDate, ErrInvalidEndDate)
+	}
+	if endDate.Before(startDate) {
+		return time.Time{}, time.Time{}, invalid(ProblemInvalidDateRange, ErrInvalidDateInterval)
+	}
+	return startDate, endDate, nil
+}
+
+// Batch runs the operations in order inside one transaction and returns a
+// result for each. Processing stops at the first failing operation and
+// everything is rolled back; its result carries the error and the results of
+// the operations after it ErrNotRun. committed reports whether the changes
+// were applied. A non-nil error means the batch itself was rejected or the
+// transaction failed.
+func (service *SubscriptionService) Batch(ctx context.Context, ops []BatchOperation) (results []BatchResult, committed bool, err error) {
+	if len(ops) == 0 {
+		return nil, false, invalid(ProblemEmptyBatch, ErrEmptyBatch)
+	}
+	if len(ops) > maxBatchOperations {
+		return nil, false, invalid(ProblemBatchTooLarge, ErrBatchTooLarge)
+	}
+
+	results = make([]BatchResult, 0, len(ops))
+	err = service.Repository.Transaction(ctx, func(tx Repository) error {
+		for _, op := range ops {
+			result := service.runBatchOperation(ctx, tx, op)
+			results = append(results, result)
+			if result.Err != nil {
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchAborted) {
+		for _, op := range ops[len(results):] {
+			results = append(results, BatchResult{Op: op.Op, ID: op.ID, Err: ErrNotRun})
+		}
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// Listeners only hear about the batch once it is committed.
+	for _, result := range results {
+		switch result.Op {
+		case BatchOpCreate:
+			service.publish(events.TypeCreated, result.Subscription)
+		case BatchOpPatch:
+			service.publish(events.TypeUpdated, result.Subscription)
+		case BatchOpDelete:
+			service.publish(events.TypeDeleted, result.deleted)
+		}
+	}
+	return results, true, nil
+}
+
+func (service *SubscriptionService) runBatchOperation(ctx context.Context, tx Repository, op BatchOperation) BatchResult {
+	result := BatchResult{Op: op.Op, ID: op.ID}
+
+	switch op.Op {
+	case BatchOpCreate:
+		var body SubscriptionCreateRequest
+		result.Err = decodeBatchData(op.Data, &body, func() []FieldViolation { return ValidateCreateRequest(&body) })
+		if result.Err != nil {
+			return result
+		}
+		sub, err := buildSubscription(&body)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if result.Err = tx.Create(ctx, sub); result.Err != nil {
+			return result
+		}
+		result.ID = sub.ID.String()
+		result.Subscription = sub
+
+	case BatchOpPatch:
+		subID, err := uuid.Parse(op.ID)
+		if err != nil {
+			result.Err = invalid(ProblemInvalidSubscriptionID, ErrInvalidSubscriptionUUID)
+			return result
+		}
+		var body SubscriptionPatchRequest
+		result.Err = decodeBatchData(op.Data, &body, func() []FieldViolation { return ValidatePatchRequest(&body) })
+		if result.Err != nil {
+			return result
+		}
+		result.Subscription, result.Err = patchSubscription(ctx, tx, service.RequireIfMatch, subID, &body, op.IfMatch)
+
+	case BatchOpDelete:
+		subID, err := uuid.Parse(op.ID)
+		if err != nil {
+			result.Err = invalid(ProblemInvalidSubscriptionID, ErrInvalidSubscriptionUUID)
+			return result
+		}
+		result.deleted, result.Err = deleteSubscription(ctx, tx, service.RequireIfMatch, subID, op.IfMatch)
+
+	default:
+		result.Err = invalid(ProblemInvalidBatchOperation, ErrInvalidBatchOperation)
+	}
+	return result
+}
+
+// decodeBatchData decodes an operation's data into v and reports unknown or
+// mistyped fields together with the violations found by validate.
+func decodeBatchData(data json.RawMessage, v any, validate func() []FieldViolation) error {
+	err := req.Unmarshal(data, v)
+	if errors.Is(err, io.EOF) {
+		return invalid(ProblemEmptyBody, ErrEmptyBody)
+	}
+	found, ok := DecodeViolations(err)
+	if err != nil && !ok {
+		return invalid(ProblemMalformedBody, ErrMalformedBody)
+	}
+	return invalidRequest(mergeViolations(found, validate()))
+}
+
+func patchSubscription(ctx context.Context, repository Repository, requireIfMatch bool, id uuid.UUID, body *SubscriptionPatchRequest, ifMatch string) (*models.Subscription, error) {
+	if ifMatch == "" && requireIfMatch {
+		return nil, ErrPreconditionRequired
+	}
+	existing, err := repository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if ifMatch != "" && !ifMatchSatisfied(ifMatch, existing.Version) {
+		return nil, ErrPrecondition
+	}
+	if err := applyPatch(existing, body); err != nil {
+		return nil, err
+	}
+
+	sub, err := repository.Update(ctx, existing)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrVersionConflict):
+			return nil, ErrPrecondition
+		case errors.Is(err, ErrRecordNotFound):
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// deleteSubscription removes the subscription and returns it as it was. The
+// delete is conditional on the version that was checked when ifMatch is set.
+func deleteSubscription(ctx context.Context, repository Repository, requireIfMatch bool, id uuid.UUID, ifMatch string) (*models.Subscription, error) {
+	if ifMatch == "" && requireIfMatch {
+		return nil, ErrPreconditionRequired
+	}
+
+	existing, err := repository.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	var version *int64
+	if ifMatch != "" {
+		if !ifMatchSatisfied(ifMatch, existing.Version) {
+			return nil, ErrPrecondition
+		}
+		version = &existing.Version
+	}
+
+	if err := repository.Delete(ctx, id, version); err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return nil, ErrNotFound
+		case errors.Is(err, ErrVersionConflict):
+			return nil, ErrPrecondition
+		}
+		return nil, err
+	}
+	return existing, nil
+}
 
 func parseMonthYear(s string) (time.Time, error) {
 	return time.Parse("01-2006", s)
 }
+
+func formatMonthYear(t time.Time) string {
+	return t.UTC().Format("01-2006")
+}
+
+// buildSubscription validates a create request and turns it into a new
+// subscription.
+func buildSubscription(body *SubscriptionCreateRequest) (*models.Subscription, error) {
+	if err := invalidRequest(ValidateCreateRequest(body)); err != nil {
+		return nil, err
+	}
+	// The values were validated above.
+	userID, _ := uuid.Parse(body.UserID)
+	startDate, _ := parseMonthYear(body.StartDate)
+	var endDate *time.Time
+	if body.EndDate != nil && *body.EndDate != "" {
+		t, _ := parseMonthYear(*body.EndDate)
+		endDate = &t
+	}
+
+	sub := &models.Subscription{
+		Service:   body.Service,
+		PriceRUB:  body.PriceRUB,
+		UserID:    userID,
+		StartDate: startDate,
+		EndDate:   endDate,
+		Version:   1,
+	}
+	return sub, nil
+}
+
+// applyPatch merges a patch request into sub. An empty end_date clears it.
+// On failure sub may be partially modified.
+func applyPatch(sub *models.Subscription, body *SubscriptionPatchRequest) error {
+	if err := invalidRequest(ValidatePatchRequest(body)); err != nil {
+		return err
+	}
+	if body.PriceRUB != nil {
+		sub.PriceRUB = *body.PriceRUB
+	}
+	if body.StartDate != nil {
+		sub.StartDate, _ = parseMonthYear(*body.StartDate)
+	}
+	if body.EndDate != nil {
+		if *body.EndDate == "" {
+			sub.EndDate = nil
+		} else {
+			endDate, _ := parseMonthYear(*body.EndDate)
+			sub.EndDate = &endDate
+		}
+	}
+	if sub.EndDate != nil && sub.EndDate.Before(sub.StartDate) {
+		violation := FieldViolation{Field: "end_date", Code: CodeDateOrder, Message: "must not be before start_date"}
+		if body.EndDate == nil {
+			violation = FieldViolation{Field: "start_date", Code: CodeDateOrder, Message: "must not be after end_date"}
+		}
+		return invalidRequest([]FieldViolation{violation})
+	}
+	return nil
+}
+
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchSatisfied reports whether an If-Match header value matches the
+// current version. It accepts "*" and comma-separated lists of entity tags;
+// weak tags never match since If-Match requires strong comparison.
+func ifMatchSatisfied(header string, version int64) bool {
+	current := formatETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeServiceName folds case and whitespace so that "Spotify" and
+// " spotify  " are treated as the same service.
+func normalizeServiceName(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// overlapInterval returns the months shared by both subscriptions. A nil end
+// means the subscription is still active. ok is false if they don't overlap.
+func overlapInterval(a, b *models.Subscription) (start time.Time, end *time.Time, ok bool) {
+	if a.EndDate != nil && a.EndDate.Before(b.StartDate) {
+		return time.Time{}, nil, false
+	}
+	if b.EndDate != nil && b.EndDate.Before(a.StartDate) {
+		return time.Time{}, nil, false
+	}
+
+	start = a.StartDate
+	if b.StartDate.After(start) {
+		start = b.StartDate
+	}
+	switch {
+	case a.EndDate == nil:
+		end = b.EndDate
+	case b.EndDate == nil:
+		end = a.EndDate
+	case a.EndDate.Before(*b.EndDate):
+		end = a.EndDate
+	default:
+		end = b.EndDate
+	}
+	return start, end, true
+}
+
+// findDuplicates returns every pair of subscriptions for the same normalized
+// service whose date ranges overlap.
+func findDuplicates(subs []models.Subscription) []DuplicatePair {
+	byService := make(map[string][]int)
+	var order []string
+	for i := range subs {
+		key := normalizeServiceName(subs[i].Service)
+		if _, seen := byService[key]; !seen {
+			order = append(order, key)
+		}
+		byService[key] = append(byService[key], i)
+	}
+
+	out := []DuplicatePair{}
+	for _, key := range order {
+		idx := byService[key]
+		for i := 0; i < len(idx); i++ {
+			for j := i + 1; j < len(idx); j++ {
+				a, b := &subs[idx[i]], &subs[idx[j]]
+				start, end, ok := overlapInterval(a, b)
+				if !ok {
+					continue
+				}
+				pair := DuplicatePair{
+					Service:       key,
+					OverlapStart:  formatMonthYear(start),
+					Subscriptions: []models.Subscription{*a, *b},
+				}
+				if end != nil {
+					e := formatMonthYear(*end)
+					pair.OverlapEnd = &e
+				}
+				out = append(out, pair)
+			}
+		}
+	}
+	return out
+}
+
+// findOverlapping returns the IDs of existing subscriptions for the same
+// normalized service as sub whose date ranges overlap with it.
+func findOverlapping(existing []models.Subscription, sub *models.Subscription) []string {
+	key := normalizeServiceName(sub.Service)
+	var out []string
+	for i := range existing {
+		if existing[i].ID == sub.ID || normalizeServiceName(existing[i].Service) != key {
+			continue
+		}
+		if _, _, ok := overlapInterval(&existing[i], sub); ok {
+			out = append(out, existing[i].ID.String())
+		}
+	}
+	return out
+}
@@ -0,0 +1,330 @@
+package subscription_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/internal/events"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/google/uuid"
+)
+
+func newService(requireIfMatch bool) *subscription.SubscriptionService {
+	return subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+		Repository:     subscription.NewMemoryRepository(),
+		RequireIfMatch: requireIfMatch,
+	})
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func assertServiceError(t *testing.T, err error, kind subscription.ErrorKind, msg string) {
+	t.Helper()
+	var svcErr *subscription.ServiceError
+	if !errors.As(err, &svcErr) {
+		t.Fatalf("got error %v, want ServiceError %q", err, msg)
+	}
+	if svcErr.Kind != kind || svcErr.Message != msg {
+		t.Fatalf("got ServiceError{%d, %q}, want {%d, %q}", svcErr.Kind, svcErr.Message, kind, msg)
+	}
+}
+
+func assertViolations(t *testing.T, err error, want ...subscription.FieldViolation) {
+	t.Helper()
+	assertServiceError(t, err, subscription.KindInvalid, subscription.ErrValidationFailed)
+	var svcErr *subscription.ServiceError
+	errors.As(err, &svcErr)
+	if len(svcErr.Violations) != len(want) {
+		t.Fatalf("got violations %+v, want %+v", svcErr.Violations, want)
+	}
+	for i, v := range svcErr.Violations {
+		if v.Field != want[i].Field || v.Code != want[i].Code || v.Message == "" {
+			t.Fatalf("violation %d = %+v, want field %s code %s", i, v, want[i].Field, want[i].Code)
+		}
+	}
+}
+
+func violation(field, code string) subscription.FieldViolation {
+	return subscription.FieldViolation{Field: field, Code: code}
+}
+
+func TestServiceCreateValidation(t *testing.T) {
+	service := newService(false)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	tests := []struct {
+		name string
+		body subscription.SubscriptionCreateRequest
+		want []subscription.FieldViolation
+	}{
+		{name: "bad user", body: subscription.SubscriptionCreateRequest{Service: "Netflix", UserID: "nope", StartDate: "01-2025"},
+			want: []subscription.FieldViolation{violation("user_id", subscription.CodeInvalidFormat)}},
+		{name: "bad start", body: subscription.SubscriptionCreateRequest{Service: "Netflix", UserID: userID, StartDate: "2025-01"},
+			want: []subscription.FieldViolation{violation("start_date", subscription.CodeInvalidFormat)}},
+		{name: "bad end", body: subscription.SubscriptionCreateRequest{Service: "Netflix", UserID: userID, StartDate: "01-2025", EndDate: strPtr("13-2025")},
+			want: []subscription.FieldViolation{violation("end_date", subscription.CodeInvalidFormat)}},
+		{name: "end before start", body: subscription.SubscriptionCreateRequest{Service: "Netflix", UserID: userID, StartDate: "05-2025", EndDate: strPtr("04-2025")},
+			want: []subscription.FieldViolation{violation("end_date", subscription.CodeDateOrder)}},
+		{name: "everything at once", body: subscription.SubscriptionCreateRequest{Service: "  ", PriceRUB: -1},
+			want: []subscription.FieldViolation{
+				violation("service_name", subscription.CodeRequired),
+				violation("price", subscription.CodeOutOfRange),
+				violation("user_id", subscription.CodeRequired),
+				violation("start_date", subscription.CodeRequired),
+			}},
+		{name: "long name and high price", body: subscription.SubscriptionCreateRequest{
+			Service: strings.Repeat("я", subscription.MaxServiceNameLength+1), PriceRUB: subscription.MaxPriceRUB + 1, UserID: userID, StartDate: "01-2025"},
+			want: []subscription.FieldViolation{
+				violation("service_name", subscription.CodeTooLong),
+				violation("price", subscription.CodeOutOfRange),
+			}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Create(ctx, &tt.body)
+			assertViolations(t, err, tt.want...)
+		})
+	}
+
+	if _, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service: strings.Repeat("я", subscription.MaxServiceNameLength), PriceRUB: subscription.MaxPriceRUB, UserID: userID, StartDate: "01-2025",
+	}); err != nil {
+		t.Fatalf("Create at the limits: %v", err)
+	}
+}
+
+func TestServicePatchValidation(t *testing.T) {
+	service := newService(false)
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service: "Netflix", PriceRUB: 100, UserID: uuid.NewString(), StartDate: "01-2025", EndDate: strPtr("06-2025"),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id := created.Subscription.ID
+
+	_, err = service.Patch(ctx, id, &subscription.SubscriptionPatchRequest{PriceRUB: int64Ptr(-5), StartDate: strPtr("")}, "")
+	assertViolations(t, err, violation("price", subscription.CodeOutOfRange), violation("start_date", subscription.CodeRequired))
+
+	_, err = service.Patch(ctx, id, &subscription.SubscriptionPatchRequest{StartDate: strPtr("09-2025")}, "")
+	assertViolations(t, err, violation("start_date", subscription.CodeDateOrder))
+}
+
+func TestServiceCreateReportsOverlaps(t *testing.T) {
+	service := newService(false)
+	ctx := context.Background()
+	userID := uuid.NewString()
+
+	first, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service: "Spotify", PriceRUB: 199, UserID: userID, StartDate: "01-2025", EndDate: strPtr("12-2025"),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(first.OverlapsWith) != 0 {
+		t.Fatalf("first subscription overlaps %v", first.OverlapsWith)
+	}
+
+	second, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service: " spotify ", PriceRUB: 1990, UserID: userID, StartDate: "06-2025",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(second.OverlapsWith) != 1 || second.OverlapsWith[0] != first.Subscription.ID.String() {
+		t.Fatalf("OverlapsWith = %v, want [%s]", second.OverlapsWith, first.Subscription.ID)
+	}
+
+	duplicates, err := service.Duplicates(ctx, first.Subscription.UserID)
+	if err != nil {
+		t.Fatalf("Duplicates: %v", err)
+	}
+	if len(duplicates) != 1 || duplicates[0].OverlapStart != "06-2025" || *duplicates[0].OverlapEnd != "12-2025" {
+		t.Fatalf("Duplicates = %+v", duplicates)
+	}
+}
+
+func TestServicePatchIfMatch(t *testing.T) {
+	service := newService(true)
+	ctx := context.Background()
+
+	created, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{
+		Service: "Netflix", PriceRUB: 499, UserID: uuid.NewString(), StartDate: "01-2025",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id := created.Subscription.ID
+	patch := &subscription.SubscriptionPatchRequest{PriceRUB: int64Ptr(599)}
+
+	_, err = service.Patch(ctx, id, patch, "")
+	assertServiceError(t, err, subscription.KindPreconditionRequired, subscription.ErrIfMatchRequired)
+
+	_, err = service.Patch(ctx, id, patch, `"2"`)
+	assertServiceError(t, err, subscription.KindPreconditionFailed, subscription.ErrPreconditionFailed)
+
+	sub, err := service.Patch(ctx, id, patch, `"0", "1"`)
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if sub.PriceRUB != 599 || sub.Version != 2 {
+		t.Fatalf("Patch returned %+v", sub)
+	}
+
+	err = service.Delete(ctx, id, `"1"`)
+	assertServiceError(t, err, subscription.KindPreconditionFailed, subscription.ErrPreconditionFailed)
+	if err := service.Delete(ctx, id, "*"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, err = service.Get(ctx, id)
+	if !errors.Is(err, subscription.ErrNotFound) {
+		t.Fatalf("Get after delete: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestServiceBatchRollsBack(t *testing.T) {
+	service := newService(false)
+	ctx := context.Background()
+	userID := uuid.New()
+
+	data := func(v any) json.RawMessage {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	create := subscription.BatchOperation{
+		Op:   subscription.BatchOpCreate,
+		Data: data(subscription.SubscriptionCreateRequest{Service: "A", PriceRUB: 1, UserID: userID.String(), StartDate: "01-2025"}),
+	}
+
+	results, committed, err := service.Batch(ctx, []subscription.BatchOperation{
+		create,
+		{Op: subscription.BatchOpDelete, ID: uuid.NewString()},
+		create,
+	})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	if committed {
+		t.Fatal("Batch committed despite a failing operation")
+	}
+	if len(results) != 3 || results[0].Err != nil || !errors.Is(results[1].Err, subscription.ErrNotFound) {
+		t.Fatalf("Batch results = %+v", results)
+	}
+	if results[2].Op != subscription.BatchOpCreate || !errors.Is(results[2].Err, subscription.ErrNotRun) {
+		t.Fatalf("operation after the failed one = %+v, want it not run", results[2])
+	}
+	if subs, _ := service.ListByUser(ctx, userID, 0, 10); len(subs) != 0 {
+		t.Fatalf("rolled back batch left %d subscriptions", len(subs))
+	}
+
+	results, committed, err = service.Batch(ctx, []subscription.BatchOperation{create, create})
+	if err != nil || !committed || len(results) != 2 {
+		t.Fatalf("Batch = %+v, %v, %v", results, committed, err)
+	}
+	if subs, _ := service.ListByUser(ctx, userID, 0, 10); len(subs) != 2 {
+		t.Fatalf("committed batch created %d subscriptions, want 2", len(subs))
+	}
+
+	_, _, err = service.Batch(ctx, nil)
+	assertServiceError(t, err, subscription.KindInvalid, subscription.ErrEmptyBatch)
+}
+
+func TestServicePublishesEvents(t *testing.T) {
+	bus := events.NewBus(0)
+	service := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+		Repository: subscription.NewMemoryRepository(),
+		Events:     bus,
+	})
+	listener, _, _ := bus.Subscribe(0, false)
+	defer listener.Close()
+	ctx := context.Background()
+	userID := uuid.New()
+
+	created, err := service.Create(ctx, &subscription.SubscriptionCreateRequest{Service: "A", PriceRUB: 1, UserID: userID.String(), StartDate: "01-2025"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Subscription.ID
+	if _, err := service.Patch(ctx, id, &subscription.SubscriptionPatchRequest{PriceRUB: int64Ptr(2)}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.Patch(ctx, id, &subscription.SubscriptionPatchRequest{PriceRUB: int64Ptr(3)}, `"99"`); err == nil {
+		t.Fatal("Patch with a stale If-Match succeeded")
+	}
+	// A rolled back batch publishes nothing.
+	if _, committed, _ := service.Batch(ctx, []subscription.BatchOperation{
+		{Op: subscription.BatchOpDelete, ID: id.String()},
+		{Op: subscription.BatchOpDelete, ID: uuid.NewString()},
+	}); committed {
+		t.Fatal("Batch committed despite a failing operation")
+	}
+	if _, committed, _ := service.Batch(ctx, []subscription.BatchOperation{{Op: subscription.BatchOpDelete, ID: id.String()}}); !committed {
+		t.Fatal("Batch was not committed")
+	}
+
+	for _, want := range []string{events.TypeCreated, events.TypeUpdated, events.TypeDeleted} {
+		select {
+		case e := <-listener.C:
+			if e.Type != want || e.SubscriptionID != id || e.UserID != userID {
+				t.Fatalf("event = %+v, want %s of %s", e, want, id)
+			}
+			if (e.Subscription == nil) != (want == events.TypeDeleted) {
+				t.Fatalf("%s event has subscription %v", want, e.Subscription)
+			}
+		default:
+			t.Fatalf("no %s event", want)
+		}
+	}
+	select {
+	case e := <-listener.C:
+		t.Fatalf("unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestServiceMonthlySpend(t *testing.T) {
+	service := newService(false)
+	ctx := context.Background()
+	userID := uuid.New()
+	for _, body := range []subscription.SubscriptionCreateRequest{
+		{Service: "Netflix", PriceRUB: 500, UserID: userID.String(), StartDate: "02-2025"},
+		{Service: "Spotify", PriceRUB: 200, UserID: userID.String(), StartDate: "01-2025", EndDate: strPtr("02-2025")},
+		{Service: "Other user", PriceRUB: 999, UserID: uuid.NewString(), StartDate: "01-2025"},
+	} {
+		if _, err := service.Create(ctx, &body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spend, err := service.MonthlySpend(ctx, userID, "01-2025", "04-2025")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []subscription.MonthSpend{{"01-2025", 200}, {"02-2025", 700}, {"03-2025", 500}, {"04-2025", 500}}
+	if len(spend) != len(want) {
+		t.Fatalf("MonthlySpend = %+v, want %+v", spend, want)
+	}
+	for i := range want {
+		if spend[i] != want[i] {
+			t.Fatalf("MonthlySpend = %+v, want %+v", spend, want)
+		}
+	}
+
+	_, err = service.MonthlySpend(ctx, userID, "04-2025", "01-2025")
+	assertServiceError(t, err, subscription.KindInvalid, subscription.ErrInvalidDateInterval)
+}
@@ -0,0 +1,158 @@
+package subscription
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/SenechkaP/subs-tracker/pkg/req"
+	"github.com/google/uuid"
+)
+
+const (
+	MaxServiceNameLength = 100
+	MinPriceRUB          = 0
+	MaxPriceRUB          = 1_000_000
+)
+
+// Violation codes are stable and meant for programs; messages are for people.
+const (
+	CodeRequired      = "required"
+	CodeTooLong       = "too_long"
+	CodeOutOfRange    = "out_of_range"
+	CodeInvalidFormat = "invalid_format"
+	CodeDateOrder     = "date_order"
+	CodeUnknownField  = req.ReasonUnknownField
+	CodeInvalidType   = req.ReasonInvalidType
+)
+
+// FieldViolation is one problem with one field of a request body. Field is
+// the JSON path of the field.
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// violations collects every problem found in a request.
+type violations []FieldViolation
+
+func (v *violations) add(field, code, format string, args ...any) {
+	*v = append(*v, FieldViolation{Field: field, Code: code, Message: fmt.Sprintf(format, args...)})
+}
+
+// invalidRequest wraps violations into the ServiceError reported for them,
+// or returns nil when there are none.
+func invalidRequest(v []FieldViolation) error {
+	if len(v) == 0 {
+		return nil
+	}
+	return &ServiceError{Kind: KindInvalid, Code: ProblemValidationFailed, Message: ErrValidationFailed, Violations: v}
+}
+
+// ValidateCreateRequest checks every field of a create request and reports
+// all violations together.
+func ValidateCreateRequest(body *SubscriptionCreateRequest) []FieldViolation {
+	var v violations
+	validateServiceName(&v, body.Service)
+	validatePrice(&v, body.PriceRUB)
+	if body.UserID == "" {
+		v.add("user_id", CodeRequired, "must not be empty")
+	} else if _, err := uuid.Parse(body.UserID); err != nil {
+		v.add("user_id", CodeInvalidFormat, "must be a UUID")
+	}
+	start, startOK := validateMonth(&v, "start_date", body.StartDate, true)
+	if body.EndDate != nil {
+		end, endOK := validateMonth(&v, "end_date", *body.EndDate, false)
+		if startOK && endOK && !end.IsZero() && end.Before(start) {
+			v.add("end_date", CodeDateOrder, "must not be before start_date")
+		}
+	}
+	return v
+}
+
+// ValidatePatchRequest checks the fields present in a patch request. The
+// order of the dates can only be checked against the stored subscription.
+func ValidatePatchRequest(body *SubscriptionPatchRequest) []FieldViolation {
+	var v violations
+	if body.PriceRUB != nil {
+		validatePrice(&v, *body.PriceRUB)
+	}
+	var start, end time.Time
+	startOK, endOK := true, true
+	if body.StartDate != nil {
+		start, startOK = validateMonth(&v, "start_date", *body.StartDate, true)
+	}
+	if body.EndDate != nil {
+		end, endOK = validateMonth(&v, "end_date", *body.EndDate, false)
+	}
+	if startOK && endOK && !start.IsZero() && !end.IsZero() && end.Before(start) {
+		v.add("end_date", CodeDateOrder, "must not be before start_date")
+	}
+	return v
+}
+
+// DecodeViolations turns the req.FieldErrors of a decoded body into
+// violations. ok is false for any other error.
+func DecodeViolations(err error) (v []FieldViolation, ok bool) {
+	var fieldErrs req.FieldErrors
+	if !errors.As(err, &fieldErrs) {
+		return nil, false
+	}
+	for _, fe := range fieldErrs {
+		message := "is not a known field"
+		if fe.Reason == req.ReasonInvalidType {
+			message = "has the wrong type"
+		}
+		v = append(v, FieldViolation{Field: fe.Field, Code: fe.Reason, Message: message})
+	}
+	return v, true
+}
+
+// mergeViolations adds the rule violations to those found while decoding.
+// A field that failed to decode is left zero, so its rule violations would
+// only repeat the problem and are dropped.
+func mergeViolations(decoded, rules []FieldViolation) []FieldViolation {
+	for _, v := range rules {
+		if !slices.ContainsFunc(decoded, func(d FieldViolation) bool { return d.Field == v.Field }) {
+			decoded = append(decoded, v)
+		}
+	}
+	return decoded
+}
+
+func validateServiceName(v *violations, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.add("service_name", CodeRequired, "must not be empty")
+	case utf8.RuneCountInString(name) > MaxServiceNameLength:
+		v.add("service_name", CodeTooLong, "must be at most %d characters", MaxServiceNameLength)
+	}
+}
+
+func validatePrice(v *violations, price int64) {
+	if price < MinPriceRUB || price > MaxPriceRUB {
+		v.add("price", CodeOutOfRange, "must be between %d and %d", MinPriceRUB, MaxPriceRUB)
+	}
+}
+
+// validateMonth parses an MM-YYYY field. An empty optional value is valid
+// and returns the zero time; ok reports whether the value is usable.
+func validateMonth(v *violations, field, value string, required bool) (t time.Time, ok bool) {
+	if value == "" {
+		if required {
+			v.add(field, CodeRequired, "must not be empty")
+			return time.Time{}, false
+		}
+		return time.Time{}, true
+	}
+	t, err := parseMonthYear(value)
+	if err != nil {
+		v.add(field, CodeInvalidFormat, "must be a month in MM-YYYY format")
+		return time.Time{}, false
+	}
+	return t, true
+}
@@ -0,0 +1,553 @@
+package ui
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"github.com/google/uuid"
+)
+
+const (
+	ErrCrossOriginForm = "FORM WAS SUBMITTED FROM ANOTHER SITE"
+	ErrRangeTooLong    = "CHART RANGE IS TOO LONG"
+)
+
+const ProblemCrossOriginForm = "cross_origin_form"
+
+const monthYearLayout = "01-2006"
+
+const (
+	pageSize = 20
+	// maxChartMonths bounds the chart, which has a bar per month.
+	maxChartMonths = 36
+	chartHeight    = 160
+	barWidth       = 24
+)
+
+// notices are the messages shown after a redirect, keyed by the notice
+// query parameter so that arbitrary text cannot be injected into the page.
+var notices = map[string]string{
+	"created": "Subscription created.",
+	"updated": "Subscription updated.",
+	"ended":   "Subscription ended.",
+}
+
+var errRangeTooLong = errors.New("chart range is too long")
+
+//go:embed templates static
+var files embed.FS
+
+type UIHandlerDeps struct {
+	Service *subscription.SubscriptionService
+}
+
+type UIHandler struct {
+	Service *subscription.SubscriptionService
+	pages   map[string]*template.Template
+}
+
+// NewUIHandler serves the dashboard under /ui. Pages are rendered on the
+// server and forms post back to it, so the UI needs no JavaScript.
+func NewUIHandler(router *http.ServeMux, deps *UIHandlerDeps) {
+	handler := UIHandler{Service: deps.Service, pages: parsePages()}
+	static, _ := fs.Sub(files, "static")
+	router.Handle("GET /ui/static/", http.StripPrefix("/ui/static/", http.FileServerFS(static)))
+	router.HandleFunc("GET /ui", handler.Index())
+	router.HandleFunc("GET /ui/users/{user_id}", handler.User())
+	router.HandleFunc("POST /ui/users/{user_id}/subscriptions", handler.Create())
+	router.HandleFunc("GET /ui/subscriptions/{sub_id}/edit", handler.Edit())
+	router.HandleFunc("POST /ui/subscriptions/{sub_id}", handler.Update())
+	router.HandleFunc("POST /ui/subscriptions/{sub_id}/end", handler.End())
+}
+
+func parsePages() map[string]*template.Template {
+	funcs := template.FuncMap{"rub": formatRUB}
+	pages := make(map[string]*template.Template)
+	for _, name := range []string{"index.html", "user.html", "edit.html", "error.html"} {
+		pages[name] = template.Must(template.New(name).Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+name))
+	}
+	return pages
+}
+
+type indexPage struct {
+	UserID string
+	Error  string
+}
+
+type subscriptionRow struct {
+	ID        string
+	Service   string
+	Price     int64
+	StartDate string
+	EndDate   string
+	Version   int64
+}
+
+type chartBar struct {
+	Month  string
+	Label  string
+	Total  int64
+	X      int
+	Y      int
+	Height int
+}
+
+// formValues holds what the user typed into a form and the violations found
+// in it, keyed by field, so that a rejected form is shown again as entered.
+type formValues struct {
+	Service   string
+	Price     string
+	StartDate string
+	EndDate   string
+	Errors    map[string]string
+}
+
+type userPage struct {
+	UserID        string
+	Notice        string
+	Error         string
+	Subscriptions []subscriptionRow
+	PrevOffset    int
+	NextOffset    int
+	HasPrev       bool
+	HasNext       bool
+	CurrentMonth  string
+	Start         string
+	End           string
+	RangeError    string
+	Total         int64
+	ChartTotal    int64
+	Chart         []chartBar
+	ChartWidth    int
+	ChartHeight   int
+	Form          formValues
+}
+
+type editPage struct {
+	Subscription subscriptionRow
+	UserID       string
+	Error        string
+	Form         formValues
+}
+
+type errorPage struct {
+	Status    int
+	Message   string
+	RequestID string
+}
+
+func (handler *UIHandler) Index() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := strings.TrimSpace(r.URL.Query().Get("user_id"))
+		if userID == "" {
+			handler.render(w, r, http.StatusOK, "index.html", indexPage{})
+			return
+		}
+		if _, err := uuid.Parse(userID); err != nil {
+			handler.render(w, r, http.StatusBadRequest, "index.html", indexPage{UserID: userID, Error: subscription.ErrInvalidUserUUID})
+			return
+		}
+		http.Redirect(w, r, "/ui/users/"+userID, http.StatusSeeOther)
+	}
+}
+
+func (handler *UIHandler) User() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := handler.userID(w, r)
+		if !ok {
+			return
+		}
+		page := &userPage{Notice: notices[r.URL.Query().Get("notice")]}
+		handler.renderUser(w, r, http.StatusOK, userID, page)
+	}
+}
+
+func (handler *UIHandler) Create() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := handler.userID(w, r)
+		if !ok || !handler.checkForm(w, r) {
+			return
+		}
+		form := formValues{
+			Service:   strings.TrimSpace(r.PostFormValue("service_name")),
+			Price:     strings.TrimSpace(r.PostFormValue("price")),
+			StartDate: strings.TrimSpace(r.PostFormValue("start_date")),
+			EndDate:   strings.TrimSpace(r.PostFormValue("end_date")),
+		}
+		body := &subscription.SubscriptionCreateRequest{
+			Service:   form.Service,
+			UserID:    userID.String(),
+			StartDate: form.StartDate,
+		}
+		if form.EndDate != "" {
+			body.EndDate = &form.EndDate
+		}
+		price, priceErr := parsePrice(form.Price)
+		body.PriceRUB = price
+
+		var err error
+		if priceErr != nil {
+			err = validationError(append(subscription.ValidateCreateRequest(body), *priceErr))
+		} else {
+			_, err = handler.Service.Create(r.Context(), body)
+		}
+		if err != nil {
+			page := &userPage{Form: form}
+			status := handler.formError(r, "UICreate", err, &page.Error, &page.Form)
+			handler.renderUser(w, r, status, userID, page)
+			return
+		}
+		http.Redirect(w, r, "/ui/users/"+userID.String()+"?notice=created", http.StatusSeeOther)
+	}
+}
+
+func (handler *UIHandler) Edit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sub, ok := handler.subscription(w, r)
+		if !ok {
+			return
+		}
+		row := toRow(sub)
+		page := editPage{
+			Subscription: row,
+			UserID:       sub.UserID.String(),
+			Form:         formValues{Price: strconv.FormatInt(row.Price, 10), StartDate: row.StartDate, EndDate: row.EndDate},
+		}
+		handler.render(w, r, http.StatusOK, "edit.html", page)
+	}
+}
+
+func (handler *UIHandler) Update() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !handler.checkForm(w, r) {
+			return
+		}
+		sub, ok := handler.subscription(w, r)
+		if !ok {
+			return
+		}
+		form := formValues{
+			Price:     strings.TrimSpace(r.PostFormValue("price")),
+			StartDate: strings.TrimSpace(r.PostFormValue("start_date")),
+			EndDate:   strings.TrimSpace(r.PostFormValue("end_date")),
+		}
+		body := &subscription.SubscriptionPatchRequest{StartDate: &form.StartDate, EndDate: &form.EndDate}
+		price, priceErr := parsePrice(form.Price)
+		body.PriceRUB = &price
+
+		var err error
+		if priceErr != nil {
+			err = validationError(append(subscription.ValidatePatchRequest(body), *priceErr))
+		} else {
+			_, err = handler.Service.Patch(r.Context(), sub.ID, body, ifMatch(r.PostFormValue("version")))
+		}
+		if err != nil {
+			page := editPage{Subscription: toRow(sub), UserID: sub.UserID.String(), Form: form}
+			status := handler.formError(r, fmt.Sprintf("UIUpdate sub_id=%s", sub.ID), err, &page.Error, &page.Form)
+			handler.render(w, r, status, "edit.html", page)
+			return
+		}
+		http.Redirect(w, r, "/ui/users/"+sub.UserID.String()+"?notice=updated", http.StatusSeeOther)
+	}
+}
+
+func (handler *UIHandler) End() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !handler.checkForm(w, r) {
+			return
+		}
+		sub, ok := handler.subscription(w, r)
+		if !ok {
+			return
+		}
+		endDate := strings.TrimSpace(r.PostFormValue("end_date"))
+		body := &subscription.SubscriptionPatchRequest{EndDate: &endDate}
+		if _, err := handler.Service.Patch(r.Context(), sub.ID, body, ifMatch(r.PostFormValue("version"))); err != nil {
+			page := &userPage{}
+			status := handler.formError(r, fmt.Sprintf("UIEnd sub_id=%s", sub.ID), err, &page.Error, nil)
+			handler.renderUser(w, r, status, sub.UserID, page)
+			return
+		}
+		http.Redirect(w, r, "/ui/users/"+sub.UserID.String()+"?notice=ended", http.StatusSeeOther)
+	}
+}
+
+// renderUser fills page with the user's subscriptions, the spend for the
+// requested range and its chart, and renders it.
+func (handler *UIHandler) renderUser(w http.ResponseWriter, r *http.Request, status int, userID uuid.UUID, page *userPage) {
+	q := r.URL.Query()
+	now := time.Now().UTC()
+	page.UserID = userID.String()
+	page.CurrentMonth = now.Format(monthYearLayout)
+	page.Start = q.Get("start")
+	if page.Start == "" {
+		page.Start = now.AddDate(0, -11, 0).Format(monthYearLayout)
+	}
+	page.End = q.Get("end")
+	if page.End == "" {
+		page.End = page.CurrentMonth
+	}
+
+	offset, err := strconv.Atoi(q.Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	// One extra row tells whether there is a next page.
+	subs, err := handler.Service.ListByUser(r.Context(), userID, offset, pageSize+1)
+	if err != nil {
+		handler.renderError(w, r, fmt.Sprintf("UIUser user_id=%s", userID), err)
+		return
+	}
+	page.HasNext = len(subs) > pageSize
+	page.HasPrev = offset > 0
+	page.NextOffset = offset + pageSize
+	page.PrevOffset = max(0, offset-pageSize)
+	for i, sub := range subs {
+		if i == pageSize {
+			break
+		}
+		page.Subscriptions = append(page.Subscriptions, toRow(&sub))
+	}
+
+	if err := handler.fillChart(r, userID, page); err != nil {
+		var svcErr *subscription.ServiceError
+		switch {
+		case errors.Is(err, errRangeTooLong):
+			page.RangeError = ErrRangeTooLong
+		case errors.As(err, &svcErr):
+			page.RangeError = svcErr.Message
+		default:
+			handler.renderError(w, r, fmt.Sprintf("UIUser spend user_id=%s", userID), err)
+			return
+		}
+	}
+	handler.render(w, r, status, "user.html", page)
+}
+
+// fillChart sums the prices of the subscriptions active in the range, as
+// /subscriptions/sum does, and charts the user's spend month by month. The
+// chart total differs from the sum: an open-ended subscription counts in
+// every month of the range.
+func (handler *UIHandler) fillChart(r *http.Request, userID uuid.UUID, page *userPage) error {
+	// The sum validates the range first, so that an invalid month is
+	// reported as such rather than as a range too long.
+	total, err := handler.Service.SumByMonthRange(r.Context(), page.Start, page.End, &userID, nil)
+	if err != nil {
+		return err
+	}
+	page.Total = total
+
+	start, err := time.Parse(monthYearLayout, page.Start)
+	if err != nil {
+		return err
+	}
+	end, err := time.Parse(monthYearLayout, page.End)
+	if err != nil {
+		return err
+	}
+	months := (end.Year()-start.Year())*12 + int(end.Month()) - int(start.Month()) + 1
+	if months > maxChartMonths {
+		return errRangeTooLong
+	}
+	spend, err := handler.Service.MonthlySpend(r.Context(), userID, page.Start, page.End)
+	if err != nil {
+		return err
+	}
+
+	var peak int64
+	for _, month := range spend {
+		peak = max(peak, month.Total)
+		page.ChartTotal += month.Total
+	}
+	for i, month := range spend {
+		bar := chartBar{Month: month.Month, Total: month.Total, X: i * barWidth}
+		if peak > 0 {
+			bar.Height = int(month.Total * chartHeight / peak)
+		}
+		bar.Y = chartHeight - bar.Height
+		// Label every month of a short range, and every third otherwise.
+		if len(spend) <= 12 || i%3 == 0 {
+			bar.Label = month.Month[:3] + month.Month[5:]
+		}
+		page.Chart = append(page.Chart, bar)
+	}
+	page.ChartWidth = len(spend) * barWidth
+	page.ChartHeight = chartHeight
+	return nil
+}
+
+// formError logs err and turns it into the message and per-field errors
+// shown with the form. It returns the status to render the form with.
+func (handler *UIHandler) formError(r *http.Request, op string, err error, message *string, form *formValues) int {
+	var svcErr *subscription.ServiceError
+	if !errors.As(err, &svcErr) {
+		logger.FromRequest(r).Errorf("%s db error err=%v", op, err)
+		*message = subscription.ErrInternal
+		return http.StatusInternalServerError
+	}
+	logger.FromRequest(r).Warnf("%s err=%v", op, svcErr)
+	*message = svcErr.Message
+	if form != nil && len(svcErr.Violations) > 0 {
+		form.Errors = make(map[string]string, len(svcErr.Violations))
+		for _, v := range svcErr.Violations {
+			form.Errors[v.Field] = v.Message
+		}
+	}
+	switch svcErr.Kind {
+	case subscription.KindNotFound:
+		return http.StatusNotFound
+	case subscription.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (handler *UIHandler) userID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		logger.FromRequest(r).Warnf("UI invalid user uuid user_id=%s", r.PathValue("user_id"))
+		handler.render(w, r, http.StatusBadRequest, "index.html", indexPage{UserID: r.PathValue("user_id"), Error: subscription.ErrInvalidUserUUID})
+		return uuid.Nil, false
+	}
+	logger.SetUserID(r.Context(), userID.String())
+	return userID, true
+}
+
+func (handler *UIHandler) subscription(w http.ResponseWriter, r *http.Request) (*models.Subscription, bool) {
+	subID, err := uuid.Parse(r.PathValue("sub_id"))
+	if err != nil {
+		logger.FromRequest(r).Warnf("UI invalid sub uuid sub_id=%s", r.PathValue("sub_id"))
+		handler.render(w, r, http.StatusBadRequest, "error.html", errorPage{Status: http.StatusBadRequest, Message: subscription.ErrInvalidSubscriptionUUID})
+		return nil, false
+	}
+	sub, err := handler.Service.Get(r.Context(), subID)
+	if err != nil {
+		handler.renderError(w, r, fmt.Sprintf("UI get sub_id=%s", subID), err)
+		return nil, false
+	}
+	logger.SetUserID(r.Context(), sub.UserID.String())
+	return sub, true
+}
+
+// checkForm rejects forms posted from other sites. CORS does not stop a
+// plain cross-site form post, so it is checked here: browsers send
+// Sec-Fetch-Site, and older ones at least Origin.
+func (handler *UIHandler) checkForm(w http.ResponseWriter, r *http.Request) bool {
+	sameOrigin := true
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		sameOrigin = site == "same-origin" || site == "none"
+	} else if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		sameOrigin = err == nil && u.Host == r.Host
+	}
+	if !sameOrigin {
+		logger.FromRequest(r).Warnf("UI cross-origin form origin=%s", r.Header.Get("Origin"))
+		res.WriteProblem(w, r, http.StatusForbidden, ProblemCrossOriginForm, ErrCrossOriginForm)
+		return false
+	}
+	return true
+}
+
+// renderError shows the error page for a failed lookup. Internal errors are
+// only logged.
+func (handler *UIHandler) renderError(w http.ResponseWriter, r *http.Request, op string, err error) {
+	page := errorPage{Status: http.StatusInternalServerError, Message: subscription.ErrInternal, RequestID: logger.RequestID(r.Context())}
+	var svcErr *subscription.ServiceError
+	if errors.As(err, &svcErr) {
+		logger.FromRequest(r).Warnf("%s err=%v", op, svcErr)
+		page.Status, page.Message = http.StatusBadRequest, svcErr.Message
+		if svcErr.Kind == subscription.KindNotFound {
+			page.Status = http.StatusNotFound
+		}
+	} else {
+		logger.FromRequest(r).Errorf("%s db error err=%v", op, err)
+	}
+	handler.render(w, r, page.Status, "error.html", page)
+}
+
+func (handler *UIHandler) render(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	var buf bytes.Buffer
+	if err := handler.pages[name].ExecuteTemplate(&buf, "layout", data); err != nil {
+		logger.FromRequest(r).Errorf("UI render %s err=%v", name, err)
+		res.WriteProblem(w, r, http.StatusInternalServerError, res.ProblemInternal, subscription.ErrInternal)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// validationError reports violations found in a form the way
+// SubscriptionService reports them for a request.
+func validationError(violations []subscription.FieldViolation) error {
+	return &subscription.ServiceError{
+		Kind:       subscription.KindInvalid,
+		Code:       subscription.ProblemValidationFailed,
+		Message:    subscription.ErrValidationFailed,
+		Violations: violations,
+	}
+}
+
+// parsePrice reads the price field of a form. An unparsable price is
+// reported as a violation rather than silently turned into zero.
+func parsePrice(s string) (int64, *subscription.FieldViolation) {
+	price, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, &subscription.FieldViolation{Field: "price", Code: subscription.CodeInvalidType, Message: "must be a whole number of rubles"}
+	}
+	return price, nil
+}
+
+// ifMatch turns the version a form was rendered with into an If-Match
+// value, so that saving a stale form fails instead of overwriting.
+func ifMatch(version string) string {
+	if _, err := strconv.ParseInt(version, 10, 64); err != nil {
+		return ""
+	}
+	return strconv.Quote(version)
+}
+
+func toRow(sub *models.Subscription) subscriptionRow {
+	row := subscriptionRow{
+		ID:        sub.ID.String(),
+		Service:   sub.Service,
+		Price:     sub.PriceRUB,
+		StartDate: sub.StartDate.UTC().Format(monthYearLayout),
+		Version:   sub.Version,
+	}
+	if sub.EndDate != nil {
+		row.EndDate = sub.EndDate.UTC().Format(monthYearLayout)
+	}
+	return row
+}
+
+// formatRUB groups thousands with non-breaking spaces: 1 000 000.
+func formatRUB(v int64) string {
+	s := strconv.FormatInt(v, 10)
+	sign := ""
+	if v < 0 {
+		sign, s = "-", s[1:]
+	}
+	var b strings.Builder
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString("\u00a0")
+		}
+		b.WriteRune(c)
+	}
+	return sign + b.String()
+}
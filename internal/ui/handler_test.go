@@ -0,0 +1,118 @@
+package ui_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/internal/ui"
+	"github.com/google/uuid"
+)
+
+func newRouter(service *subscription.SubscriptionService) *http.ServeMux {
+	router := http.NewServeMux()
+	ui.NewUIHandler(router, &ui.UIHandlerDeps{Service: service})
+	return router
+}
+
+func postForm(router http.Handler, path string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func get(router http.Handler, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func TestUIManagesSubscriptions(t *testing.T) {
+	service := subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{Repository: subscription.NewMemoryRepository()})
+	router := newRouter(service)
+	userID := uuid.NewString()
+	userPath := "/ui/users/" + userID
+
+	w := postForm(router, userPath+"/subscriptions", url.Values{
+		"service_name": {"Netflix"}, "price": {"1500"}, "start_date": {"01-2025"},
+	}, http.Header{"Sec-Fetch-Site": {"same-origin"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != userPath+"?notice=created" {
+		t.Fatalf("create = %d %q, want a redirect to the user page", w.Code, w.Header().Get("Location"))
+	}
+
+	w = get(router, userPath+"?notice=created&start=01-2025&end=03-2025")
+	body := w.Body.String()
+	for _, want := range []string{"Subscription created.", "Netflix", "<title>03-2025: 1\u00a0500 ₽</title>",
+		// The sum counts the subscription once, as /subscriptions/sum does,
+		"<strong>1\u00a0500 ₽</strong>",
+		// while the chart counts it in each of the three months.
+		"4\u00a0500 ₽ in total"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("user page does not contain %q:\n%s", want, body)
+		}
+	}
+
+	for _, tt := range []struct{ query, want string }{
+		{"start=13-2025&end=03-2025", subscription.ErrInvalidStartDate},
+		{"start=01-2020&end=03-2025", ui.ErrRangeTooLong},
+	} {
+		if w := get(router, userPath+"?"+tt.query); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), tt.want) {
+			t.Fatalf("user page for %s = %d, want %q:\n%s", tt.query, w.Code, tt.want, w.Body)
+		}
+	}
+
+	w = postForm(router, userPath+"/subscriptions", url.Values{
+		"service_name": {"<b>x</b>"}, "price": {"free"}, "start_date": {"13-2025"},
+	}, nil)
+	body = w.Body.String()
+	if w.Code != http.StatusBadRequest || !strings.Contains(body, "must be a whole number of rubles") ||
+		!strings.Contains(body, "must be a month in MM-YYYY format") || !strings.Contains(body, "&lt;b&gt;x&lt;/b&gt;") {
+		t.Fatalf("invalid create = %d, want the form shown again with errors:\n%s", w.Code, body)
+	}
+
+	subs, _ := service.ListByUser(t.Context(), uuid.MustParse(userID), 0, 10)
+	if len(subs) != 1 {
+		t.Fatalf("subscriptions = %d, want 1", len(subs))
+	}
+	subPath := "/ui/subscriptions/" + subs[0].ID.String()
+	if w := get(router, subPath+"/edit"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `value="1500"`) {
+		t.Fatalf("edit page = %d:\n%s", w.Code, w.Body)
+	}
+
+	w = postForm(router, subPath+"/end", url.Values{"end_date": {"06-2025"}, "version": {"1"}}, nil)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("end = %d:\n%s", w.Code, w.Body)
+	}
+	// The form was rendered before the subscription was ended.
+	w = postForm(router, subPath, url.Values{"price": {"1"}, "start_date": {"01-2025"}, "end_date": {""}, "version": {"1"}}, nil)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale update = %d, want 412", w.Code)
+	}
+	sub, _ := service.Get(t.Context(), subs[0].ID)
+	if sub.EndDate == nil || sub.PriceRUB != 1500 {
+		t.Fatalf("subscription = %+v, want it ended and unchanged otherwise", sub)
+	}
+}
+
+func TestUIRejectsCrossSiteForms(t *testing.T) {
+	router := newRouter(subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{Repository: subscription.NewMemoryRepository()}))
+	form := url.Values{"service_name": {"Netflix"}, "price": {"1"}, "start_date": {"01-2025"}}
+	path := "/ui/users/" + uuid.NewString() + "/subscriptions"
+
+	for _, header := range []http.Header{
+		{"Sec-Fetch-Site": {"cross-site"}},
+		{"Origin": {"https://evil.example"}},
+	} {
+		if w := postForm(router, path, form, header); w.Code != http.StatusForbidden {
+			t.Fatalf("form with %v = %d, want 403", header, w.Code)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package apiversion mounts versions of the API side by side on one
+// ServeMux: /v1/subscriptions and /v2/subscriptions can be served by
+// different handlers, and the routes from before versioning stay available
+// as deprecated aliases of one version.
+package apiversion
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Router is what API handlers register their routes on. *http.ServeMux and
+// *Version implement it, so a handler can be mounted unversioned in tests
+// and under a version prefix in the app.
+type Router interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// Deprecation describes routes that are going away. Responses from them
+// carry the Deprecation header of RFC 9745 and, if Sunset is set, the
+// Sunset header of RFC 8594.
+type Deprecation struct {
+	// Since is when the routes were deprecated.
+	Since time.Time
+	// Sunset is when they stop being served; zero if not yet decided.
+	Sunset time.Time
+	// Link is a page describing the migration, sent as
+	// Link: <...>; rel="deprecation".
+	Link string
+}
+
+// VersionConfig describes one version of the API.
+type VersionConfig struct {
+	// Name is the path prefix without slashes, e.g. "v1".
+	Name string
+	// Deprecation marks every route of the version as deprecated, once a
+	// newer version replaces it.
+	Deprecation *Deprecation
+	// LegacyAliases also serves every route without the version prefix,
+	// with these deprecation headers. At most one version may have it.
+	LegacyAliases *Deprecation
+}
+
+// Version registers routes under /Name on a ServeMux.
+type Version struct {
+	mux    *http.ServeMux
+	config VersionConfig
+	prefix string
+	// aliases maps the legacy alias patterns to the versioned ones.
+	aliases map[string]string
+}
+
+func Mount(mux *http.ServeMux, config VersionConfig) *Version {
+	return &Version{mux: mux, config: config, prefix: "/" + config.Name, aliases: make(map[string]string)}
+}
+
+// Prefix returns the path prefix of the version, e.g. "/v1".
+func (v *Version) Prefix() string {
+	return v.prefix
+}
+
+// Handle registers handler for pattern under the version prefix, and for
+// pattern itself if the version has legacy aliases. pattern is a ServeMux
+// pattern without a host, such as "GET /subscriptions/{sub_id}".
+func (v *Version) Handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	} else {
+		method += " "
+	}
+	if !strings.HasPrefix(path, "/") {
+		panic(fmt.Sprintf("apiversion: pattern %q must not have a host", pattern))
+	}
+
+	versioned := handler
+	if v.config.Deprecation != nil {
+		versioned = Deprecated(*v.config.Deprecation, "", handler)
+	}
+	v.mux.Handle(method+v.prefix+path, versioned)
+	if v.config.LegacyAliases != nil {
+		v.mux.Handle(pattern, Deprecated(*v.config.LegacyAliases, v.prefix, handler))
+		v.aliases[pattern] = method + v.prefix + path
+	}
+}
+
+// Canonical returns the versioned pattern for a legacy alias pattern, e.g.
+// "GET /v1/subscriptions" for "GET /subscriptions", and any other pattern
+// unchanged. Settings keyed by route, such as rate limits, use the
+// versioned pattern so that they cover the alias too.
+func (v *Version) Canonical(pattern string) string {
+	if versioned, ok := v.aliases[pattern]; ok {
+		return versioned
+	}
+	return pattern
+}
+
+func (v *Version) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	v.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// Deprecated adds the headers of d to the responses of next. A non-empty
+// successorPrefix names where the same route lives now; it is prepended to
+// the request path and sent as Link: <...>; rel="successor-version".
+func Deprecated(d Deprecation, successorPrefix string, next http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(d.Since.Unix(), 10)
+	sunset := ""
+	if !d.Sunset.IsZero() {
+		sunset = d.Sunset.UTC().Format(http.TimeFormat)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Deprecation", deprecation)
+		if sunset != "" {
+			h.Set("Sunset", sunset)
+		}
+		if d.Link != "" {
+			h.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+		}
+		if successorPrefix != "" {
+			successor := successorPrefix + r.URL.EscapedPath()
+			if r.URL.RawQuery != "" {
+				successor += "?" + r.URL.RawQuery
+			}
+			h.Add("Link", "<"+successor+`>; rel="successor-version"`)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
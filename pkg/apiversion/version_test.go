@@ -0,0 +1,104 @@
+package apiversion_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
+)
+
+func TestVersionsCoexist(t *testing.T) {
+	since := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+	router := http.NewServeMux()
+
+	v1 := apiversion.Mount(router, apiversion.VersionConfig{
+		Name:          "v1",
+		Deprecation:   &apiversion.Deprecation{Since: since.AddDate(0, 1, 0), Link: "https://example.com/v2"},
+		LegacyAliases: &apiversion.Deprecation{Since: since, Sunset: sunset},
+	})
+	v2 := apiversion.Mount(router, apiversion.VersionConfig{Name: "v2"})
+	for _, v := range []*apiversion.Version{v1, v2} {
+		name := v.Prefix()
+		v.HandleFunc("GET /subscriptions/{sub_id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + r.PathValue("sub_id")))
+		})
+	}
+
+	tests := []struct {
+		path        string
+		body        string
+		pattern     string
+		deprecation string
+		sunset      string
+		links       []string
+	}{
+		{
+			path:    "/v2/subscriptions/42",
+			body:    "/v2 42",
+			pattern: "GET /v2/subscriptions/{sub_id}",
+		},
+		{
+			path:        "/v1/subscriptions/42",
+			body:        "/v1 42",
+			pattern:     "GET /v1/subscriptions/{sub_id}",
+			deprecation: "@1795046400",
+			links:       []string{`<https://example.com/v2>; rel="deprecation"`},
+		},
+		{
+			path:        "/subscriptions/42?fields=id",
+			body:        "/v1 42",
+			pattern:     "GET /subscriptions/{sub_id}",
+			deprecation: "@1792368000",
+			sunset:      "Wed, 30 Jun 2027 00:00:00 GMT",
+			links:       []string{`</v1/subscriptions/42?fields=id>; rel="successor-version"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK || w.Body.String() != tt.body {
+				t.Fatalf("response = %d %q, want 200 %q", w.Code, w.Body.String(), tt.body)
+			}
+			if r.Pattern != tt.pattern {
+				t.Errorf("pattern = %q, want %q", r.Pattern, tt.pattern)
+			}
+			if got := w.Header().Get("Deprecation"); got != tt.deprecation {
+				t.Errorf("Deprecation = %q, want %q", got, tt.deprecation)
+			}
+			if got := w.Header().Get("Sunset"); got != tt.sunset {
+				t.Errorf("Sunset = %q, want %q", got, tt.sunset)
+			}
+			if got := w.Header().Values("Link"); len(got) != len(tt.links) || (len(got) > 0 && got[0] != tt.links[0]) {
+				t.Errorf("Link = %q, want %q", got, tt.links)
+			}
+		})
+	}
+
+	for pattern, want := range map[string]string{
+		"GET /subscriptions/{sub_id}":    "GET /v1/subscriptions/{sub_id}",
+		"GET /v1/subscriptions/{sub_id}": "GET /v1/subscriptions/{sub_id}",
+		"GET /healthz":                   "GET /healthz",
+	} {
+		if got := v1.Canonical(pattern); got != want {
+			t.Errorf("Canonical(%q) = %q, want %q", pattern, got, want)
+		}
+	}
+}
+
+func TestVersionWithoutLegacyAliases(t *testing.T) {
+	router := http.NewServeMux()
+	v1 := apiversion.Mount(router, apiversion.VersionConfig{Name: "v1"})
+	v1.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/subscriptions", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unversioned route status = %d, want 404", w.Code)
+	}
+}
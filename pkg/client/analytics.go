@@ -0,0 +1,46 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Trends returns the monthly spend in the range with the change from the
+// previous month.
+func (c *Client) Trends(ctx context.Context, filter MonthRange) (*TrendsResponse, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/analytics/trends", nil)
+	r.query = filter.query()
+	var out TrendsResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TopServices returns the services with the largest spend in the range.
+// Zero limit uses the server default.
+func (c *Client) TopServices(ctx context.Context, filter MonthRange, limit int) (*TopServicesResponse, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/analytics/top-services", nil)
+	r.query = filter.query()
+	if limit != 0 {
+		r.query.Set("limit", strconv.Itoa(limit))
+	}
+	var out TopServicesResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Churn returns how many subscriptions started and ended in every month of
+// the range.
+func (c *Client) Churn(ctx context.Context, filter MonthRange) (*ChurnResponse, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/analytics/churn", nil)
+	r.query = filter.query()
+	var out ChurnResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,288 @@
+// Package client is a Go client for the subscriptions HTTP API.
+//
+// Requests that fail with a 5xx status, 429, or a network error are retried
+// with exponential backoff, honouring Retry-After. POST requests carry an
+// Idempotency-Key that stays the same across retries, so a retried create
+// or batch is applied at most once. PATCH and DELETE are retried as well;
+// pass an If-Match value to make a retry of an already applied change fail
+// with precondition_failed instead of applying it twice.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryBackoff = 200 * time.Millisecond
+
+	// maxRetryWait caps the wait between two attempts, including waits
+	// requested with Retry-After.
+	maxRetryWait = 30 * time.Second
+)
+
+// apiPrefix is the API version the client speaks.
+const apiPrefix = "/v1"
+
+const (
+	headerAPIKey         = "X-API-Key"
+	headerIdempotencyKey = "Idempotency-Key"
+	headerIfMatch        = "If-Match"
+)
+
+type Options struct {
+	// HTTPClient sends the requests. Defaults to a client with
+	// DefaultTimeout.
+	HTTPClient *http.Client
+	// APIKey is sent as X-API-Key, which the server rate limits by.
+	APIKey string
+	// MaxRetries is how many times a failed request is retried. Zero means
+	// DefaultMaxRetries; a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the wait before the first retry; it doubles with
+	// every further attempt. Zero means DefaultRetryBackoff.
+	RetryBackoff time.Duration
+}
+
+// Client calls the API at a base URL. It is safe for concurrent use.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	apiKey       string
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+func New(baseURL string, opts Options) *Client {
+	c := &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   opts.HTTPClient,
+		apiKey:       opts.APIKey,
+		maxRetries:   opts.MaxRetries,
+		retryBackoff: opts.RetryBackoff,
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: DefaultTimeout}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = DefaultMaxRetries
+	}
+	if c.maxRetries < 0 {
+		c.maxRetries = 0
+	}
+	if c.retryBackoff <= 0 {
+		c.retryBackoff = DefaultRetryBackoff
+	}
+	return c
+}
+
+// Error is a non-2xx response of the API. The embedded ErrorResponse holds
+// the problem details; its Code is empty when the response was not a
+// problem document, e.g. an error page of a proxy.
+type Error struct {
+	StatusCode int
+	ErrorResponse
+}
+
+func (e *Error) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = e.Code
+	}
+	for i, v := range e.Violations {
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += sep + v.Field + " " + v.Message
+	}
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), msg)
+}
+
+// ErrorCode returns the problem code of an *Error in err's chain, or "" if
+// there is none.
+func ErrorCode(err error) string {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// ETag formats a subscription version as the If-Match value that expects
+// it.
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// RequestOption adjusts a single request.
+type RequestOption func(*request)
+
+// WithIdempotencyKey sets the Idempotency-Key of a create or batch request
+// instead of a generated one, so that the caller can repeat the call later
+// without applying it twice.
+func WithIdempotencyKey(key string) RequestOption {
+	return func(r *request) { r.headers.Set(headerIdempotencyKey, key) }
+}
+
+type request struct {
+	method  string
+	path    string
+	query   url.Values
+	headers http.Header
+	body    any
+	// batch accepts a non-2xx JSON response as the result: a rolled back
+	// batch is reported with the failing operation's status.
+	batch bool
+	// noRetry sends the request once, for probes whose failure is the
+	// answer.
+	noRetry bool
+}
+
+func newRequest(method, path string, opts []RequestOption) *request {
+	r := &request{method: method, path: path, headers: http.Header{}}
+	if method == http.MethodPost {
+		r.headers.Set(headerIdempotencyKey, uuid.NewString())
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// do sends r, retrying as described in the package doc, and decodes the
+// response body into out.
+func (c *Client) do(ctx context.Context, r *request, out any) error {
+	u := c.baseURL + r.path
+	if len(r.query) > 0 {
+		u += "?" + r.query.Encode()
+	}
+	var body []byte
+	if r.body != nil {
+		var err error
+		if body, err = json.Marshal(r.body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, r.method, u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		if r.body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		req.Header.Set("Accept", "application/json")
+		if c.apiKey != "" {
+			req.Header.Set(headerAPIKey, c.apiKey)
+		}
+		for k, v := range r.headers {
+			if v[0] != "" {
+				req.Header[k] = v
+			}
+		}
+
+		resp, err := c.httpClient.Do(req)
+		retry := !r.noRetry && attempt < c.maxRetries
+		if err != nil {
+			if !retry || ctx.Err() != nil {
+				return err
+			}
+			if err := sleep(ctx, c.backoff(attempt)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = decodeResponse(resp, r.batch, out)
+		resp.Body.Close()
+		if !retry || !retryable(err) {
+			return err
+		}
+		wait := c.backoff(attempt)
+		if after, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = after
+		}
+		if err := sleep(ctx, min(wait, maxRetryWait)); err != nil {
+			return err
+		}
+	}
+}
+
+func decodeResponse(resp *http.Response, batch bool, out any) error {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode >= 300 && !(batch && mediaType == "application/json") {
+		apiErr := &Error{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(&apiErr.ErrorResponse)
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %d response: %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// retryable reports whether err is a response worth repeating the request
+// for: a server failure, a rate limit, or an idempotent request whose first
+// attempt is still being processed.
+func retryable(err error) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return apiErr.Code == ProblemRequestInProgress
+}
+
+// backoff returns the wait before retry attempt+1: RetryBackoff doubled per
+// attempt, with jitter so that clients failing together don't retry
+// together.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := maxRetryWait
+	if attempt < 16 {
+		d = min(c.retryBackoff<<attempt, maxRetryWait)
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter reads the delay in seconds from a Retry-After header.
+func retryAfter(header string) (time.Duration, bool) {
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,458 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/analytics"
+	"github.com/SenechkaP/subs-tracker/internal/events"
+	"github.com/SenechkaP/subs-tracker/internal/idempotency"
+	"github.com/SenechkaP/subs-tracker/internal/migrations"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/internal/subscription"
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
+	"github.com/SenechkaP/subs-tracker/pkg/client"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+	"github.com/google/uuid"
+)
+
+const userID = "8a7f9f6e-3f2b-4c2a-9d5b-1a2b3c4d5e6f"
+
+// faultInjector fails the first failures requests with status, and records
+// the Idempotency-Key of every request it sees.
+type faultInjector struct {
+	next     http.Handler
+	mu       sync.Mutex
+	failures int
+	status   int
+	keys     []string
+}
+
+func (f *faultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.keys = append(f.keys, r.Header.Get(idempotency.HeaderIdempotencyKey))
+	fail := f.failures > 0
+	if fail {
+		f.failures--
+	}
+	f.mu.Unlock()
+	if fail {
+		res.WriteProblem(w, r, f.status, res.ProblemInternal, "INJECTED FAILURE")
+		return
+	}
+	f.next.ServeHTTP(w, r)
+}
+
+func (f *faultInjector) fail(n, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures, f.status, f.keys = n, status, nil
+}
+
+func (f *faultInjector) seenKeys() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.keys...)
+}
+
+// newTestServer runs the real subscription handler on an in-memory store,
+// with idempotency keys kept in an in-memory SQLite database.
+func newTestServer(t *testing.T) (*client.Client, *faultInjector) {
+	t.Helper()
+	gormDB, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	if err := migrations.AutoMigrate(gormDB); err != nil {
+		t.Fatalf("migrate sqlite: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := gormDB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	router := http.NewServeMux()
+	v1 := apiversion.Mount(router, apiversion.VersionConfig{Name: "v1"})
+	bus := events.NewBus(100)
+	subscription.NewSubscriptionHandler(v1, &subscription.SubscriptionHandlerDeps{
+		Service: subscription.NewSubscriptionService(&subscription.SubscriptionServiceDeps{
+			Repository: subscription.NewMemoryRepository(),
+			Events:     bus,
+		}),
+		Idempotency: idempotency.NewGuard(idempotency.NewIdempotencyRepository(gormDB), time.Hour),
+	})
+	events.NewEventsHandler(v1, &events.EventsHandlerDeps{Bus: bus, Heartbeat: time.Minute})
+	faults := &faultInjector{next: router}
+	server := httptest.NewServer(faults)
+	t.Cleanup(server.Close)
+	t.Cleanup(bus.Close)
+
+	return client.New(server.URL, client.Options{RetryBackoff: time.Millisecond}), faults
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func createNetflix(t *testing.T, c *client.Client, opts ...client.RequestOption) string {
+	t.Helper()
+	created, err := c.CreateSubscription(context.Background(), &client.SubscriptionCreateRequest{
+		Service:   "Netflix",
+		PriceRUB:  499,
+		UserID:    userID,
+		StartDate: "01-2025",
+	}, opts...)
+	if err != nil {
+		t.Fatalf("CreateSubscription: %v", err)
+	}
+	return created.SubID
+}
+
+func TestClientLifecycle(t *testing.T) {
+	c, _ := newTestServer(t)
+	ctx := context.Background()
+
+	id := createNetflix(t, c)
+	sub, err := c.GetSubscription(ctx, id)
+	if err != nil {
+		t.Fatalf("GetSubscription: %v", err)
+	}
+	if sub.Service != "Netflix" || sub.PriceRUB != 499 || sub.Version != 1 {
+		t.Fatalf("GetSubscription = %+v", sub)
+	}
+
+	patched, err := c.PatchSubscription(ctx, id, &client.SubscriptionPatchRequest{PriceRUB: int64Ptr(599)}, client.ETag(sub.Version))
+	if err != nil {
+		t.Fatalf("PatchSubscription: %v", err)
+	}
+	if patched.PriceRUB != 599 || patched.Version != 2 {
+		t.Fatalf("PatchSubscription = %+v", patched)
+	}
+	_, err = c.PatchSubscription(ctx, id, &client.SubscriptionPatchRequest{EndDate: strPtr("06-2025")}, client.ETag(sub.Version))
+	if code := client.ErrorCode(err); code != client.ProblemPreconditionFailed {
+		t.Fatalf("stale PatchSubscription code = %q (err %v), want %q", code, err, client.ProblemPreconditionFailed)
+	}
+
+	subs, err := c.ListUserSubscriptions(ctx, userID, 0, 10)
+	if err != nil || len(subs) != 1 || subs[0].ID.String() != id {
+		t.Fatalf("ListUserSubscriptions = %+v, %v", subs, err)
+	}
+	sum, err := c.SumSubscriptions(ctx, client.MonthRange{Start: "01-2025", End: "12-2025", UserID: userID})
+	if err != nil || sum != 599 {
+		t.Fatalf("SumSubscriptions = %d, %v, want 599", sum, err)
+	}
+	duplicates, err := c.FindUserDuplicates(ctx, userID)
+	if err != nil || len(duplicates.Duplicates) != 0 {
+		t.Fatalf("FindUserDuplicates = %+v, %v", duplicates, err)
+	}
+
+	if err := c.DeleteSubscription(ctx, id, ""); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+	_, err = c.GetSubscription(ctx, id)
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != client.ProblemSubscriptionNotFound {
+		t.Fatalf("GetSubscription after delete err = %v", err)
+	}
+}
+
+func TestClientReportsViolations(t *testing.T) {
+	c, faults := newTestServer(t)
+
+	_, err := c.CreateSubscription(context.Background(), &client.SubscriptionCreateRequest{
+		PriceRUB:  -1,
+		UserID:    userID,
+		StartDate: "13-2025",
+	})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("err = %v, want *client.Error", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Code != client.ProblemValidationFailed {
+		t.Errorf("err = %d %s, want 400 %s", apiErr.StatusCode, apiErr.Code, client.ProblemValidationFailed)
+	}
+	fields := map[string]bool{}
+	for _, v := range apiErr.Violations {
+		fields[v.Field] = true
+	}
+	for _, field := range []string{"service_name", "price", "start_date"} {
+		if !fields[field] {
+			t.Errorf("no violation for %s in %+v", field, apiErr.Violations)
+		}
+	}
+	if n := len(faults.seenKeys()); n != 1 {
+		t.Errorf("client error was sent %d times, want once", n)
+	}
+}
+
+func TestClientRetriesWithSameIdempotencyKey(t *testing.T) {
+	c, faults := newTestServer(t)
+
+	faults.fail(2, http.StatusServiceUnavailable)
+	id := createNetflix(t, c)
+
+	keys := faults.seenKeys()
+	if len(keys) != 3 {
+		t.Fatalf("sent %d attempts, want 3", len(keys))
+	}
+	if _, err := uuid.Parse(keys[0]); err != nil {
+		t.Fatalf("Idempotency-Key %q is not generated", keys[0])
+	}
+	for _, key := range keys[1:] {
+		if key != keys[0] {
+			t.Fatalf("retries used keys %v, want the same key", keys)
+		}
+	}
+	subs, err := c.ListUserSubscriptions(context.Background(), userID, 0, 10)
+	if err != nil || len(subs) != 1 || subs[0].ID.String() != id {
+		t.Fatalf("ListUserSubscriptions = %+v, %v, want only %s", subs, err, id)
+	}
+}
+
+func TestClientGivesUp(t *testing.T) {
+	c, faults := newTestServer(t)
+
+	faults.fail(100, http.StatusInternalServerError)
+	_, err := c.GetSubscription(context.Background(), uuid.NewString())
+	if code := client.ErrorCode(err); code != client.ProblemInternal {
+		t.Fatalf("code = %q (err %v), want %q", code, err, client.ProblemInternal)
+	}
+	if n := len(faults.seenKeys()); n != client.DefaultMaxRetries+1 {
+		t.Fatalf("sent %d attempts, want %d", n, client.DefaultMaxRetries+1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.GetSubscription(ctx, uuid.NewString()); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err with canceled context = %v", err)
+	}
+}
+
+func TestClientIdempotencyKeyReplays(t *testing.T) {
+	c, _ := newTestServer(t)
+
+	key := uuid.NewString()
+	first := createNetflix(t, c, client.WithIdempotencyKey(key))
+	second := createNetflix(t, c, client.WithIdempotencyKey(key))
+	if first != second {
+		t.Fatalf("repeated create returned %s and %s", first, second)
+	}
+	if third := createNetflix(t, c); third == first {
+		t.Fatal("create without a key was replayed")
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	c, _ := newTestServer(t)
+	ctx := context.Background()
+
+	create, _ := json.Marshal(client.SubscriptionCreateRequest{Service: "Spotify", PriceRUB: 199, UserID: userID, StartDate: "02-2025"})
+	out, err := c.BatchSubscriptions(ctx, []client.BatchOperation{
+		{Op: client.BatchOpCreate, Data: create},
+		{Op: client.BatchOpDelete, ID: uuid.NewString()},
+		{Op: client.BatchOpCreate, Data: create},
+	})
+	if err != nil {
+		t.Fatalf("BatchSubscriptions: %v", err)
+	}
+	if out.Committed || len(out.Results) != 3 || out.Results[1].Code != client.ProblemSubscriptionNotFound ||
+		out.Results[2].Code != client.ProblemNotRun {
+		t.Fatalf("rolled back batch = %+v", out)
+	}
+
+	out, err = c.BatchSubscriptions(ctx, []client.BatchOperation{{Op: client.BatchOpCreate, Data: create}})
+	if err != nil || !out.Committed || out.Results[0].Subscription == nil {
+		t.Fatalf("BatchSubscriptions = %+v, %v", out, err)
+	}
+
+	_, err = c.BatchSubscriptions(ctx, nil)
+	if code := client.ErrorCode(err); code != client.ProblemEmptyBatch {
+		t.Fatalf("empty batch code = %q (err %v), want %q", code, err, client.ProblemEmptyBatch)
+	}
+}
+
+func TestClientAnalyticsQuery(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.URL.Path+"?"+r.URL.RawQuery)
+		res.JsonDump(w, map[string]any{}, http.StatusOK)
+	}))
+	defer server.Close()
+	c := client.New(server.URL, client.Options{})
+	ctx := context.Background()
+
+	filter := client.MonthRange{Start: "01-2025", End: "03-2025", Service: "Netflix"}
+	if _, err := c.Trends(ctx, filter); err != nil {
+		t.Fatalf("Trends: %v", err)
+	}
+	if _, err := c.TopServices(ctx, filter, 5); err != nil {
+		t.Fatalf("TopServices: %v", err)
+	}
+	if _, err := c.Churn(ctx, client.MonthRange{Start: "01-2025", End: "03-2025"}); err != nil {
+		t.Fatalf("Churn: %v", err)
+	}
+
+	want := []string{
+		"/v1/analytics/trends?end=03-2025&service=Netflix&start=01-2025",
+		"/v1/analytics/top-services?end=03-2025&limit=5&service=Netflix&start=01-2025",
+		"/v1/analytics/churn?end=03-2025&start=01-2025",
+	}
+	for i := range want {
+		if i >= len(got) || got[i] != want[i] {
+			t.Fatalf("requests = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestClientStreamEvents(t *testing.T) {
+	c, _ := newTestServer(t)
+	ctx := context.Background()
+
+	stream, err := c.StreamEvents(ctx, userID, "")
+	if err != nil {
+		t.Fatalf("StreamEvents: %v", err)
+	}
+	id := createNetflix(t, c)
+	if err := c.DeleteSubscription(ctx, id, ""); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+
+	created, err := stream.Next()
+	if err != nil || created.Type != client.EventCreated || created.Subscription == nil || created.Subscription.ID.String() != id {
+		t.Fatalf("Next = %+v, %v, want the create", created, err)
+	}
+	resumeFrom := stream.LastEventID()
+	if created.ID == 0 || resumeFrom != strconv.FormatUint(created.ID, 10) {
+		t.Fatalf("event ID = %d, LastEventID = %q", created.ID, resumeFrom)
+	}
+	stream.Close()
+
+	resumed, err := c.StreamEvents(ctx, userID, resumeFrom)
+	if err != nil {
+		t.Fatalf("StreamEvents resuming: %v", err)
+	}
+	defer resumed.Close()
+	if deleted, err := resumed.Next(); err != nil || deleted.Type != client.EventDeleted || deleted.SubscriptionID.String() != id {
+		t.Fatalf("Next after resuming = %+v, %v, want the delete", deleted, err)
+	}
+
+	if _, err := c.StreamEvents(ctx, "nope", ""); client.ErrorCode(err) != client.ProblemInvalidUserID {
+		t.Fatalf("StreamEvents with an invalid user: err = %v", err)
+	}
+}
+
+func TestClientChanges(t *testing.T) {
+	c, _ := newTestServer(t)
+	ctx := context.Background()
+	ids := []string{createNetflix(t, c), createNetflix(t, c)}
+	if err := c.DeleteSubscription(ctx, ids[0], ""); err != nil {
+		t.Fatalf("DeleteSubscription: %v", err)
+	}
+
+	var got []string
+	since := ""
+	for {
+		page, err := c.Changes(ctx, since, 2)
+		if err != nil {
+			t.Fatalf("Changes: %v", err)
+		}
+		for _, change := range page.Changes {
+			got = append(got, change.Op+" "+change.SubscriptionID)
+		}
+		since = page.NextCursor
+		if !page.HasMore {
+			break
+		}
+	}
+	want := []string{client.ChangeCreated + " " + ids[0], client.ChangeCreated + " " + ids[1], client.ChangeDeleted + " " + ids[0]}
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("changes = %v, want %v", got, want)
+	}
+
+	if _, err := c.Changes(ctx, "nope", 0); client.ErrorCode(err) != client.ProblemInvalidCursor {
+		t.Fatalf("Changes with a bad cursor: err = %v", err)
+	}
+}
+
+// The client is imported by other modules, so it must not drag in the
+// server's packages, its database drivers or its metrics.
+func TestClientDependencies(t *testing.T) {
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	out, err := exec.Command(gobin, "list", "-deps", ".").Output()
+	if err != nil {
+		t.Fatalf("go list: %v", err)
+	}
+	for _, pkg := range strings.Fields(string(out)) {
+		if strings.Contains(pkg, "/internal/") && strings.HasPrefix(pkg, "github.com/SenechkaP/subs-tracker/") ||
+			strings.Contains(pkg, "gorm") || strings.Contains(pkg, "pgx") || strings.Contains(pkg, "sqlite") {
+			t.Errorf("pkg/client depends on %s", pkg)
+		}
+	}
+}
+
+// The constants are copies of the server's and have to stay in step.
+func TestClientConstants(t *testing.T) {
+	tests := map[string][2]string{
+		"BatchOpCreate":                {client.BatchOpCreate, subscription.BatchOpCreate},
+		"BatchOpPatch":                 {client.BatchOpPatch, subscription.BatchOpPatch},
+		"BatchOpDelete":                {client.BatchOpDelete, subscription.BatchOpDelete},
+		"EventCreated":                 {client.EventCreated, events.TypeCreated},
+		"EventUpdated":                 {client.EventUpdated, events.TypeUpdated},
+		"EventDeleted":                 {client.EventDeleted, events.TypeDeleted},
+		"EventReset":                   {client.EventReset, events.TypeReset},
+		"ChangeCreated":                {client.ChangeCreated, models.ChangeCreated},
+		"ChangeUpdated":                {client.ChangeUpdated, models.ChangeUpdated},
+		"ChangeDeleted":                {client.ChangeDeleted, models.ChangeDeleted},
+		"ProblemValidationFailed":      {client.ProblemValidationFailed, subscription.ProblemValidationFailed},
+		"ProblemMalformedBody":         {client.ProblemMalformedBody, subscription.ProblemMalformedBody},
+		"ProblemEmptyBody":             {client.ProblemEmptyBody, subscription.ProblemEmptyBody},
+		"ProblemBodyTooLarge":          {client.ProblemBodyTooLarge, res.ProblemBodyTooLarge},
+		"ProblemReadBody":              {client.ProblemReadBody, idempotency.ProblemReadBody},
+		"ProblemInvalidSubscriptionID": {client.ProblemInvalidSubscriptionID, subscription.ProblemInvalidSubscriptionID},
+		"ProblemInvalidUserID":         {client.ProblemInvalidUserID, res.ProblemInvalidUserID},
+		"ProblemInvalidStartDate":      {client.ProblemInvalidStartDate, res.ProblemInvalidStartDate},
+		"ProblemInvalidEndDate":        {client.ProblemInvalidEndDate, res.ProblemInvalidEndDate},
+		"ProblemInvalidDateRange":      {client.ProblemInvalidDateRange, res.ProblemInvalidDateRange},
+		"ProblemRangeTooLong":          {client.ProblemRangeTooLong, analytics.ProblemRangeTooLong},
+		"ProblemMissingParameter":      {client.ProblemMissingParameter, res.ProblemMissingParameter},
+		"ProblemInvalidParameter":      {client.ProblemInvalidParameter, res.ProblemInvalidParameter},
+		"ProblemInvalidCursor":         {client.ProblemInvalidCursor, subscription.ProblemInvalidCursor},
+		"ProblemEmptyBatch":            {client.ProblemEmptyBatch, subscription.ProblemEmptyBatch},
+		"ProblemBatchTooLarge":         {client.ProblemBatchTooLarge, subscription.ProblemBatchTooLarge},
+		"ProblemInvalidBatchOperation": {client.ProblemInvalidBatchOperation, subscription.ProblemInvalidBatchOperation},
+		"ProblemNotRun":                {client.ProblemNotRun, subscription.ProblemNotRun},
+		"ProblemSubscriptionNotFound":  {client.ProblemSubscriptionNotFound, subscription.ProblemSubscriptionNotFound},
+		"ProblemPreconditionFailed":    {client.ProblemPreconditionFailed, subscription.ProblemPreconditionFailed},
+		"ProblemIfMatchRequired":       {client.ProblemIfMatchRequired, subscription.ProblemIfMatchRequired},
+		"ProblemInvalidIdempotencyKey": {client.ProblemInvalidIdempotencyKey, idempotency.ProblemInvalidIdempotencyKey},
+		"ProblemKeyReused":             {client.ProblemKeyReused, idempotency.ProblemKeyReused},
+		"ProblemRequestInProgress":     {client.ProblemRequestInProgress, idempotency.ProblemRequestInProgress},
+		"ProblemTooManyRequests":       {client.ProblemTooManyRequests, middleware.ProblemTooManyRequests},
+		"ProblemInternal":              {client.ProblemInternal, res.ProblemInternal},
+	}
+	for name, tt := range tests {
+		if tt[0] != tt[1] {
+			t.Errorf("client.%s = %q, the server uses %q", name, tt[0], tt[1])
+		}
+	}
+}
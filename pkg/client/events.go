@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// EventStream reads the events of GET /events. It is not safe for
+// concurrent use.
+type EventStream struct {
+	body        io.ReadCloser
+	scanner     *bufio.Scanner
+	lastEventID string
+}
+
+// StreamEvents opens the stream of subscription changes, only those of
+// userID unless it is empty. lastEventID resumes after an event received
+// earlier, e.g. from EventStream.LastEventID of a broken stream; the stream
+// then starts with an EventReset if the missed events are gone. The stream
+// ends with ctx or Close and is not reopened by the client.
+func (c *Client) StreamEvents(ctx context.Context, userID, lastEventID string) (*EventStream, error) {
+	u := c.baseURL + apiPrefix + "/events"
+	if userID != "" {
+		u += "?" + url.Values{"user_id": {userID}}.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.apiKey != "" {
+		req.Header.Set(headerAPIKey, c.apiKey)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	// The stream is open for as long as the caller reads it, so the
+	// client's overall timeout must not apply.
+	httpClient := *c.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeResponse(resp, false, nil); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &EventStream{body: resp.Body, scanner: bufio.NewScanner(resp.Body), lastEventID: lastEventID}, nil
+}
+
+// Next blocks until the next event arrives. It returns io.EOF when the
+// server ends the stream.
+func (s *EventStream) Next() (*Event, error) {
+	var id, typ string
+	var data []string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if typ == "" && data == nil {
+				continue
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &e); err != nil {
+				return nil, fmt.Errorf("decode %s event: %w", typ, err)
+			}
+			if id != "" {
+				s.lastEventID = id
+				e.ID, _ = strconv.ParseUint(id, 10, 64)
+			}
+			return &e, nil
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			typ = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// LastEventID is the id of the last event read, to resume from with
+// StreamEvents.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Liveness calls /healthz once, without retries.
+func (c *Client) Liveness(ctx context.Context) (*HealthResponse, error) {
+	return c.health(ctx, "/healthz")
+}
+
+// Readiness calls /readyz once, without retries. A failed check is an
+// *Error with status 503.
+func (c *Client) Readiness(ctx context.Context) (*HealthResponse, error) {
+	return c.health(ctx, "/readyz")
+}
+
+func (c *Client) health(ctx context.Context, path string) (*HealthResponse, error) {
+	r := newRequest(http.MethodGet, path, nil)
+	r.noRetry = true
+	var out HealthResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// MonthRange selects the months from Start to End, both formatted as
+// MM-YYYY, optionally narrowed to one user and one service.
+type MonthRange struct {
+	Start   string
+	End     string
+	UserID  string
+	Service string
+}
+
+func (m MonthRange) query() url.Values {
+	query := url.Values{}
+	query.Set("start", m.Start)
+	query.Set("end", m.End)
+	if m.UserID != "" {
+		query.Set("user_id", m.UserID)
+	}
+	if m.Service != "" {
+		query.Set("service", m.Service)
+	}
+	return query
+}
+
+func subscriptionPath(id string) string {
+	return apiPrefix + "/subscriptions/" + url.PathEscape(id)
+}
+
+func (c *Client) GetSubscription(ctx context.Context, id string) (*Subscription, error) {
+	var out Subscription
+	if err := c.do(ctx, newRequest(http.MethodGet, subscriptionPath(id), nil), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (c *Client) CreateSubscription(ctx context.Context, body *SubscriptionCreateRequest, opts ...RequestOption) (*SubscriptionCreateResponse, error) {
+	r := newRequest(http.MethodPost, apiPrefix+"/subscriptions", opts)
+	r.body = body
+	var out SubscriptionCreateResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PatchSubscription changes the fields set in body. ifMatch, e.g. from
+// ETag, makes the change conditional; empty sends no If-Match.
+func (c *Client) PatchSubscription(ctx context.Context, id string, body *SubscriptionPatchRequest, ifMatch string) (*Subscription, error) {
+	r := newRequest(http.MethodPatch, subscriptionPath(id), nil)
+	r.body = body
+	r.headers.Set(headerIfMatch, ifMatch)
+	var out Subscription
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSubscription removes the subscription; ifMatch is handled as in
+// PatchSubscription.
+func (c *Client) DeleteSubscription(ctx context.Context, id string, ifMatch string) error {
+	r := newRequest(http.MethodDelete, subscriptionPath(id), nil)
+	r.headers.Set(headerIfMatch, ifMatch)
+	return c.do(ctx, r, nil)
+}
+
+func (c *Client) ListUserSubscriptions(ctx context.Context, userID string, offset, limit int) ([]Subscription, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/users/"+url.PathEscape(userID)+"/subscriptions", nil)
+	r.query = url.Values{}
+	r.query.Set("offset", strconv.Itoa(offset))
+	r.query.Set("limit", strconv.Itoa(limit))
+	var out []Subscription
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) FindUserDuplicates(ctx context.Context, userID string) (*DuplicateSubscriptionsResponse, error) {
+	var out DuplicateSubscriptionsResponse
+	if err := c.do(ctx, newRequest(http.MethodGet, apiPrefix+"/users/"+url.PathEscape(userID)+"/duplicates", nil), &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SumSubscriptions returns the total price of the subscriptions in the
+// range.
+func (c *Client) SumSubscriptions(ctx context.Context, filter MonthRange) (int64, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/subscriptions/sum", nil)
+	r.query = filter.query()
+	var out SubscriptionsPriceSumResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return 0, err
+	}
+	return out.PriceSum, nil
+}
+
+// BatchSubscriptions runs ops in one transaction. A batch rolled back
+// because of a failing operation is not an error: the response has
+// Committed false and the failure in that operation's result.
+func (c *Client) BatchSubscriptions(ctx context.Context, ops []BatchOperation, opts ...RequestOption) (*BatchResponse, error) {
+	r := newRequest(http.MethodPost, apiPrefix+"/subscriptions/batch", opts)
+	r.body = BatchRequest{Operations: ops}
+	r.batch = true
+	var out BatchResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Changes returns up to limit changes after the cursor since, "" for the
+// start of the change log; limit 0 means the server default. Pass the
+// response's NextCursor as since to continue.
+func (c *Client) Changes(ctx context.Context, since string, limit int) (*ChangesResponse, error) {
+	r := newRequest(http.MethodGet, apiPrefix+"/changes", nil)
+	r.query = url.Values{}
+	if since != "" {
+		r.query.Set("since", since)
+	}
+	if limit != 0 {
+		r.query.Set("limit", strconv.Itoa(limit))
+	}
+	var out ChangesResponse
+	if err := c.do(ctx, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,229 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// The request and response bodies mirror the server's wire format. They are
+// declared here rather than taken from the server packages so that the
+// client doesn't pull in the database drivers and the server's metrics.
+
+type Subscription struct {
+	ID        uuid.UUID  `json:"id"`
+	Service   string     `json:"service_name"`
+	PriceRUB  int64      `json:"price"`
+	UserID    uuid.UUID  `json:"user_id"`
+	StartDate time.Time  `json:"start_date"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	Version   int64      `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type SubscriptionCreateRequest struct {
+	Service   string  `json:"service_name"`
+	PriceRUB  int64   `json:"price"`
+	UserID    string  `json:"user_id"`
+	StartDate string  `json:"start_date"`
+	EndDate   *string `json:"end_date,omitempty"`
+}
+
+type SubscriptionPatchRequest struct {
+	PriceRUB  *int64  `json:"price,omitempty"`
+	StartDate *string `json:"start_date,omitempty"`
+	EndDate   *string `json:"end_date,omitempty"`
+}
+
+type SubscriptionCreateResponse struct {
+	SubID        string   `json:"subscription_id"`
+	Warning      string   `json:"warning,omitempty"`
+	OverlapsWith []string `json:"overlaps_with,omitempty"`
+}
+
+type SubscriptionsPriceSumResponse struct {
+	PriceSum int64 `json:"total_sum"`
+}
+
+const (
+	BatchOpCreate = "create"
+	BatchOpPatch  = "patch"
+	BatchOpDelete = "delete"
+)
+
+type BatchOperation struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	IfMatch string          `json:"if_match,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations"`
+}
+
+type BatchOperationResult struct {
+	Index        int              `json:"index"`
+	Op           string           `json:"op"`
+	Status       int              `json:"status"`
+	ID           string           `json:"id,omitempty"`
+	Subscription *Subscription    `json:"subscription,omitempty"`
+	Code         string           `json:"code,omitempty"`
+	Error        string           `json:"error,omitempty"`
+	Violations   []FieldViolation `json:"violations,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool                   `json:"committed"`
+	Results   []BatchOperationResult `json:"results"`
+}
+
+type DuplicatePair struct {
+	Service       string         `json:"service_name"`
+	OverlapStart  string         `json:"overlap_start"`
+	OverlapEnd    *string        `json:"overlap_end"`
+	Subscriptions []Subscription `json:"subscriptions"`
+}
+
+type DuplicateSubscriptionsResponse struct {
+	Duplicates []DuplicatePair `json:"duplicates"`
+}
+
+// Change is an entry of GET /changes. Subscription is the state after the
+// change and is absent for deletes.
+type Change struct {
+	Cursor         string        `json:"cursor"`
+	Op             string        `json:"op"`
+	SubscriptionID string        `json:"subscription_id"`
+	UserID         string        `json:"user_id"`
+	Version        int64         `json:"version"`
+	ChangedAt      time.Time     `json:"changed_at"`
+	Subscription   *Subscription `json:"subscription,omitempty"`
+}
+
+type ChangesResponse struct {
+	Changes    []Change `json:"changes"`
+	NextCursor string   `json:"next_cursor"`
+	HasMore    bool     `json:"has_more"`
+}
+
+// Problem is the RFC 9457 problem+json body of a failed request.
+type Problem struct {
+	Type      string `json:"type"`
+	Code      string `json:"code"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorResponse is the body of every failed request. Violations is set for
+// ProblemValidationFailed.
+type ErrorResponse struct {
+	Problem
+	Violations []FieldViolation `json:"violations,omitempty"`
+}
+
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type TrendPoint struct {
+	Month         string   `json:"month"`
+	Total         int64    `json:"total"`
+	Delta         *int64   `json:"delta"`
+	PercentChange *float64 `json:"percent_change"`
+}
+
+type TrendsResponse struct {
+	Months []TrendPoint `json:"months"`
+}
+
+type TopService struct {
+	Service       string `json:"service_name"`
+	Total         int64  `json:"total"`
+	Subscriptions int64  `json:"subscriptions"`
+	Users         int64  `json:"users"`
+}
+
+type TopServicesResponse struct {
+	Services []TopService `json:"services"`
+}
+
+type ChurnPoint struct {
+	Month   string `json:"month"`
+	Started int64  `json:"started"`
+	Ended   int64  `json:"ended"`
+	Net     int64  `json:"net"`
+}
+
+type ChurnResponse struct {
+	Months []ChurnPoint `json:"months"`
+}
+
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Event is a change received from EventStream. ID is set from the stream's
+// event id. Subscription is the state after the change and is absent for
+// EventDeleted.
+type Event struct {
+	ID             uint64        `json:"-"`
+	Type           string        `json:"type"`
+	SubscriptionID uuid.UUID     `json:"subscription_id"`
+	UserID         uuid.UUID     `json:"user_id"`
+	Subscription   *Subscription `json:"subscription,omitempty"`
+	Time           time.Time     `json:"time"`
+}
+
+// Event types.
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+	EventReset   = "reset"
+)
+
+// Change operations.
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Problem codes reported in Error.Code and BatchOperationResult.Code.
+const (
+	ProblemValidationFailed      = "validation_failed"
+	ProblemMalformedBody         = "malformed_body"
+	ProblemEmptyBody             = "empty_body"
+	ProblemBodyTooLarge          = "body_too_large"
+	ProblemReadBody              = "unreadable_body"
+	ProblemInvalidSubscriptionID = "invalid_subscription_id"
+	ProblemInvalidUserID         = "invalid_user_id"
+	ProblemInvalidStartDate      = "invalid_start_date"
+	ProblemInvalidEndDate        = "invalid_end_date"
+	ProblemInvalidDateRange      = "invalid_date_range"
+	ProblemRangeTooLong          = "date_range_too_long"
+	ProblemMissingParameter      = "missing_parameter"
+	ProblemInvalidParameter      = "invalid_parameter"
+	ProblemInvalidCursor         = "invalid_cursor"
+	ProblemEmptyBatch            = "empty_batch"
+	ProblemBatchTooLarge         = "batch_too_large"
+	ProblemInvalidBatchOperation = "invalid_batch_operation"
+	ProblemNotRun                = "not_run"
+	ProblemSubscriptionNotFound  = "subscription_not_found"
+	ProblemPreconditionFailed    = "precondition_failed"
+	ProblemIfMatchRequired       = "if_match_required"
+	ProblemInvalidIdempotencyKey = "invalid_idempotency_key"
+	ProblemKeyReused             = "idempotency_key_reused"
+	ProblemRequestInProgress     = "request_in_progress"
+	ProblemTooManyRequests       = "rate_limited"
+	ProblemInternal              = "internal_error"
+)
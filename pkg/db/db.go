@@ -2,23 +2,181 @@ package db
 
 import (
 	"fmt"
-	"log"
+	"strings"
+	"time"
 
 	"github.com/SenechkaP/subs-tracker/configs"
+	applog "github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
-func NewDb(conf *configs.Config) *gorm.DB {
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
-		conf.DBHost, conf.DBUser, conf.DBPassword, conf.DBName, conf.DBPort)
+// replicaResolver names the resolver used by queries that opt in with
+// FromReplica. Without it every query goes to the primary.
+const replicaResolver = "replicas"
 
-	gormDB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+// Startup retries start at initialBackoff and double up to maxBackoff.
+var (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// FromReplica sends the query to one of the configured read replicas, or to
+// the primary when there are none. Use it only for reads that tolerate
+// replication lag; everything else, including reads that follow a write,
+// stays on the primary.
+func FromReplica(q *gorm.DB) *gorm.DB {
+	// Read is needed as well for raw queries that do not start with SELECT.
+	return q.Clauses(dbresolver.Use(replicaResolver), dbresolver.Read)
+}
+
+// NewDb connects to Postgres and its read replicas. While the database is
+// not reachable it retries with backoff for up to conf.DBConnectTimeout.
+func NewDb(conf *configs.Config) (*gorm.DB, error) {
+	var gormDB *gorm.DB
+	err := retry(conf.DBConnectTimeout, func() error {
+		var err error
+		gormDB, err = connect(conf)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to db %s:%s: %w", conf.DBHost, conf.DBPort, err)
+	}
+	return gormDB, nil
+}
+
+func connect(conf *configs.Config) (*gorm.DB, error) {
+	// Failed attempts are reported by retry, not once more by GORM.
+	gormDB, err := gorm.Open(postgres.Open(dsn(conf, conf.DBHost, conf.DBPort)), &gorm.Config{
+		Logger: logger.Discard,
+	})
+	if err != nil {
+		return nil, err
+	}
+	gormDB.Logger = logger.Default.LogMode(logLevel(conf.DBLogLevel))
+	sqlDB, err := gormDB.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(conf.DBMaxOpenConns)
+	sqlDB.SetMaxIdleConns(conf.DBMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(conf.DBConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(conf.DBConnMaxIdleTime)
+
+	if len(conf.DBReplicas) == 0 {
+		return gormDB, nil
+	}
+	replicas := make([]gorm.Dialector, 0, len(conf.DBReplicas))
+	for _, replica := range conf.DBReplicas {
+		host, port, err := conf.ReplicaHostPort(replica)
+		if err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		replicas = append(replicas, postgres.Open(dsn(conf, host, port)))
+	}
+	if err := useReplicas(gormDB, replicas, conf); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("replicas: %w", err)
+	}
+	return gormDB, nil
+}
+
+// useReplicas registers the replicas for FromReplica queries, with the same
+// pool settings as the primary.
+func useReplicas(gormDB *gorm.DB, replicas []gorm.Dialector, conf *configs.Config) error {
+	return gormDB.Use(dbresolver.Register(dbresolver.Config{Replicas: replicas}, replicaResolver).
+		SetMaxOpenConns(conf.DBMaxOpenConns).
+		SetMaxIdleConns(conf.DBMaxIdleConns).
+		SetConnMaxLifetime(conf.DBConnMaxLifetime).
+		SetConnMaxIdleTime(conf.DBConnMaxIdleTime))
+}
+
+// retry calls attempt until it succeeds or timeout has passed since the
+// first call. A zero timeout means a single attempt.
+func retry(timeout time.Duration, attempt func() error) error {
+	deadline := time.Now().Add(timeout)
+	backoff := initialBackoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			if n > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", n, err)
+			}
+			return err
+		}
+		applog.Log.Warnf("db not ready (attempt %d): %v; retrying in %s", n, err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+func dsn(conf *configs.Config, host, port string) string {
+	parts := []string{
+		"host=" + dsnValue(host),
+		"port=" + dsnValue(port),
+		"user=" + dsnValue(conf.DBUser),
+		"password=" + dsnValue(conf.DBPassword),
+		"dbname=" + dsnValue(conf.DBName),
+		"sslmode=" + dsnValue(conf.DBSSLMode),
+	}
+	for _, tls := range [][2]string{
+		{"sslrootcert", conf.DBSSLRootCert},
+		{"sslcert", conf.DBSSLCert},
+		{"sslkey", conf.DBSSLKey},
+	} {
+		if tls[1] != "" {
+			parts = append(parts, tls[0]+"="+dsnValue(tls[1]))
+		}
+	}
+	parts = append(parts, "TimeZone=UTC")
+	return strings.Join(parts, " ")
+}
+
+// dsnValue quotes a value for a key=value connection string, so that
+// passwords with spaces or quotes survive.
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+func logLevel(level string) logger.LogLevel {
+	switch level {
+	case configs.DBLogLevelSilent:
+		return logger.Silent
+	case configs.DBLogLevelError:
+		return logger.Error
+	case configs.DBLogLevelInfo:
+		return logger.Info
+	default:
+		return logger.Warn
+	}
+}
+
+// OpenSQLite opens a SQLite database at path. Use ":memory:" for a private
+// in-memory database. level is one of the configs.DBLogLevel values.
+func OpenSQLite(path, level string) (*gorm.DB, error) {
+	gormDB, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		Logger: logger.Default.LogMode(logLevel(level)),
 	})
 	if err != nil {
-		log.Fatalf("failed to connect to db: %v", err)
+		return nil, err
+	}
+	if path == ":memory:" {
+		// Every new connection to ":memory:" would see its own empty database.
+		sqlDB, err := gormDB.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
 	}
-	return gormDB
+	return gormDB, nil
 }
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDSN(t *testing.T) {
+	conf := &configs.Config{
+		DBUser:        "app",
+		DBPassword:    "it's secret",
+		DBName:        "subs",
+		DBSSLMode:     "verify-full",
+		DBSSLRootCert: "/certs/ca.pem",
+	}
+	got := dsn(conf, "replica-1", "5433")
+	want := `host=replica-1 port=5433 user=app password='it\'s secret' dbname=subs sslmode=verify-full sslrootcert=/certs/ca.pem TimeZone=UTC`
+	if got != want {
+		t.Fatalf("dsn =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	initialBackoff, maxBackoff = time.Millisecond, 2*time.Millisecond
+	t.Cleanup(func() { initialBackoff, maxBackoff = 500*time.Millisecond, 5*time.Second })
+
+	calls := 0
+	err := retry(time.Second, func() error {
+		if calls++; calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("retry = %v after %d calls, want success on the third", err, calls)
+	}
+
+	calls = 0
+	err = retry(0, func() error { calls++; return errors.New("connection refused") })
+	if err == nil || calls != 1 {
+		t.Fatalf("retry with no timeout = %v after %d calls, want one failed attempt", err, calls)
+	}
+
+	err = retry(20*time.Millisecond, func() error { return errors.New("connection refused") })
+	if err == nil || !strings.Contains(err.Error(), "giving up after") {
+		t.Fatalf("retry = %v, want it to give up", err)
+	}
+}
+
+func TestFromReplica(t *testing.T) {
+	type item struct{ Name string }
+	open := func(name string) *gorm.DB {
+		gormDB, err := OpenSQLite(filepath.Join(t.TempDir(), name), configs.DBLogLevelSilent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := gormDB.AutoMigrate(&item{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := gormDB.Create(&item{Name: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+		return gormDB
+	}
+	primary := open("primary.db")
+	replica := open("replica.db")
+	replicaPath := replica.Dialector.(*sqlite.Dialector).DSN
+
+	if err := useReplicas(primary, []gorm.Dialector{sqlite.Open(replicaPath)}, &configs.Config{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var got item
+	if err := primary.First(&got).Error; err != nil || got.Name != "primary.db" {
+		t.Fatalf("plain read got %q, %v; want the primary", got.Name, err)
+	}
+	got = item{}
+	if err := FromReplica(primary).First(&got).Error; err != nil || got.Name != "replica.db" {
+		t.Fatalf("FromReplica read got %q, %v; want the replica", got.Name, err)
+	}
+	var names []string
+	err := FromReplica(primary).Raw("WITH x AS (SELECT name FROM items) SELECT name FROM x").Scan(&names).Error
+	if err != nil || len(names) != 1 || names[0] != "replica.db" {
+		t.Fatalf("FromReplica raw read got %v, %v; want the replica", names, err)
+	}
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+)
+
+// APIKeyHeader carries the caller's API key.
+const APIKeyHeader = "X-API-Key"
+
+type apiKeyContextKey struct{}
+
+// APIKeys checks the X-API-Key header against the configured keys and
+// records a listed key in the request context, where ClientID finds it. Any
+// other key is ignored rather than rejected, so that made-up keys can't be
+// used to pose as a new client.
+func APIKeys(keys []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := r.Header.Get(APIKeyHeader); key != "" && knownKey(keys, key) {
+				r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func knownKey(keys []string, key string) bool {
+	found := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+// ClientID identifies the caller of r by the API key checked by APIKeys, or
+// by its IP when there is none.
+func ClientID(r *http.Request) string {
+	if key, ok := r.Context().Value(apiKeyContextKey{}).(string); ok {
+		return "key " + key
+	}
+	return "ip " + clientIP(r)
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import "net/http"
+
+// MaxBodySize limits request bodies to limit bytes. Reading past the limit
+// fails with *http.MaxBytesError, which req.HandleBody reports as
+// req.ErrBodyTooLarge. A limit of 0 disables the check.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -5,9 +5,26 @@ import "net/http"
 type WrapperWriter struct {
 	http.ResponseWriter
 	Statuscode int
+	Size       int
 }
 
 func (w *WrapperWriter) WriteHeader(statusCode int) {
 	w.ResponseWriter.WriteHeader(statusCode)
 	w.Statuscode = statusCode
 }
+
+// Write records the implicit 200 of handlers that never call WriteHeader and
+// counts the body bytes.
+func (w *WrapperWriter) Write(b []byte) (int, error) {
+	if w.Statuscode == 0 {
+		w.Statuscode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.Size += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *WrapperWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,77 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+)
+
+func TestCORS(t *testing.T) {
+	router := http.NewServeMux()
+	router.HandleFunc("GET /subscriptions/{sub_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1"`)
+	})
+	cfg := middleware.CORSConfig{
+		AllowedOrigins: []string{"https://app.example"},
+		AllowedMethods: []string{"GET", "PATCH"},
+		AllowedHeaders: []string{"Content-Type", "If-Match"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	do := func(cfg middleware.CORSConfig, method, origin string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/subscriptions/1", nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		middleware.CORS(cfg)(router).ServeHTTP(w, r)
+		return w
+	}
+	preflight := http.Header{"Access-Control-Request-Method": {"PATCH"}}
+
+	w := do(cfg, "OPTIONS", "https://app.example", preflight)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight = %d, want 204", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://app.example",
+		"Access-Control-Allow-Methods": "GET, PATCH",
+		"Access-Control-Allow-Headers": "Content-Type, If-Match",
+		"Access-Control-Max-Age":       "600",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("preflight %s = %q, want %q", header, got, want)
+		}
+	}
+
+	w = do(cfg, "GET", "https://app.example", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "https://app.example" ||
+		w.Header().Get("Access-Control-Expose-Headers") != "ETag, X-Request-ID" {
+		t.Fatalf("GET = %d %v, want CORS headers", w.Code, w.Header())
+	}
+
+	w = do(cfg, "OPTIONS", "https://evil.example", preflight)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight from another origin = %d %v, want 204 without CORS headers", w.Code, w.Header())
+	}
+	if w := do(cfg, "GET", "https://evil.example", nil); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("GET from another origin got CORS headers %v", w.Header())
+	}
+
+	wildcard := cfg
+	wildcard.AllowedOrigins = []string{"*"}
+	if w := do(wildcard, "GET", "https://other.example", nil); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("wildcard Access-Control-Allow-Origin = %q, want *", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	if w := do(middleware.CORSConfig{}, "OPTIONS", "https://app.example", preflight); w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("preflight with CORS disabled = %d, want the router's 405", w.Code)
+	}
+}
@@ -1,10 +1,12 @@
 package middleware
 
 import (
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/sirupsen/logrus"
 )
 
 func Logging(next http.Handler) http.Handler {
@@ -15,11 +17,36 @@ func Logging(next http.Handler) http.Handler {
 			Statuscode:     0,
 		}
 		next.ServeHTTP(wrapper, r)
-		logger.Access.Infof("%d %s %s %v",
-			wrapper.Statuscode,
+		duration := time.Since(start)
+
+		status := wrapper.Statuscode
+		if status == 0 {
+			status = http.StatusOK
+		}
+		logger.AccessFromRequest(r).WithFields(logrus.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      status,
+			"size":        wrapper.Size,
+			"duration_ms": float64(duration.Microseconds()) / 1000,
+			"client_ip":   clientIP(r),
+		}).Infof("%d %s %s %v %dB %s",
+			status,
 			r.Method,
 			r.URL.Path,
-			time.Since(start),
+			duration,
+			wrapper.Size,
+			clientIP(r),
 		)
 	})
 }
+
+// clientIP is the address of the peer. X-Forwarded-For is not trusted, since
+// any client can set it.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
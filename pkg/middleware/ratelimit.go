@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/pkg/res"
+)
+
+const (
+	ErrTooManyRequests     = "TOO MANY REQUESTS, RETRY LATER"
+	ProblemTooManyRequests = "rate_limited"
+)
+
+// bucketIdleTTL is how long a bucket is kept without requests, and how often
+// such buckets are swept.
+const bucketIdleTTL = time.Minute
+
+// maxBuckets bounds the memory held by buckets when many clients show up
+// within bucketIdleTTL.
+const maxBuckets = 100_000
+
+// Limit is a token bucket refilled at RPS tokens per second, holding at most
+// Burst tokens. A zero RPS means no limit.
+type Limit struct {
+	RPS   float64
+	Burst int
+}
+
+// RateLimitConfig holds the limit for all routes and overrides for single
+// routes, keyed by ServeMux pattern such as "POST /subscriptions". A route
+// with its own limit gets buckets separate from the default ones.
+type RateLimitConfig struct {
+	Default Limit
+	Routes  map[string]Limit
+	// Canonical maps the pattern a request matched to the one Routes is
+	// keyed by, e.g. a legacy alias to its versioned route, which then share
+	// a limit and buckets. It may be nil.
+	Canonical func(pattern string) string
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take refills the bucket and spends one token. When the bucket is empty it
+// returns how long until a token becomes available.
+func (b *bucket) take(limit Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.RPS)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.RPS * float64(time.Second))
+}
+
+type rateLimiter struct {
+	config     RateLimitConfig
+	mu         sync.Mutex
+	buckets    map[string]*bucket
+	maxBuckets int
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+// RateLimit rejects requests over the configured limits with 429 and a
+// Retry-After header. router resolves the route of a request before it is
+// served, so that per-route limits apply.
+func RateLimit(router *http.ServeMux, config RateLimitConfig) func(http.Handler) http.Handler {
+	limiter := &rateLimiter{config: config, buckets: make(map[string]*bucket), maxBuckets: maxBuckets, now: time.Now}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := router.Handler(r)
+			route := pattern
+			if config.Canonical != nil {
+				route = config.Canonical(pattern)
+			}
+			limit, scope := config.Default, ""
+			if routeLimit, ok := config.Routes[route]; ok {
+				limit, scope = routeLimit, route
+			}
+			if limit.RPS <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ok, wait := limiter.allow(scope+"|"+ClientID(r), limit)
+			if ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// The mux never sees the request; record the route for logs and
+			// metrics ourselves.
+			r.Pattern = pattern
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			logger.FromRequest(r).Warnf("RateLimit rejected client_ip=%s retry_after=%ds", clientIP(r), retryAfter)
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			res.WriteProblem(w, r, http.StatusTooManyRequests, ProblemTooManyRequests, ErrTooManyRequests)
+		})
+	}
+}
+
+func (limiter *rateLimiter) allow(key string, limit Limit) (bool, time.Duration) {
+	now := limiter.now()
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+
+	if now.Sub(limiter.lastSweep) > bucketIdleTTL {
+		limiter.sweep(now)
+	}
+	b, ok := limiter.buckets[key]
+	if !ok {
+		// When full, give up an arbitrary bucket; its client at worst gets
+		// a full one early.
+		for evict := range limiter.buckets {
+			if len(limiter.buckets) < limiter.maxBuckets {
+				break
+			}
+			delete(limiter.buckets, evict)
+		}
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		limiter.buckets[key] = b
+	}
+	return b.take(limit, now)
+}
+
+// sweep drops buckets idle for longer than bucketIdleTTL. Such a bucket has
+// usually refilled; if not, the client merely gets a full bucket early.
+func (limiter *rateLimiter) sweep(now time.Time) {
+	for key, b := range limiter.buckets {
+		if now.Sub(b.last) > bucketIdleTTL {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastSweep = now
+}
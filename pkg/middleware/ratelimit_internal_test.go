@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterBoundsBuckets(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := &rateLimiter{buckets: make(map[string]*bucket), maxBuckets: 10, lastSweep: now, now: func() time.Time { return now }}
+	limit := Limit{RPS: 1, Burst: 1}
+
+	for i := range 100 {
+		if ok, _ := limiter.allow(strconv.Itoa(i), limit); !ok {
+			t.Fatalf("client %d was limited on its first request", i)
+		}
+	}
+	if len(limiter.buckets) != 10 {
+		t.Fatalf("%d buckets kept, want at most 10", len(limiter.buckets))
+	}
+	if ok, _ := limiter.allow("99", limit); ok {
+		t.Fatal("the newest client's bucket was given up")
+	}
+
+	now = now.Add(2 * bucketIdleTTL)
+	limiter.allow("new", limit)
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("%d buckets left after the idle ones were swept, want 1", len(limiter.buckets))
+	}
+}
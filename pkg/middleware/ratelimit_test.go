@@ -0,0 +1,136 @@
+package middleware_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/pkg/apiversion"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+	"github.com/SenechkaP/subs-tracker/pkg/req"
+)
+
+func TestRateLimit(t *testing.T) {
+	router := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	router.HandleFunc("POST /subscriptions", ok)
+	router.HandleFunc("GET /subscriptions/{sub_id}", ok)
+	router.HandleFunc("GET /healthz", ok)
+	handler := middleware.APIKeys([]string{"key-a", "key-b"})(middleware.RateLimit(router, middleware.RateLimitConfig{
+		Default: middleware.Limit{RPS: 1, Burst: 3},
+		Routes: map[string]middleware.Limit{
+			"POST /subscriptions": {RPS: 0.5, Burst: 1},
+			"GET /healthz":        {},
+		},
+	})(router))
+
+	do := func(method, path, addr, apiKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		r.RemoteAddr = addr
+		if apiKey != "" {
+			r.Header.Set(middleware.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := do("POST", "/subscriptions", "10.0.0.1:1000", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("first POST = %d", w.Code)
+	}
+	w := do("POST", "/subscriptions", "10.0.0.1:1001", "")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second POST = %d, want 429 from the route limit", w.Code)
+	}
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry != 2 {
+		t.Fatalf("Retry-After = %q, want 2", w.Header().Get("Retry-After"))
+	}
+
+	// The route limit has its own buckets; the default one is untouched.
+	for i := 0; i < 3; i++ {
+		if w := do("GET", "/subscriptions/1", "10.0.0.1:1000", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("GET %d = %d, want it within the default burst", i, w.Code)
+		}
+	}
+	if w := do("GET", "/subscriptions/1", "10.0.0.1:1000", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("GET over the burst = %d, want 429", w.Code)
+	}
+
+	if w := do("POST", "/subscriptions", "10.0.0.2:1000", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("POST from another IP = %d", w.Code)
+	}
+	if w := do("POST", "/subscriptions", "10.0.0.1:1000", "key-a"); w.Code != http.StatusNoContent {
+		t.Fatalf("POST with an API key = %d, want a bucket per key", w.Code)
+	}
+	if w := do("POST", "/subscriptions", "10.0.0.3:1000", "key-a"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("POST with the same API key from another IP = %d, want 429", w.Code)
+	}
+	// Unknown keys don't buy a fresh bucket; the IP's bucket is used.
+	for _, key := range []string{"made-up-1", "made-up-2", "KEY-B"} {
+		if w := do("POST", "/subscriptions", "10.0.0.2:1000", key); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("POST with unknown API key %q = %d, want 429", key, w.Code)
+		}
+	}
+	if w := do("POST", "/subscriptions", "10.0.0.2:1000", "key-b"); w.Code != http.StatusNoContent {
+		t.Fatalf("POST with another known API key = %d", w.Code)
+	}
+
+	for i := 0; i < 10; i++ {
+		if w := do("GET", "/healthz", "10.0.0.1:1000", ""); w.Code != http.StatusNoContent {
+			t.Fatalf("GET /healthz = %d, want it unlimited", w.Code)
+		}
+	}
+}
+
+func TestRateLimitCoversLegacyAliases(t *testing.T) {
+	router := http.NewServeMux()
+	v1 := apiversion.Mount(router, apiversion.VersionConfig{Name: "v1", LegacyAliases: &apiversion.Deprecation{}})
+	v1.HandleFunc("POST /subscriptions", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := middleware.RateLimit(router, middleware.RateLimitConfig{
+		Routes:    map[string]middleware.Limit{"POST /v1/subscriptions": {RPS: 0.5, Burst: 1}},
+		Canonical: v1.Canonical,
+	})(router)
+
+	do := func(path string) int {
+		r := httptest.NewRequest("POST", path, nil)
+		r.RemoteAddr = "10.0.0.1:1000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := do("/v1/subscriptions"); code != http.StatusNoContent {
+		t.Fatalf("POST /v1/subscriptions = %d", code)
+	}
+	if code := do("/subscriptions"); code != http.StatusTooManyRequests {
+		t.Fatalf("POST /subscriptions = %d, want 429 from the /v1 route limit", code)
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	var gotErr error
+	handler := middleware.MaxBodySize(16)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, gotErr = req.HandleBody[map[string]string](r)
+	}))
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader(`{"service":"`+strings.Repeat("x", 32)+`"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !errors.Is(gotErr, req.ErrBodyTooLarge) {
+		t.Fatalf("HandleBody error = %v, want ErrBodyTooLarge", gotErr)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"a":"b"}`))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if gotErr != nil {
+		t.Fatalf("HandleBody error = %v for a small body", gotErr)
+	}
+
+	r = httptest.NewRequest("POST", "/", io.NopCloser(strings.NewReader("")))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	if !errors.Is(gotErr, io.EOF) {
+		t.Fatalf("HandleBody error = %v for an empty body, want io.EOF", gotErr)
+	}
+}
@@ -0,0 +1,70 @@
+package middleware_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/internal/logger"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+)
+
+func TestRequestIDReachesLogs(t *testing.T) {
+	var logs, access bytes.Buffer
+	logger.Log.SetOutput(&logs)
+	logger.Access.SetOutput(&access)
+	t.Cleanup(func() {
+		logger.Log.SetOutput(os.Stderr)
+		logger.Access.SetOutput(os.Stderr)
+	})
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /users/{user_id}/things", func(w http.ResponseWriter, r *http.Request) {
+		logger.FromRequest(r).Warnf("handler line")
+		w.Write([]byte("hello"))
+	})
+	handler := middleware.RequestID(middleware.Logging(router))
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{name: "kept", header: "abc-123", want: "abc-123"},
+		{name: "generated", header: ""},
+		{name: "unsafe replaced", header: "bad id\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			access.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/users/u1/things", nil)
+			if tt.header != "" {
+				req.Header.Set(middleware.RequestIDHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(middleware.RequestIDHeader)
+			if tt.want != "" && id != tt.want {
+				t.Fatalf("X-Request-ID = %q, want %q", id, tt.want)
+			}
+			if tt.want == "" && (id == "" || id == tt.header) {
+				t.Fatalf("X-Request-ID = %q, want a generated ID", id)
+			}
+			for name, line := range map[string]string{"handler": logs.String(), "access": access.String()} {
+				for _, field := range []string{"request_id=" + id, `route="GET /users/{user_id}/things"`, "user_id=u1"} {
+					if !strings.Contains(line, field) {
+						t.Fatalf("%s log %q lacks %s", name, line, field)
+					}
+				}
+			}
+			if !strings.Contains(access.String(), " 5B ") {
+				t.Fatalf("access log %q lacks the response size", access.String())
+			}
+		})
+	}
+}
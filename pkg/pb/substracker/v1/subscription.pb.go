@@ -0,0 +1,907 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.28.3
+// source: substracker/v1/subscription.proto
+
+package substrackerv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Subscription struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ServiceName   string                 `protobuf:"bytes,2,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Price         int64                  `protobuf:"varint,3,opt,name=price,proto3" json:"price,omitempty"`
+	UserId        string                 `protobuf:"bytes,4,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	StartDate     string                 `protobuf:"bytes,5,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       *string                `protobuf:"bytes,6,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	Version       int64                  `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscription) Reset() {
+	*x = Subscription{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscription) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscription) ProtoMessage() {}
+
+func (x *Subscription) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscription.ProtoReflect.Descriptor instead.
+func (*Subscription) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Subscription) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Subscription) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *Subscription) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *Subscription) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Subscription) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *Subscription) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+func (x *Subscription) GetVersion() int64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Subscription) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Subscription) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type GetSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetSubscriptionRequest) Reset() {
+	*x = GetSubscriptionRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetSubscriptionRequest) ProtoMessage() {}
+
+func (x *GetSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*GetSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type CreateSubscriptionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ServiceName   string                 `protobuf:"bytes,1,opt,name=service_name,json=serviceName,proto3" json:"service_name,omitempty"`
+	Price         int64                  `protobuf:"varint,2,opt,name=price,proto3" json:"price,omitempty"`
+	UserId        string                 `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	StartDate     string                 `protobuf:"bytes,4,opt,name=start_date,json=startDate,proto3" json:"start_date,omitempty"`
+	EndDate       *string                `protobuf:"bytes,5,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSubscriptionRequest) Reset() {
+	*x = CreateSubscriptionRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSubscriptionRequest) ProtoMessage() {}
+
+func (x *CreateSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*CreateSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateSubscriptionRequest) GetServiceName() string {
+	if x != nil {
+		return x.ServiceName
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetPrice() int64 {
+	if x != nil {
+		return x.Price
+	}
+	return 0
+}
+
+func (x *CreateSubscriptionRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetStartDate() string {
+	if x != nil {
+		return x.StartDate
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionRequest) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+type CreateSubscriptionResponse struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Subscription *Subscription          `protobuf:"bytes,1,opt,name=subscription,proto3" json:"subscription,omitempty"`
+	// Set when the new subscription overlaps existing ones for the same service.
+	Warning       string   `protobuf:"bytes,2,opt,name=warning,proto3" json:"warning,omitempty"`
+	OverlapsWith  []string `protobuf:"bytes,3,rep,name=overlaps_with,json=overlapsWith,proto3" json:"overlaps_with,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateSubscriptionResponse) Reset() {
+	*x = CreateSubscriptionResponse{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateSubscriptionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateSubscriptionResponse) ProtoMessage() {}
+
+func (x *CreateSubscriptionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateSubscriptionResponse.ProtoReflect.Descriptor instead.
+func (*CreateSubscriptionResponse) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CreateSubscriptionResponse) GetSubscription() *Subscription {
+	if x != nil {
+		return x.Subscription
+	}
+	return nil
+}
+
+func (x *CreateSubscriptionResponse) GetWarning() string {
+	if x != nil {
+		return x.Warning
+	}
+	return ""
+}
+
+func (x *CreateSubscriptionResponse) GetOverlapsWith() []string {
+	if x != nil {
+		return x.OverlapsWith
+	}
+	return nil
+}
+
+type PatchSubscriptionRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Id        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Price     *int64                 `protobuf:"varint,2,opt,name=price,proto3,oneof" json:"price,omitempty"`
+	StartDate *string                `protobuf:"bytes,3,opt,name=start_date,json=startDate,proto3,oneof" json:"start_date,omitempty"`
+	// An empty string clears the end date.
+	EndDate *string `protobuf:"bytes,4,opt,name=end_date,json=endDate,proto3,oneof" json:"end_date,omitempty"`
+	// When non-zero the patch only applies if the subscription is still at
+	// this version, like the If-Match header of the REST API.
+	ExpectedVersion int64 `protobuf:"varint,5,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *PatchSubscriptionRequest) Reset() {
+	*x = PatchSubscriptionRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PatchSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PatchSubscriptionRequest) ProtoMessage() {}
+
+func (x *PatchSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PatchSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*PatchSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PatchSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *PatchSubscriptionRequest) GetPrice() int64 {
+	if x != nil && x.Price != nil {
+		return *x.Price
+	}
+	return 0
+}
+
+func (x *PatchSubscriptionRequest) GetStartDate() string {
+	if x != nil && x.StartDate != nil {
+		return *x.StartDate
+	}
+	return ""
+}
+
+func (x *PatchSubscriptionRequest) GetEndDate() string {
+	if x != nil && x.EndDate != nil {
+		return *x.EndDate
+	}
+	return ""
+}
+
+func (x *PatchSubscriptionRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteSubscriptionRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// Same semantics as PatchSubscriptionRequest.expected_version.
+	ExpectedVersion int64 `protobuf:"varint,2,opt,name=expected_version,json=expectedVersion,proto3" json:"expected_version,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *DeleteSubscriptionRequest) Reset() {
+	*x = DeleteSubscriptionRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSubscriptionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSubscriptionRequest) ProtoMessage() {}
+
+func (x *DeleteSubscriptionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSubscriptionRequest.ProtoReflect.Descriptor instead.
+func (*DeleteSubscriptionRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DeleteSubscriptionRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteSubscriptionRequest) GetExpectedVersion() int64 {
+	if x != nil {
+		return x.ExpectedVersion
+	}
+	return 0
+}
+
+type DeleteSubscriptionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteSubscriptionResponse) Reset() {
+	*x = DeleteSubscriptionResponse{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteSubscriptionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteSubscriptionResponse) ProtoMessage() {}
+
+func (x *DeleteSubscriptionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteSubscriptionResponse.ProtoReflect.Descriptor instead.
+func (*DeleteSubscriptionResponse) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{6}
+}
+
+type ListUserSubscriptionsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Offset int32                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	// Defaults to 10 when zero.
+	Limit         int32 `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUserSubscriptionsRequest) Reset() {
+	*x = ListUserSubscriptionsRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUserSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUserSubscriptionsRequest) ProtoMessage() {}
+
+func (x *ListUserSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUserSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*ListUserSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListUserSubscriptionsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ListUserSubscriptionsRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *ListUserSubscriptionsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type ListUserSubscriptionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Subscriptions []*Subscription        `protobuf:"bytes,1,rep,name=subscriptions,proto3" json:"subscriptions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListUserSubscriptionsResponse) Reset() {
+	*x = ListUserSubscriptionsResponse{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListUserSubscriptionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListUserSubscriptionsResponse) ProtoMessage() {}
+
+func (x *ListUserSubscriptionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListUserSubscriptionsResponse.ProtoReflect.Descriptor instead.
+func (*ListUserSubscriptionsResponse) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ListUserSubscriptionsResponse) GetSubscriptions() []*Subscription {
+	if x != nil {
+		return x.Subscriptions
+	}
+	return nil
+}
+
+type StreamUserSubscriptionsRequest struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	UserId string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Number of rows fetched from storage at a time. Defaults to 100 when zero.
+	PageSize      int32 `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUserSubscriptionsRequest) Reset() {
+	*x = StreamUserSubscriptionsRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUserSubscriptionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUserSubscriptionsRequest) ProtoMessage() {}
+
+func (x *StreamUserSubscriptionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUserSubscriptionsRequest.ProtoReflect.Descriptor instead.
+func (*StreamUserSubscriptionsRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *StreamUserSubscriptionsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *StreamUserSubscriptionsRequest) GetPageSize() int32 {
+	if x != nil {
+		return x.PageSize
+	}
+	return 0
+}
+
+type SumByMonthRangeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         string                 `protobuf:"bytes,1,opt,name=start,proto3" json:"start,omitempty"`
+	End           string                 `protobuf:"bytes,2,opt,name=end,proto3" json:"end,omitempty"`
+	UserId        *string                `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3,oneof" json:"user_id,omitempty"`
+	ServiceName   *string                `protobuf:"bytes,4,opt,name=service_name,json=serviceName,proto3,oneof" json:"service_name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SumByMonthRangeRequest) Reset() {
+	*x = SumByMonthRangeRequest{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SumByMonthRangeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SumByMonthRangeRequest) ProtoMessage() {}
+
+func (x *SumByMonthRangeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SumByMonthRangeRequest.ProtoReflect.Descriptor instead.
+func (*SumByMonthRangeRequest) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SumByMonthRangeRequest) GetStart() string {
+	if x != nil {
+		return x.Start
+	}
+	return ""
+}
+
+func (x *SumByMonthRangeRequest) GetEnd() string {
+	if x != nil {
+		return x.End
+	}
+	return ""
+}
+
+func (x *SumByMonthRangeRequest) GetUserId() string {
+	if x != nil && x.UserId != nil {
+		return *x.UserId
+	}
+	return ""
+}
+
+func (x *SumByMonthRangeRequest) GetServiceName() string {
+	if x != nil && x.ServiceName != nil {
+		return *x.ServiceName
+	}
+	return ""
+}
+
+type SumByMonthRangeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TotalSum      int64                  `protobuf:"varint,1,opt,name=total_sum,json=totalSum,proto3" json:"total_sum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SumByMonthRangeResponse) Reset() {
+	*x = SumByMonthRangeResponse{}
+	mi := &file_substracker_v1_subscription_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SumByMonthRangeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SumByMonthRangeResponse) ProtoMessage() {}
+
+func (x *SumByMonthRangeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_substracker_v1_subscription_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SumByMonthRangeResponse.ProtoReflect.Descriptor instead.
+func (*SumByMonthRangeResponse) Descriptor() ([]byte, []int) {
+	return file_substracker_v1_subscription_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SumByMonthRangeResponse) GetTotalSum() int64 {
+	if x != nil {
+		return x.TotalSum
+	}
+	return 0
+}
+
+var File_substracker_v1_subscription_proto protoreflect.FileDescriptor
+
+const file_substracker_v1_subscription_proto_rawDesc = "" +
+	"\n" +
+	"!substracker/v1/subscription.proto\x12\x0esubstracker.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xcc\x02\n" +
+	"\fSubscription\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12!\n" +
+	"\fservice_name\x18\x02 \x01(\tR\vserviceName\x12\x14\n" +
+	"\x05price\x18\x03 \x01(\x03R\x05price\x12\x17\n" +
+	"\auser_id\x18\x04 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x05 \x01(\tR\tstartDate\x12\x1e\n" +
+	"\bend_date\x18\x06 \x01(\tH\x00R\aendDate\x88\x01\x01\x12\x18\n" +
+	"\aversion\x18\a \x01(\x03R\aversion\x129\n" +
+	"\n" +
+	"created_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\v\n" +
+	"\t_end_date\"(\n" +
+	"\x16GetSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xb9\x01\n" +
+	"\x19CreateSubscriptionRequest\x12!\n" +
+	"\fservice_name\x18\x01 \x01(\tR\vserviceName\x12\x14\n" +
+	"\x05price\x18\x02 \x01(\x03R\x05price\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"start_date\x18\x04 \x01(\tR\tstartDate\x12\x1e\n" +
+	"\bend_date\x18\x05 \x01(\tH\x00R\aendDate\x88\x01\x01B\v\n" +
+	"\t_end_date\"\x9d\x01\n" +
+	"\x1aCreateSubscriptionResponse\x12@\n" +
+	"\fsubscription\x18\x01 \x01(\v2\x1c.substracker.v1.SubscriptionR\fsubscription\x12\x18\n" +
+	"\awarning\x18\x02 \x01(\tR\awarning\x12#\n" +
+	"\roverlaps_with\x18\x03 \x03(\tR\foverlapsWith\"\xda\x01\n" +
+	"\x18PatchSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x19\n" +
+	"\x05price\x18\x02 \x01(\x03H\x00R\x05price\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"start_date\x18\x03 \x01(\tH\x01R\tstartDate\x88\x01\x01\x12\x1e\n" +
+	"\bend_date\x18\x04 \x01(\tH\x02R\aendDate\x88\x01\x01\x12)\n" +
+	"\x10expected_version\x18\x05 \x01(\x03R\x0fexpectedVersionB\b\n" +
+	"\x06_priceB\r\n" +
+	"\v_start_dateB\v\n" +
+	"\t_end_date\"V\n" +
+	"\x19DeleteSubscriptionRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12)\n" +
+	"\x10expected_version\x18\x02 \x01(\x03R\x0fexpectedVersion\"\x1c\n" +
+	"\x1aDeleteSubscriptionResponse\"e\n" +
+	"\x1cListUserSubscriptionsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x05R\x06offset\x12\x14\n" +
+	"\x05limit\x18\x03 \x01(\x05R\x05limit\"c\n" +
+	"\x1dListUserSubscriptionsResponse\x12B\n" +
+	"\rsubscriptions\x18\x01 \x03(\v2\x1c.substracker.v1.SubscriptionR\rsubscriptions\"V\n" +
+	"\x1eStreamUserSubscriptionsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tpage_size\x18\x02 \x01(\x05R\bpageSize\"\xa3\x01\n" +
+	"\x16SumByMonthRangeRequest\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\tR\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\tR\x03end\x12\x1c\n" +
+	"\auser_id\x18\x03 \x01(\tH\x00R\x06userId\x88\x01\x01\x12&\n" +
+	"\fservice_name\x18\x04 \x01(\tH\x01R\vserviceName\x88\x01\x01B\n" +
+	"\n" +
+	"\b_user_idB\x0f\n" +
+	"\r_service_name\"6\n" +
+	"\x17SumByMonthRangeResponse\x12\x1b\n" +
+	"\ttotal_sum\x18\x01 \x01(\x03R\btotalSum2\xea\x05\n" +
+	"\x13SubscriptionService\x12W\n" +
+	"\x0fGetSubscription\x12&.substracker.v1.GetSubscriptionRequest\x1a\x1c.substracker.v1.Subscription\x12k\n" +
+	"\x12CreateSubscription\x12).substracker.v1.CreateSubscriptionRequest\x1a*.substracker.v1.CreateSubscriptionResponse\x12[\n" +
+	"\x11PatchSubscription\x12(.substracker.v1.PatchSubscriptionRequest\x1a\x1c.substracker.v1.Subscription\x12k\n" +
+	"\x12DeleteSubscription\x12).substracker.v1.DeleteSubscriptionRequest\x1a*.substracker.v1.DeleteSubscriptionResponse\x12t\n" +
+	"\x15ListUserSubscriptions\x12,.substracker.v1.ListUserSubscriptionsRequest\x1a-.substracker.v1.ListUserSubscriptionsResponse\x12i\n" +
+	"\x17StreamUserSubscriptions\x12..substracker.v1.StreamUserSubscriptionsRequest\x1a\x1c.substracker.v1.Subscription0\x01\x12b\n" +
+	"\x0fSumByMonthRange\x12&.substracker.v1.SumByMonthRangeRequest\x1a'.substracker.v1.SumByMonthRangeResponseBGZEgithub.com/SenechkaP/subs-tracker/pkg/pb/substracker/v1;substrackerv1b\x06proto3"
+
+var (
+	file_substracker_v1_subscription_proto_rawDescOnce sync.Once
+	file_substracker_v1_subscription_proto_rawDescData []byte
+)
+
+func file_substracker_v1_subscription_proto_rawDescGZIP() []byte {
+	file_substracker_v1_subscription_proto_rawDescOnce.Do(func() {
+		file_substracker_v1_subscription_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_substracker_v1_subscription_proto_rawDesc), len(file_substracker_v1_subscription_proto_rawDesc)))
+	})
+	return file_substracker_v1_subscription_proto_rawDescData
+}
+
+var file_substracker_v1_subscription_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_substracker_v1_subscription_proto_goTypes = []any{
+	(*Subscription)(nil),                   // 0: substracker.v1.Subscription
+	(*GetSubscriptionRequest)(nil),         // 1: substracker.v1.GetSubscriptionRequest
+	(*CreateSubscriptionRequest)(nil),      // 2: substracker.v1.CreateSubscriptionRequest
+	(*CreateSubscriptionResponse)(nil),     // 3: substracker.v1.CreateSubscriptionResponse
+	(*PatchSubscriptionRequest)(nil),       // 4: substracker.v1.PatchSubscriptionRequest
+	(*DeleteSubscriptionRequest)(nil),      // 5: substracker.v1.DeleteSubscriptionRequest
+	(*DeleteSubscriptionResponse)(nil),     // 6: substracker.v1.DeleteSubscriptionResponse
+	(*ListUserSubscriptionsRequest)(nil),   // 7: substracker.v1.ListUserSubscriptionsRequest
+	(*ListUserSubscriptionsResponse)(nil),  // 8: substracker.v1.ListUserSubscriptionsResponse
+	(*StreamUserSubscriptionsRequest)(nil), // 9: substracker.v1.StreamUserSubscriptionsRequest
+	(*SumByMonthRangeRequest)(nil),         // 10: substracker.v1.SumByMonthRangeRequest
+	(*SumByMonthRangeResponse)(nil),        // 11: substracker.v1.SumByMonthRangeResponse
+	(*timestamppb.Timestamp)(nil),          // 12: google.protobuf.Timestamp
+}
+var file_substracker_v1_subscription_proto_depIdxs = []int32{
+	12, // 0: substracker.v1.Subscription.created_at:type_name -> google.protobuf.Timestamp
+	12, // 1: substracker.v1.Subscription.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 2: substracker.v1.CreateSubscriptionResponse.subscription:type_name -> substracker.v1.Subscription
+	0,  // 3: substracker.v1.ListUserSubscriptionsResponse.subscriptions:type_name -> substracker.v1.Subscription
+	1,  // 4: substracker.v1.SubscriptionService.GetSubscription:input_type -> substracker.v1.GetSubscriptionRequest
+	2,  // 5: substracker.v1.SubscriptionService.CreateSubscription:input_type -> substracker.v1.CreateSubscriptionRequest
+	4,  // 6: substracker.v1.SubscriptionService.PatchSubscription:input_type -> substracker.v1.PatchSubscriptionRequest
+	5,  // 7: substracker.v1.SubscriptionService.DeleteSubscription:input_type -> substracker.v1.DeleteSubscriptionRequest
+	7,  // 8: substracker.v1.SubscriptionService.ListUserSubscriptions:input_type -> substracker.v1.ListUserSubscriptionsRequest
+	9,  // 9: substracker.v1.SubscriptionService.StreamUserSubscriptions:input_type -> substracker.v1.StreamUserSubscriptionsRequest
+	10, // 10: substracker.v1.SubscriptionService.SumByMonthRange:input_type -> substracker.v1.SumByMonthRangeRequest
+	0,  // 11: substracker.v1.SubscriptionService.GetSubscription:output_type -> substracker.v1.Subscription
+	3,  // 12: substracker.v1.SubscriptionService.CreateSubscription:output_type -> substracker.v1.CreateSubscriptionResponse
+	0,  // 13: substracker.v1.SubscriptionService.PatchSubscription:output_type -> substracker.v1.Subscription
+	6,  // 14: substracker.v1.SubscriptionService.DeleteSubscription:output_type -> substracker.v1.DeleteSubscriptionResponse
+	8,  // 15: substracker.v1.SubscriptionService.ListUserSubscriptions:output_type -> substracker.v1.ListUserSubscriptionsResponse
+	0,  // 16: substracker.v1.SubscriptionService.StreamUserSubscriptions:output_type -> substracker.v1.Subscription
+	11, // 17: substracker.v1.SubscriptionService.SumByMonthRange:output_type -> substracker.v1.SumByMonthRangeResponse
+	11, // [11:18] is the sub-list for method output_type
+	4,  // [4:11] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_substracker_v1_subscription_proto_init() }
+func file_substracker_v1_subscription_proto_init() {
+	if File_substracker_v1_subscription_proto != nil {
+		return
+	}
+	file_substracker_v1_subscription_proto_msgTypes[0].OneofWrappers = []any{}
+	file_substracker_v1_subscription_proto_msgTypes[2].OneofWrappers = []any{}
+	file_substracker_v1_subscription_proto_msgTypes[4].OneofWrappers = []any{}
+	file_substracker_v1_subscription_proto_msgTypes[10].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_substracker_v1_subscription_proto_rawDesc), len(file_substracker_v1_subscription_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_substracker_v1_subscription_proto_goTypes,
+		DependencyIndexes: file_substracker_v1_subscription_proto_depIdxs,
+		MessageInfos:      file_substracker_v1_subscription_proto_msgTypes,
+	}.Build()
+	File_substracker_v1_subscription_proto = out.File
+	file_substracker_v1_subscription_proto_goTypes = nil
+	file_substracker_v1_subscription_proto_depIdxs = nil
+}
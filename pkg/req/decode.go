@@ -1,15 +1,138 @@
 package req
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"reflect"
+	"sort"
+	"strings"
 )
 
+const (
+	ReasonUnknownField = "unknown_field"
+	ReasonInvalidType  = "invalid_type"
+)
+
+// FieldError is a JSON field that does not fit the request type. Field is
+// the dotted JSON path, e.g. "price".
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// FieldErrors is returned by Decode when the body is a JSON object with
+// unknown fields or values of the wrong type. Decode returns the payload
+// decoded from the remaining fields along with it, so that callers can
+// validate that too and report every problem at once.
+type FieldErrors []FieldError
+
+func (e FieldErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + strings.ReplaceAll(fe.Reason, "_", " ")
+	}
+	return strings.Join(parts, "; ")
+}
+
 func Decode[T any](body io.ReadCloser) (*T, error) {
-	var payload T
-	err := json.NewDecoder(body).Decode(&payload)
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
+	var payload T
+	if err := Unmarshal(data, &payload); err != nil {
+		var fieldErrs FieldErrors
+		if errors.As(err, &fieldErrs) {
+			return &payload, err
+		}
+		return nil, err
+	}
 	return &payload, nil
 }
+
+// Unmarshal decodes JSON into v. Unlike json.Unmarshal it rejects unknown
+// fields when v points to a struct, and it reports them and every mistyped
+// value together as FieldErrors. Empty input is io.EOF.
+func Unmarshal(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return io.EOF
+	}
+	// json.Unmarshal carries on past a mistyped value but only returns the
+	// first one, so the fields are checked one by one below.
+	var typeErr *json.UnmarshalTypeError
+	if err := json.Unmarshal(data, v); err != nil {
+		if !errors.As(err, &typeErr) || typeErr.Field == "" {
+			return err
+		}
+	}
+
+	t := reflect.TypeOf(v).Elem()
+	if t.Kind() != reflect.Struct {
+		if typeErr != nil {
+			return FieldErrors{{Field: typeErr.Field, Reason: ReasonInvalidType}}
+		}
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("request body must be a JSON object")
+	}
+	known := jsonFields(t)
+	var mistyped, unknown FieldErrors
+	for name, raw := range fields {
+		fieldType, ok := known[name]
+		if !ok {
+			unknown = append(unknown, FieldError{Field: name, Reason: ReasonUnknownField})
+			continue
+		}
+		if typeErr == nil {
+			continue
+		}
+		var fieldErr *json.UnmarshalTypeError
+		if errors.As(json.Unmarshal(raw, reflect.New(fieldType).Interface()), &fieldErr) {
+			field := name
+			if fieldErr.Field != "" {
+				field += "." + fieldErr.Field
+			}
+			mistyped = append(mistyped, FieldError{Field: field, Reason: ReasonInvalidType})
+		}
+	}
+	// The mistyped value was under a key that only matches a field
+	// case-insensitively, which is reported as unknown.
+	if typeErr != nil && len(mistyped) == 0 {
+		mistyped = append(mistyped, FieldError{Field: typeErr.Field, Reason: ReasonInvalidType})
+	}
+	sort.Slice(mistyped, func(i, j int) bool { return mistyped[i].Field < mistyped[j].Field })
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Field < unknown[j].Field })
+
+	if fieldErrs := append(mistyped, unknown...); len(fieldErrs) > 0 {
+		return fieldErrs
+	}
+	return nil
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types. Keys
+// must match exactly, although encoding/json itself would accept "Price" for
+// "price".
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
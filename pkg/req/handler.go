@@ -1,14 +1,26 @@
 package req
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 )
 
+// ErrBodyTooLarge is returned when the body is cut off by
+// http.MaxBytesReader.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+// HandleBody decodes the request body as Decode does. Along with FieldErrors
+// it returns the partially decoded payload.
 func HandleBody[T any](q *http.Request) (*T, error) {
 	body, err := Decode[T](q.Body)
 
 	if err != nil {
-		return nil, err
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, fmt.Errorf("%w: limit is %d bytes", ErrBodyTooLarge, maxBytesErr.Limit)
+		}
+		return body, err
 	}
 
 	return body, nil
@@ -0,0 +1,18 @@
+// Package requestid carries the ID of the request being served in its
+// context, for responses and logs to refer to.
+package requestid
+
+import "context"
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying the request ID id.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,66 @@
+package res
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SenechkaP/subs-tracker/pkg/requestid"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// ProblemTypePrefix turns a problem code into the RFC 7807 type URI.
+const ProblemTypePrefix = "urn:substracker:problem:"
+
+// ProblemInternal is the code of every 5xx problem. Its detail never
+// carries the underlying error.
+const ProblemInternal = "internal_error"
+
+// Problem codes used by more than one API package. The packages alias them
+// so the same problem always carries the same code.
+const (
+	ProblemInvalidUserID    = "invalid_user_id"
+	ProblemInvalidStartDate = "invalid_start_date"
+	ProblemInvalidEndDate   = "invalid_end_date"
+	ProblemInvalidDateRange = "invalid_date_range"
+	ProblemMissingParameter = "missing_parameter"
+	ProblemInvalidParameter = "invalid_parameter"
+	ProblemBodyTooLarge     = "body_too_large"
+)
+
+// Problem is an RFC 7807 problem details document. Code is the stable,
+// machine-readable identifier of the problem and Type is its URI form;
+// Detail is the human-readable message for this occurrence.
+type Problem struct {
+	Type      string `json:"type"`
+	Code      string `json:"code"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func NewProblem(r *http.Request, status int, code, detail string) Problem {
+	return Problem{
+		Type:      ProblemTypePrefix + code,
+		Code:      code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestid.FromContext(r.Context()),
+	}
+}
+
+// ProblemDump writes problem, a Problem or a struct embedding one, as
+// application/problem+json.
+func ProblemDump(w http.ResponseWriter, problem any, statusCode int) {
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func WriteProblem(w http.ResponseWriter, r *http.Request, statusCode int, code, detail string) {
+	ProblemDump(w, NewProblem(r, statusCode, code, detail), statusCode)
+}
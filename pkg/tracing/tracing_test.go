@@ -0,0 +1,73 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SenechkaP/subs-tracker/configs"
+	"github.com/SenechkaP/subs-tracker/internal/models"
+	"github.com/SenechkaP/subs-tracker/pkg/db"
+	"github.com/SenechkaP/subs-tracker/pkg/middleware"
+	"github.com/SenechkaP/subs-tracker/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(noop.NewTracerProvider()) })
+
+	database, err := db.OpenSQLite(":memory:", configs.DBLogLevelSilent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tracing.InstrumentDB(database); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.AutoMigrate(&models.Subscription{}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /subscriptions/{sub_id}", func(w http.ResponseWriter, r *http.Request) {
+		var sub models.Subscription
+		database.WithContext(r.Context()).Limit(1).Find(&sub)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/subscriptions/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	middleware.Tracing(router).ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	var server, query sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		switch span.SpanKind() {
+		case trace.SpanKindServer:
+			server = span
+		case trace.SpanKindClient:
+			query = span
+		}
+	}
+	if server == nil || query == nil {
+		t.Fatalf("got %d spans, want a server span and a query span", len(spans))
+	}
+	if server.Name() != "GET /subscriptions/{sub_id}" {
+		t.Fatalf("server span name = %q", server.Name())
+	}
+	if server.SpanContext().TraceID().String() != traceID {
+		t.Fatalf("server span trace id = %s, want %s from traceparent", server.SpanContext().TraceID(), traceID)
+	}
+	if query.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatal("query span is not a child of the server span")
+	}
+}